@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// 执行自定义操作
+func runAction(action ActionConfig) {
+	if action.Confirm != "" && !messageBoxConfirm(AppName, action.Confirm) {
+		return
+	}
+
+	var err error
+	switch {
+	case action.Url != "":
+		err = openBrowser(action.Url)
+	case action.Command != "":
+		err = startConsoleProcess(action.Command, action.Args, action.Dir)
+	case action.Api != nil:
+		var result string
+		if result, err = callActionApi(action.Api); err == nil {
+			sendNotification(I.TranSys("msg.info.action_done", map[string]any{"Name": action.Name, "Result": result}))
+		}
+	default:
+		err = fmt.Errorf("no url, command or api configured")
+	}
+	if err != nil {
+		log.Printf("Failed to run action %s: %v\n", action.Name, err)
+		messageBoxAlert(AppName, I.TranSys("msg.error.action_failed", map[string]any{"Name": action.Name, "Error": err}))
+	}
+}
+
+// 调用外部控制器接口，返回响应状态和内容摘要
+func callActionApi(api *ActionApiConfig) (string, error) {
+	method := strings.ToUpper(api.Method)
+	if method == "" {
+		method = http.MethodGet
+	}
+	var body io.Reader
+	if api.Body != "" {
+		body = strings.NewReader(api.Body)
+	}
+	resp, err := controllerRequest(method, api.Path, body)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	content, _ := io.ReadAll(io.LimitReader(resp.Body, 256))
+	result := resp.Status
+	if text := strings.TrimSpace(string(content)); text != "" {
+		result += "\n" + text
+	}
+	return result, nil
+}
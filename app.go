@@ -3,7 +3,9 @@ package main
 import (
 	"log"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"slices"
 	"sync/atomic"
 	"time"
 
@@ -13,8 +15,131 @@ import (
 )
 
 type AppConfig struct {
-	CoreLogEnabled bool     `yaml:"core-log-enabled" mapstructure:"core-log-enabled"` // 是否启用记录核心日志
-	ProxyByPass    []string `yaml:"proxy-by-pass" mapstructure:"proxy-by-pass"`       // 代理白名单地址
+	CoreLogEnabled      bool                       `yaml:"core-log-enabled" mapstructure:"core-log-enabled"`         // 是否启用记录核心日志
+	ProxyByPass         []string                   `yaml:"proxy-by-pass" mapstructure:"proxy-by-pass"`               // 代理白名单地址
+	ProxyProtocols      []string                   `yaml:"proxy-protocols" mapstructure:"proxy-protocols"`           // 系统代理使用的协议：http、https、socks
+	ToolProxySync       []string                   `yaml:"tool-proxy-sync" mapstructure:"tool-proxy-sync"`           // 同步系统代理的开发工具：git、npm、pip、docker
+	Terminals           []TerminalConfig           `yaml:"terminals" mapstructure:"terminals"`                       // 托盘中可打开的终端列表
+	Actions             []ActionConfig             `yaml:"actions" mapstructure:"actions"`                           // 托盘中的自定义操作
+	Dashboards          []DashboardConfig          `yaml:"dashboards" mapstructure:"dashboards"`                     // 托盘中的控制面板列表
+	DashboardGateway    GatewayConfig              `yaml:"dashboard-gateway" mapstructure:"dashboard-gateway"`       // 本地控制面板网关
+	ExternalUiSource    UiSourceConfig             `yaml:"external-ui-source" mapstructure:"external-ui-source"`     // 外部ui安装来源
+	CoreRelease         CoreReleaseConfig          `yaml:"core-release" mapstructure:"core-release"`                 // core程序的发布来源
+	Update              UpdateConfig               `yaml:"update" mapstructure:"update"`                             // 程序更新
+	GeoData             GeoDataConfig              `yaml:"geodata" mapstructure:"geodata"`                           // geodata数据库更新
+	SubscriptionAlert   SubscriptionAlertConfig    `yaml:"subscription-alert" mapstructure:"subscription-alert"`     // 订阅流量和到期提醒
+	ProfileGenerators   []ProfileGeneratorConfig   `yaml:"profile-generators" mapstructure:"profile-generators"`     // 由模板和多个订阅生成的配置文件
+	SubscriptionFilters []SubscriptionFilterConfig `yaml:"subscription-filters" mapstructure:"subscription-filters"` // 订阅节点过滤及重命名规则
+	Scripts             ScriptsConfig              `yaml:"scripts" mapstructure:"scripts"`                           // 配置转换脚本
+	DirectBypass        DirectBypassConfig         `yaml:"direct-bypass" mapstructure:"direct-bypass"`               // 从配置文件直连规则生成代理白名单
+}
+
+// TerminalConfig 终端启动配置
+type TerminalConfig struct {
+	Name    string   `yaml:"name" mapstructure:"name"`           // 托盘中显示的名称
+	Command string   `yaml:"command" mapstructure:"command"`     // 启动命令
+	Args    []string `yaml:"args,omitempty" mapstructure:"args"` // 启动参数
+	Dir     string   `yaml:"dir,omitempty" mapstructure:"dir"`   // 工作目录，为空时使用程序目录，支持环境变量
+}
+
+// ActionConfig 自定义托盘操作，url、command、api 三选一，都为空且有 children 时作为子菜单
+type ActionConfig struct {
+	Name     string           `yaml:"name" mapstructure:"name"`                   // 托盘中显示的名称
+	Confirm  string           `yaml:"confirm,omitempty" mapstructure:"confirm"`   // 执行前的确认提示，为空时不确认
+	Url      string           `yaml:"url,omitempty" mapstructure:"url"`           // 使用默认程序打开的地址
+	Command  string           `yaml:"command,omitempty" mapstructure:"command"`   // 执行的命令，会设置代理环境变量
+	Args     []string         `yaml:"args,omitempty" mapstructure:"args"`         // 命令参数
+	Dir      string           `yaml:"dir,omitempty" mapstructure:"dir"`           // 命令工作目录
+	Api      *ActionApiConfig `yaml:"api,omitempty" mapstructure:"api"`           // 调用的外部控制器接口
+	Children []ActionConfig   `yaml:"children,omitempty" mapstructure:"children"` // 子菜单
+}
+
+// ActionApiConfig 自定义操作调用的外部控制器接口
+type ActionApiConfig struct {
+	Method string `yaml:"method" mapstructure:"method"`       // 请求方法，默认 GET
+	Path   string `yaml:"path" mapstructure:"path"`           // 接口路径，如 /cache/dns/flush
+	Body   string `yaml:"body,omitempty" mapstructure:"body"` // 请求体（json）
+}
+
+// DashboardConfig 控制面板配置
+type DashboardConfig struct {
+	Name  string `yaml:"name" mapstructure:"name"`             // 托盘中显示的名称
+	Url   string `yaml:"url" mapstructure:"url"`               // 面板地址，Go text/template 模板，可用 Host、Port、Secret、TLS、UiPath
+	Local bool   `yaml:"local,omitempty" mapstructure:"local"` // 是否为core外部ui提供的本地面板，未配置 external-ui 时隐藏
+}
+
+// GatewayConfig 本地控制面板网关配置
+type GatewayConfig struct {
+	Enabled bool   `yaml:"enabled" mapstructure:"enabled"`         // 是否启用，启用后面板地址中不再包含密钥
+	Listen  string `yaml:"listen,omitempty" mapstructure:"listen"` // 监听地址，只允许本地回环地址，默认随机端口
+}
+
+// UiSourceConfig 外部ui安装来源配置
+type UiSourceConfig struct {
+	Name       string `yaml:"name" mapstructure:"name"`               // 内置来源：metacubexd、zashboard
+	Url        string `yaml:"url,omitempty" mapstructure:"url"`       // 自定义压缩包地址（zip 或 tar.gz），配置后忽略 name
+	Sha256     string `yaml:"sha256,omitempty" mapstructure:"sha256"` // 自定义压缩包的 sha256 摘要
+	AutoUpdate bool   `yaml:"auto-update" mapstructure:"auto-update"` // 启动时自动检查更新
+}
+
+// CoreReleaseConfig core程序发布来源配置
+type CoreReleaseConfig struct {
+	Repo    string `yaml:"repo" mapstructure:"repo"`                   // GitHub 发布仓库
+	ApiBase string `yaml:"api-base,omitempty" mapstructure:"api-base"` // GitHub API 地址，可替换为兼容的镜像
+	Level   string `yaml:"level,omitempty" mapstructure:"level"`       // amd64 构建级别 v1、v2、v3，为空时自动检测
+}
+
+// UpdateConfig 程序更新配置
+type UpdateConfig struct {
+	Channel       string   `yaml:"channel" mapstructure:"channel"`               // 更新通道：stable、beta
+	Mirrors       []string `yaml:"mirrors,omitempty" mapstructure:"mirrors"`     // 更新包下载镜像地址前缀，如 https://ghfast.top/，依次尝试，都失败时直连
+	ApiBase       string   `yaml:"api-base,omitempty" mapstructure:"api-base"`   // GitHub API 地址，可替换为兼容的镜像
+	CheckInterval string   `yaml:"check-interval" mapstructure:"check-interval"` // 后台检查更新的间隔，如 24h，为空或 0 时不检查
+}
+
+// GeoDataConfig geodata数据库更新配置
+type GeoDataConfig struct {
+	UpdateInterval string `yaml:"update-interval" mapstructure:"update-interval"` // 定时更新的间隔，默认 24h，为 0 时只下载缺失的数据库
+}
+
+// ProfileGeneratorConfig 生成配置文件的配置
+type ProfileGeneratorConfig struct {
+	Name     string   `yaml:"name" mapstructure:"name"`         // 生成的配置文件名称
+	Template string   `yaml:"template" mapstructure:"template"` // 模板文件路径，相对路径基于程序所在目录
+	Sources  []string `yaml:"sources" mapstructure:"sources"`   // 订阅配置文件名称或订阅地址
+}
+
+// SubscriptionFilterConfig 订阅节点过滤及重命名规则
+type SubscriptionFilterConfig struct {
+	Profile string             `yaml:"profile" mapstructure:"profile"` // 订阅配置文件名称，* 表示所有订阅
+	Include string             `yaml:"include" mapstructure:"include"` // 只保留名称匹配的节点
+	Exclude string             `yaml:"exclude" mapstructure:"exclude"` // 删除名称匹配的节点
+	Rename  []RenameRuleConfig `yaml:"rename" mapstructure:"rename"`   // 依次应用的重命名规则
+	Flag    bool               `yaml:"flag" mapstructure:"flag"`       // 根据识别的地区在名称前添加旗帜
+}
+
+// RenameRuleConfig 节点重命名规则
+type RenameRuleConfig struct {
+	Pattern string `yaml:"pattern" mapstructure:"pattern"` // 正则表达式
+	Replace string `yaml:"replace" mapstructure:"replace"` // 替换内容，可使用 $1 引用捕获组
+}
+
+// ScriptsConfig 配置转换脚本配置
+type ScriptsConfig struct {
+	Enabled bool   `yaml:"enabled" mapstructure:"enabled"` // 是否在生成运行配置时运行 scripts 目录中的脚本
+	Timeout string `yaml:"timeout" mapstructure:"timeout"` // 单个脚本的运行时间限制，默认 5s
+}
+
+// DirectBypassConfig 从配置文件直连规则生成代理白名单配置
+type DirectBypassConfig struct {
+	Enabled    bool `yaml:"enabled" mapstructure:"enabled"`         // 是否将策略为 DIRECT 的规则和 hosts 加入代理白名单
+	MaxEntries int  `yaml:"max-entries" mapstructure:"max-entries"` // 生成的白名单地址数量上限，默认 200
+}
+
+// SubscriptionAlertConfig 订阅流量和到期提醒配置
+type SubscriptionAlertConfig struct {
+	UsageThresholds []int `yaml:"usage-thresholds" mapstructure:"usage-thresholds"` // 已用流量达到这些百分比时提醒
+	ExpireDays      int   `yaml:"expire-days" mapstructure:"expire-days"`           // 到期前多少天提醒，0 不提醒
 }
 
 const (
@@ -37,6 +162,22 @@ func initAppConfig() {
 	appConfig.Store(&AppConfig{
 		CoreLogEnabled: false,
 		ProxyByPass:    defaultBypassHosts,
+		ProxyProtocols: defaultProxyProtocols,
+		Terminals:      defaultTerminals(),
+		Dashboards:     defaultDashboards(),
+		ExternalUiSource: UiSourceConfig{
+			Name: UiSourceMetaCubeXD,
+		},
+		CoreRelease: CoreReleaseConfig{
+			Repo: defaultCoreRepo,
+		},
+		Update: UpdateConfig{
+			Channel: UpdateChannelStable,
+		},
+		SubscriptionAlert: SubscriptionAlertConfig{
+			UsageThresholds: defaultUsageThresholds,
+			ExpireDays:      defaultExpireDays,
+		},
 	})
 
 	appConfigPath = filepath.Join(workDir, "gohomo.yaml")
@@ -57,12 +198,43 @@ func initAppConfig() {
 	watchAppConfig()
 }
 
+// 只读加载应用配置，供命令行子命令使用，不创建默认配置也不监听变化
+func loadAppConfigReadOnly() {
+	appConfigPath = filepath.Join(workDir, "gohomo.yaml")
+	appConfigViper = viper.New()
+	appConfigViper.SetConfigFile(appConfigPath)
+	if err := appConfigViper.ReadInConfig(); err != nil {
+		log.Println("Failed to read app config:", err)
+	}
+	if err := loadAppConfig(); err != nil {
+		log.Println("Failed to load app config:", err)
+		appConfig.Store(new(AppConfig))
+	}
+}
+
 func loadAppConfig() error {
 	tempConfig := new(AppConfig)
 	if err := appConfigViper.Unmarshal(tempConfig); err != nil {
 		return err
 	}
 
+	// 未配置的列表项使用默认值，不在解析前填充，避免配置项与默认值逐项合并
+	if !appConfigViper.IsSet("proxy-protocols") {
+		tempConfig.ProxyProtocols = slices.Clone(defaultProxyProtocols)
+	}
+	if !appConfigViper.IsSet("terminals") {
+		tempConfig.Terminals = defaultTerminals()
+	}
+	if !appConfigViper.IsSet("dashboards") {
+		tempConfig.Dashboards = defaultDashboards()
+	}
+	if !appConfigViper.IsSet("subscription-alert.usage-thresholds") {
+		tempConfig.SubscriptionAlert.UsageThresholds = slices.Clone(defaultUsageThresholds)
+	}
+	if !appConfigViper.IsSet("subscription-alert.expire-days") {
+		tempConfig.SubscriptionAlert.ExpireDays = defaultExpireDays
+	}
+
 	appConfig.Store(tempConfig)
 	log.Println("App config loaded:", appConfigPath)
 	return nil
@@ -72,6 +244,19 @@ func getAppConfig() *AppConfig {
 	return appConfig.Load().(*AppConfig)
 }
 
+// 默认终端列表，优先使用 PowerShell 7
+func defaultTerminals() []TerminalConfig {
+	ps := "pwsh.exe"
+	if _, err := exec.LookPath(ps); err != nil {
+		// 不存在使用系统默认的 PowerShell
+		ps = "powershell.exe"
+	}
+	return []TerminalConfig{
+		{Name: I.TranSys("tray.open.options.powershell", nil), Command: ps},
+		{Name: I.TranSys("tray.open.options.cmd", nil), Command: "cmd.exe"},
+	}
+}
+
 func writeDefaultAppConfig(path string) error {
 	out, err := yaml.Marshal(getAppConfig())
 	if err != nil {
@@ -107,6 +292,17 @@ func watchAppConfig() {
 		if getProxyEnable() {
 			setCoreProxy()
 		}
+
+		// 重建托盘菜单
+		reloadSystray()
+
+		// 同步生成的配置文件
+		go func() {
+			if err := syncProfileGenerators(true); err != nil {
+				log.Println("Failed to sync profile generators:", err)
+			}
+			buildProfileItems()
+		}()
 	})
 
 	appConfigViper.WatchConfig()
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"text/tabwriter"
+)
+
+// CliCommand 命令行子命令
+type CliCommand struct {
+	Name  string
+	Usage string
+	Run   func(args []string) error
+}
+
+// 命令行子命令列表
+var cliCommands = []CliCommand{
+	{
+		Name:  "providers",
+		Usage: "providers [list | update [name...] | healthcheck [name...]]",
+		Run:   runProvidersCommand,
+	},
+	{
+		Name:  "profiles",
+		Usage: "profiles [list | import | update [name...] | preview name]",
+		Run:   runProfilesCommand,
+	},
+	{
+		Name:  "which",
+		Usage: "which host[:port] | url",
+		Run:   runWhichCommand,
+	},
+	{
+		Name:  "rule",
+		Usage: "rule [list | add TYPE,payload,policy | rm rule|index]",
+		Run:   runRuleCommand,
+	},
+}
+
+// 执行命令行子命令，返回进程退出码
+func runCli(args []string) int {
+	attachParentConsole()
+	// 命令行不写入日志文件
+	log.SetOutput(io.Discard)
+
+	index := slices.IndexFunc(cliCommands, func(c CliCommand) bool { return c.Name == args[0] })
+	if index < 0 {
+		printCliUsage()
+		return 2
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return 1
+	}
+	workDir = filepath.Dir(executable)
+	loadAppConfigReadOnly()
+
+	if err = cliCommands[index].Run(args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return 1
+	}
+	return 0
+}
+
+// 打印命令行用法
+func printCliUsage() {
+	fmt.Fprintln(os.Stderr, "Usage:")
+	for _, command := range cliCommands {
+		fmt.Fprintln(os.Stderr, "  gohomo", command.Usage)
+	}
+}
+
+// providers 子命令：列出、更新或健康检查提供者
+func runProvidersCommand(args []string) error {
+	if err := loadRunningCoreConfig(); err != nil {
+		return err
+	}
+	action := "list"
+	if len(args) > 0 {
+		action, args = args[0], args[1:]
+	}
+	providers, err := fetchAllProviders()
+	if err != nil {
+		return err
+	}
+
+	switch action {
+	case "list":
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tKIND\tTYPE\tCOUNT\tUPDATED")
+		for _, provider := range providers {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\n", provider.Name, provider.Kind, provider.VehicleType,
+				provider.Count(), formatProviderTime(provider.UpdatedAt))
+		}
+		return w.Flush()
+	case "update", "healthcheck":
+		if action == "healthcheck" && len(args) == 0 {
+			// 规则集合不支持健康检查，指定名称时报错
+			providers = proxyProviders(providers)
+		}
+		if providers, err = filterProviders(providers, args); err != nil {
+			return err
+		}
+		fn := updateProvider
+		if action == "healthcheck" {
+			fn = healthcheckProvider
+		}
+		failed := 0
+		for _, provider := range providers {
+			if err := fn(provider); err != nil {
+				fmt.Printf("%s: %v\n", provider.Name, err)
+				failed++
+			} else {
+				fmt.Printf("%s: ok\n", provider.Name)
+			}
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d of %d providers failed", failed, len(providers))
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown action: %s, expected list, update or healthcheck", action)
+	}
+}
+
+// profiles 子命令：列出、从其他客户端导入或更新配置文件
+func runProfilesCommand(args []string) error {
+	action := "list"
+	if len(args) > 0 {
+		action, args = args[0], args[1:]
+	}
+	store := getProfileStore()
+
+	switch action {
+	case "list":
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ACTIVE\tNAME\tTYPE\tINTERVAL\tUPDATED")
+		for _, profile := range store.List() {
+			active := ""
+			if profile.Name == store.ActiveName() {
+				active = "*"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", active, profile.Name, profile.Type, profile.UpdateInterval,
+				formatProviderTime(profile.UpdatedAt))
+		}
+		return w.Flush()
+	case "import":
+		profiles, err := findClientProfiles(defaultClashClients())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Warning:", err)
+		}
+		imported, _, err := importClientProfiles(store, profiles)
+		for _, profile := range imported {
+			fmt.Printf("%s: imported\n", profile.Name)
+		}
+		fmt.Printf("%d of %d profiles imported\n", len(imported), len(profiles))
+		return err
+	case "update":
+		failed := 0
+		for _, profile := range store.List() {
+			if profile.Type != ProfileTypeRemote || len(args) > 0 && !slices.Contains(args, profile.Name) {
+				continue
+			}
+			if err := fetchRemoteProfile(store, profile); err != nil {
+				fmt.Printf("%s: %v\n", profile.Name, err)
+				failed++
+			} else {
+				fmt.Printf("%s: ok\n", profile.Name)
+			}
+		}
+		if err := store.Save(); err != nil {
+			return err
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d profiles failed", failed)
+		}
+		return nil
+	case "preview":
+		if len(args) != 1 {
+			return fmt.Errorf("usage: profiles preview name")
+		}
+		profile := store.Get(args[0])
+		if profile == nil || profile.Type != ProfileTypeRemote {
+			return fmt.Errorf("subscription profile not found: %s", args[0])
+		}
+		results, err := previewSubscriptionFilter(profile)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "RESULT\tNAME\tNEW NAME")
+		kept := 0
+		for _, result := range results {
+			if result.Kept {
+				kept++
+				fmt.Fprintf(w, "kept\t%s\t%s\n", result.Name, result.NewName)
+			} else {
+				fmt.Fprintf(w, "dropped\t%s\t\n", result.Name)
+			}
+		}
+		fmt.Fprintf(w, "\n%d kept, %d dropped\n", kept, len(results)-kept)
+		return w.Flush()
+	default:
+		return fmt.Errorf("unknown action: %s, expected list, import, update or preview", action)
+	}
+}
+
+// which 子命令：离线匹配正在使用的配置文件中的规则，输出目标使用的规则和策略
+func runWhichCommand(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: which host[:port] | url")
+	}
+	target, err := ParseRuleTarget(args[0])
+	if err != nil {
+		return err
+	}
+	path, err := findActiveCoreConfigPath()
+	if err != nil {
+		return err
+	}
+	matcher, err := loadRuleMatcher(path)
+	if err != nil {
+		return err
+	}
+	fmt.Println(formatRuleMatch(target, matcher.Match(target)))
+	return nil
+}
+
+// rule 子命令：列出、添加或删除用户规则，修改后重新加载正在运行的core
+func runRuleCommand(args []string) error {
+	action := "list"
+	if len(args) > 0 {
+		action, args = args[0], args[1:]
+	}
+	path, err := findActiveCoreConfigPath()
+	if err != nil {
+		return err
+	}
+
+	switch action {
+	case "list":
+		rules, err := loadUserRules()
+		if err != nil {
+			return err
+		}
+		for i, rule := range rules {
+			fmt.Printf("%d\t%s\n", i+1, rule)
+		}
+		return nil
+	case "add", "rm":
+		if len(args) != 1 {
+			return fmt.Errorf("usage: rule %s rule", action)
+		}
+		if action == "add" {
+			err = addUserRule(args[0], path)
+		} else {
+			var removed string
+			if removed, err = removeUserRule(args[0]); err == nil {
+				fmt.Println("Removed:", removed)
+			}
+		}
+		if err != nil {
+			return err
+		}
+		coreConfigPath = path
+		if err = reloadCoreConfigFromCli(); err != nil {
+			fmt.Fprintln(os.Stderr, "Warning: saved, but the running core was not reloaded:", err)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown action: %s, expected list, add or rm", action)
+	}
+}
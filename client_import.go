@@ -0,0 +1,290 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// ClashClient 可以导入配置文件的其他 Clash 系客户端
+type ClashClient struct {
+	Name  string                                            // 客户端名称
+	Dirs  []string                                          // 可能的数据目录，使用第一个存在的目录
+	Parse func(client, dir string) ([]ClientProfile, error) // 解析数据目录中的配置文件列表
+}
+
+// ClientProfile 其他客户端中的配置文件
+type ClientProfile struct {
+	Client         string        // 客户端名称
+	Name           string        // 配置文件名称
+	Url            string        // 订阅地址，本地配置文件为空
+	Path           string        // 配置文件路径
+	UpdateInterval time.Duration // 订阅的更新间隔
+	Active         bool          // 是否为客户端正在使用的配置文件
+}
+
+// 其他 Clash 系客户端及其数据目录，appData 为 %APPDATA%，home 为用户目录
+func clashClients(appData, home string) []ClashClient {
+	return []ClashClient{
+		{
+			Name:  "Clash Verge Rev",
+			Dirs:  []string{filepath.Join(appData, "io.github.clash-verge-rev.clash-verge-rev")},
+			Parse: parseVergeProfiles,
+		},
+		{
+			Name:  "Clash Verge",
+			Dirs:  []string{filepath.Join(home, ".config", "clash-verge")},
+			Parse: parseVergeProfiles,
+		},
+		{
+			// 基于 Clash Verge，配置文件列表格式相同
+			Name: "Clash Nyanpasu",
+			Dirs: []string{
+				filepath.Join(appData, "moe.elaina.clash.nyanpasu"),
+				filepath.Join(home, ".config", "clash-nyanpasu"),
+			},
+			Parse: parseVergeProfiles,
+		},
+		{
+			Name:  "Clash for Windows",
+			Dirs:  []string{filepath.Join(home, ".config", "clash")},
+			Parse: parseCfwProfiles,
+		},
+	}
+}
+
+// 当前用户的其他 Clash 系客户端
+func defaultClashClients() []ClashClient {
+	home, _ := os.UserHomeDir()
+	return clashClients(os.Getenv("APPDATA"), home)
+}
+
+// Clash Verge 系客户端的 profiles.yaml
+type vergeProfileList struct {
+	Current any `yaml:"current"` // Clash Verge 为 uid，Clash Nyanpasu 为 uid 列表
+	Items   []struct {
+		Uid    string `yaml:"uid"`
+		Type   any    `yaml:"type"` // remote、local、merge、script
+		Name   string `yaml:"name"`
+		File   string `yaml:"file"`
+		Url    string `yaml:"url"`
+		Option struct {
+			UpdateInterval int `yaml:"update_interval"` // 分钟
+		} `yaml:"option"`
+	} `yaml:"items"`
+}
+
+// 解析 Clash Verge 系客户端的配置文件列表，忽略 merge 和 script 等增强配置
+func parseVergeProfiles(client, dir string) ([]ClientProfile, error) {
+	var list vergeProfileList
+	if err := readYamlFile(filepath.Join(dir, "profiles.yaml"), &list); err != nil {
+		return nil, err
+	}
+	var current []string
+	switch value := list.Current.(type) {
+	case string:
+		current = []string{value}
+	case []any:
+		for _, uid := range value {
+			current = append(current, fmt.Sprint(uid))
+		}
+	}
+	var profiles []ClientProfile
+	for _, item := range list.Items {
+		itemType, _ := item.Type.(string)
+		if (itemType != ProfileTypeRemote && itemType != ProfileTypeLocal) || item.File == "" {
+			continue
+		}
+		profile := ClientProfile{
+			Client: client,
+			Name:   item.Name,
+			Path:   filepath.Join(dir, "profiles", item.File),
+			Active: slices.Contains(current, item.Uid),
+		}
+		if itemType == ProfileTypeRemote {
+			profile.Url = item.Url
+			profile.UpdateInterval = time.Duration(item.Option.UpdateInterval) * time.Minute
+		}
+		if profile.Name == "" {
+			profile.Name = item.Uid
+		}
+		profiles = append(profiles, profile)
+	}
+	return profiles, nil
+}
+
+// Clash for Windows 的 profiles/list.yml
+type cfwProfileList struct {
+	Index int `yaml:"index"` // 正在使用的配置文件序号
+	Files []struct {
+		Time     string  `yaml:"time"` // 配置文件名
+		Name     string  `yaml:"name"`
+		Url      string  `yaml:"url"`
+		Interval float64 `yaml:"interval"` // 小时
+	} `yaml:"files"`
+}
+
+// 解析 Clash for Windows 的配置文件列表
+func parseCfwProfiles(client, dir string) ([]ClientProfile, error) {
+	var list cfwProfileList
+	if err := readYamlFile(filepath.Join(dir, "profiles", "list.yml"), &list); err != nil {
+		return nil, err
+	}
+	var profiles []ClientProfile
+	for i, file := range list.Files {
+		if file.Time == "" {
+			continue
+		}
+		profile := ClientProfile{
+			Client:         client,
+			Name:           file.Name,
+			Url:            file.Url,
+			Path:           filepath.Join(dir, "profiles", file.Time),
+			UpdateInterval: time.Duration(file.Interval * float64(time.Hour)),
+			Active:         i == list.Index,
+		}
+		if profile.Name == "" {
+			profile.Name = strings.TrimSuffix(file.Time, filepath.Ext(file.Time))
+		}
+		profiles = append(profiles, profile)
+	}
+	return profiles, nil
+}
+
+func readYamlFile(path string, out any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err = yaml.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// 查找已安装客户端的配置文件，客户端未安装时忽略
+func findClientProfiles(clients []ClashClient) ([]ClientProfile, error) {
+	var profiles []ClientProfile
+	var errs []error
+	for _, client := range clients {
+		for _, dir := range client.Dirs {
+			if !isFileExist(dir) {
+				continue
+			}
+			found, err := client.Parse(client.Name, dir)
+			if err != nil {
+				if !errors.Is(err, os.ErrNotExist) {
+					errs = append(errs, fmt.Errorf("%s: %w", client.Name, err))
+				}
+				continue
+			}
+			profiles = append(profiles, found...)
+			break
+		}
+	}
+	return profiles, errors.Join(errs...)
+}
+
+// 将其他客户端的配置文件导入为 Gohomo 的配置文件，已存在相同订阅地址或名称的配置文件时跳过
+// 订阅的缓存文件不存在时重新下载，返回导入的配置文件以及其中客户端正在使用的配置文件
+func importClientProfiles(store *ProfileStore, profiles []ClientProfile) ([]*Profile, *Profile, error) {
+	if err := os.MkdirAll(store.dir, 0755); err != nil {
+		return nil, nil, err
+	}
+	var imported []*Profile
+	var active *Profile
+	var errs []error
+	for _, source := range profiles {
+		if slices.ContainsFunc(store.List(), func(p *Profile) bool {
+			if source.Url != "" {
+				return p.Url == source.Url
+			}
+			return p.Type == ProfileTypeLocal && p.Name == source.Name
+		}) {
+			continue
+		}
+		profile := &Profile{Name: source.Name, Type: ProfileTypeLocal}
+		if source.Url != "" {
+			profile.Type = ProfileTypeRemote
+			profile.Url = source.Url
+			if source.UpdateInterval > 0 {
+				profile.UpdateInterval = source.UpdateInterval.String()
+			}
+		}
+		store.Add(profile)
+
+		err := copyFile(source.Path, store.Path(profile))
+		if err == nil {
+			if info, err := os.Stat(source.Path); err == nil {
+				profile.UpdatedAt = info.ModTime()
+			}
+		} else if profile.Type == ProfileTypeRemote {
+			err = fetchRemoteProfile(store, profile)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s %s: %w", source.Client, source.Name, err))
+			store.Remove(profile.Name)
+			continue
+		}
+		log.Println("Imported profile from", source.Client+":", profile.Name)
+		imported = append(imported, profile)
+		if source.Active && active == nil {
+			active = profile
+		}
+	}
+	if len(imported) > 0 {
+		errs = append(errs, store.Save())
+	}
+	return imported, active, errors.Join(errs...)
+}
+
+// 在托盘中导入其他客户端的配置文件，未选择配置文件时询问是否切换到客户端正在使用的配置文件
+func importClientProfilesAndNotify() {
+	profiles, err := findClientProfiles(defaultClashClients())
+	if err != nil {
+		log.Println("Failed to read client profiles:", err)
+	}
+	if len(profiles) == 0 {
+		messageBoxAlert(AppName, I.TranSys("msg.info.profiles_no_client", nil))
+		return
+	}
+	store := getProfileStore()
+	imported, active, err := importClientProfiles(store, profiles)
+	if err != nil {
+		log.Println("Failed to import client profiles:", err)
+		messageBoxAlert(AppName, I.TranSys("msg.error.profiles_failed", map[string]any{"Error": err}))
+	}
+	if len(imported) == 0 {
+		if err == nil {
+			// 找到的配置文件都已导入过
+			messageBoxAlert(AppName, I.TranSys("msg.info.profiles_already_imported", map[string]any{"Count": len(profiles)}))
+		}
+		return
+	}
+	names := make([]string, 0, len(imported))
+	for _, profile := range imported {
+		names = append(names, profile.Name)
+	}
+	sendNotification(I.TranSys("msg.info.profiles_imported", map[string]any{
+		"Count": len(imported),
+		"Names": strings.Join(names, ", "),
+	}))
+	buildProfileItems()
+
+	if active == nil || store.ActiveName() != "" {
+		return
+	}
+	if messageBoxConfirm(AppName, I.TranSys("msg.info.profile_switch", map[string]any{"Name": active.Name})) {
+		if err := switchProfile(active.Name); err != nil {
+			messageBoxAlert(AppName, fmt.Sprint(err))
+		}
+		buildProfileItems()
+	}
+}
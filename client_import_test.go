@@ -0,0 +1,155 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// 在临时的 %APPDATA% 和用户目录中生成其他客户端的数据目录
+func writeClientFixtures(t *testing.T) (appData, home string) {
+	t.Helper()
+	root := t.TempDir()
+	appData, home = filepath.Join(root, "AppData", "Roaming"), filepath.Join(root, "home")
+
+	verge := filepath.Join(appData, "io.github.clash-verge-rev.clash-verge-rev")
+	writeTestFile(t, filepath.Join(verge, "profiles.yaml"), `current: R2
+items:
+  - uid: m1
+    type: merge
+    file: m1.yaml
+  - uid: R1
+    type: remote
+    name: Airport
+    file: R1.yaml
+    url: https://sub.example.com/a
+    option:
+      update_interval: 720
+  - uid: R2
+    type: remote
+    file: R2.yaml
+    url: https://sub.example.com/b
+  - uid: L1
+    type: local
+    name: Home
+    file: L1.yaml
+  - uid: s1
+    type: script
+    file: s1.js
+  - uid: L0
+    type: local
+    name: No File
+`)
+	for _, name := range []string{"R1", "R2", "L1"} {
+		writeTestFile(t, filepath.Join(verge, "profiles", name+".yaml"), "# "+name+"\nproxies: []\n")
+	}
+
+	// Clash Nyanpasu 使用备选目录，current 为列表
+	nyanpasu := filepath.Join(home, ".config", "clash-nyanpasu")
+	writeTestFile(t, filepath.Join(nyanpasu, "profiles.yaml"), `current: [L2]
+items:
+  - uid: L2
+    type: local
+    name: Nyan
+    file: L2.yaml
+`)
+	writeTestFile(t, filepath.Join(nyanpasu, "profiles", "L2.yaml"), "# L2\n")
+
+	cfw := filepath.Join(home, ".config", "clash", "profiles")
+	writeTestFile(t, filepath.Join(cfw, "list.yml"), `index: 1
+files:
+  - time: 1700000000000.yml
+    name: CFW Sub
+    url: https://sub.example.com/a
+    interval: 12
+  - time: 1700000000001.yml
+    url: https://sub.example.com/c
+    interval: 0.5
+  - name: Missing File
+`)
+	writeTestFile(t, filepath.Join(cfw, "1700000000000.yml"), "# cfw a\n")
+	writeTestFile(t, filepath.Join(cfw, "1700000000001.yml"), "# cfw c\n")
+	return appData, home
+}
+
+func TestFindClientProfiles(t *testing.T) {
+	appData, home := writeClientFixtures(t)
+	verge := filepath.Join(appData, "io.github.clash-verge-rev.clash-verge-rev", "profiles")
+	cfw := filepath.Join(home, ".config", "clash", "profiles")
+
+	profiles, err := findClientProfiles(clashClients(appData, home))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ClientProfile{
+		{Client: "Clash Verge Rev", Name: "Airport", Url: "https://sub.example.com/a", Path: filepath.Join(verge, "R1.yaml"), UpdateInterval: 12 * time.Hour},
+		{Client: "Clash Verge Rev", Name: "R2", Url: "https://sub.example.com/b", Path: filepath.Join(verge, "R2.yaml"), Active: true},
+		{Client: "Clash Verge Rev", Name: "Home", Path: filepath.Join(verge, "L1.yaml")},
+		{Client: "Clash Nyanpasu", Name: "Nyan", Path: filepath.Join(home, ".config", "clash-nyanpasu", "profiles", "L2.yaml"), Active: true},
+		{Client: "Clash for Windows", Name: "CFW Sub", Url: "https://sub.example.com/a", Path: filepath.Join(cfw, "1700000000000.yml"), UpdateInterval: 12 * time.Hour},
+		{Client: "Clash for Windows", Name: "1700000000001", Url: "https://sub.example.com/c", Path: filepath.Join(cfw, "1700000000001.yml"), UpdateInterval: 30 * time.Minute, Active: true},
+	}
+	if !reflect.DeepEqual(profiles, want) {
+		t.Fatalf("findClientProfiles() =\n%+v\nwant\n%+v", profiles, want)
+	}
+}
+
+func TestFindClientProfilesReportsBrokenClient(t *testing.T) {
+	appData, home := writeClientFixtures(t)
+	writeTestFile(t, filepath.Join(home, ".config", "clash", "profiles", "list.yml"), "files: [")
+
+	profiles, err := findClientProfiles(clashClients(appData, home))
+	if err == nil {
+		t.Fatal("expected error for broken profile list")
+	}
+	// 其他客户端的配置文件照常返回
+	if len(profiles) != 4 {
+		t.Fatalf("profiles = %+v", profiles)
+	}
+}
+
+func TestImportClientProfiles(t *testing.T) {
+	appData, home := writeClientFixtures(t)
+	profiles, err := findClientProfiles(clashClients(appData, home))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	store := NewProfileStore(filepath.Join(dir, profileStateFile), filepath.Join(dir, profilesDir))
+	store.Add(&Profile{Name: "Home", Type: ProfileTypeLocal})
+
+	imported, active, err := importClientProfiles(store, profiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, profile := range imported {
+		names = append(names, profile.Name)
+	}
+	// 已存在的本地配置文件和重复的订阅地址跳过
+	if want := []string{"Airport", "R2", "Nyan", "1700000000001"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("imported = %v, want %v", names, want)
+	}
+	if active == nil || active.Name != "R2" {
+		t.Fatalf("active = %+v", active)
+	}
+
+	saved := NewProfileStore(filepath.Join(dir, profileStateFile), filepath.Join(dir, profilesDir))
+	airport := saved.Get("Airport")
+	if airport == nil || airport.Type != ProfileTypeRemote || airport.Url != "https://sub.example.com/a" || airport.UpdateInterval != "12h0m0s" {
+		t.Fatalf("saved profile = %+v", airport)
+	}
+	if data, err := os.ReadFile(saved.Path(airport)); err != nil || string(data) != "# R1\nproxies: []\n" {
+		t.Fatalf("profile content = %q, %v", data, err)
+	}
+	if nyan := saved.Get("Nyan"); nyan == nil || nyan.Type != ProfileTypeLocal || nyan.Url != "" {
+		t.Fatalf("saved profile = %+v", nyan)
+	}
+
+	// 再次导入时全部跳过
+	if imported, _, err = importClientProfiles(store, profiles); err != nil || len(imported) != 0 {
+		t.Fatalf("imported again = %v, %v", imported, err)
+	}
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"slices"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// 检查结果的严重程度
+const (
+	LintError   = "error"   // 配置无法使用，阻止启动
+	LintWarning = "warning" // 配置可以使用，但可能有误
+)
+
+// 内置策略，可以在代理组和规则中直接引用
+var builtinPolicies = []string{"DIRECT", "REJECT", "REJECT-DROP", "PASS", "COMPATIBLE", "GLOBAL"}
+
+// LintFinding 配置检查发现的问题
+type LintFinding struct {
+	Severity    string // LintError 或 LintWarning
+	Line        int    // 所在行号，从 1 开始
+	Message     string
+	Transformed bool // 只在脚本转换后的配置中出现，行号对应转换后的内容
+}
+
+func (f LintFinding) String() string {
+	if f.Transformed {
+		return fmt.Sprintf("%s: line %d of the config transformed by scripts: %s", f.Severity, f.Line, f.Message)
+	}
+	return fmt.Sprintf("%s: line %d: %s", f.Severity, f.Line, f.Message)
+}
+
+// 配置中定义的名称及其所在行号
+type lintName struct {
+	name string
+	line int
+}
+
+// 规则中引用规则集合的 RULE-SET,name
+var ruleSetPattern = regexp.MustCompile(`RULE-SET\s*,\s*([^,()]+)`)
+
+// 检查core配置中的引用错误，extraProxies 为注入到运行配置的节点名称
+// 检查代理组引用的节点、代理组和代理集合是否存在，规则的策略和规则集合是否存在，名称是否重复，节点是否未被使用，以及代理组是否循环引用
+func lintCoreConfig(doc *yaml.Node, extraProxies []string) []LintFinding {
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil
+	}
+	root := doc.Content[0]
+	var findings []LintFinding
+	report := func(severity string, line int, format string, args ...any) {
+		findings = append(findings, LintFinding{Severity: severity, Line: line, Message: fmt.Sprintf(format, args...)})
+	}
+
+	// 节点和代理组名称
+	defined := make(map[string]int)
+	var proxies, groups []lintName
+	for _, kind := range []struct {
+		key   string
+		names *[]lintName
+	}{{"proxies", &proxies}, {"proxy-groups", &groups}} {
+		node := mappingValue(root, kind.key, 0)
+		if node == nil || node.Kind != yaml.SequenceNode {
+			continue
+		}
+		for _, item := range node.Content {
+			name := mappingValue(item, "name", 0)
+			if name == nil || name.Value == "" {
+				report(LintError, item.Line, "%s entry without a name", kind.key)
+				continue
+			}
+			if line, ok := defined[name.Value]; ok {
+				report(LintError, name.Line, "duplicate name %q, first defined at line %d", name.Value, line)
+				continue
+			}
+			defined[name.Value] = name.Line
+			*kind.names = append(*kind.names, lintName{name.Value, name.Line})
+		}
+	}
+	isPolicy := func(name string) bool {
+		_, ok := defined[name]
+		return ok || slices.Contains(builtinPolicies, name) || slices.Contains(extraProxies, name)
+	}
+	used := make(map[string]bool)
+
+	// 节点通过 dialer-proxy 引用的前置节点或代理组
+	if node := mappingValue(root, "proxies", 0); node != nil && node.Kind == yaml.SequenceNode {
+		for _, item := range node.Content {
+			dialer := mappingValue(item, "dialer-proxy", 0)
+			if dialer == nil || dialer.Value == "" {
+				continue
+			}
+			used[dialer.Value] = true
+			if !isPolicy(dialer.Value) {
+				report(LintError, dialer.Line, "dialer-proxy references missing proxy or group %q", dialer.Value)
+			}
+		}
+	}
+	proxyProviders := mappingKeys(mappingValue(root, "proxy-providers", 0))
+	ruleProviders := mappingKeys(mappingValue(root, "rule-providers", 0))
+	subRules := mappingKeys(mappingValue(root, "sub-rules", 0))
+
+	// 代理组引用
+	includeAll := false
+	members := make(map[string][]string)
+	if node := mappingValue(root, "proxy-groups", 0); node != nil && node.Kind == yaml.SequenceNode {
+		for _, group := range node.Content {
+			name := mappingValue(group, "name", 0)
+			if name == nil {
+				continue
+			}
+			groupIncludeAll := isTrue(mappingValue(group, "include-all", 0)) || isTrue(mappingValue(group, "include-all-proxies", 0))
+			includeAll = includeAll || groupIncludeAll
+			count := 0
+			if list := mappingValue(group, "proxies", 0); list != nil && list.Kind == yaml.SequenceNode {
+				for _, member := range list.Content {
+					count++
+					used[member.Value] = true
+					if !isPolicy(member.Value) {
+						report(LintError, member.Line, "proxy group %q references missing proxy or group %q", name.Value, member.Value)
+						continue
+					}
+					members[name.Value] = append(members[name.Value], member.Value)
+				}
+			}
+			if list := mappingValue(group, "use", 0); list != nil && list.Kind == yaml.SequenceNode {
+				for _, provider := range list.Content {
+					count++
+					if _, ok := proxyProviders[provider.Value]; !ok {
+						report(LintError, provider.Line, "proxy group %q uses missing proxy provider %q", name.Value, provider.Value)
+					}
+				}
+			}
+			if count == 0 && !groupIncludeAll && !isTrue(mappingValue(group, "include-all-providers", 0)) {
+				report(LintError, name.Line, "proxy group %q has no proxies", name.Value)
+			}
+		}
+	}
+
+	// 规则引用
+	if node := mappingValue(root, "rules", 0); node != nil && node.Kind == yaml.SequenceNode {
+		for _, rule := range node.Content {
+			ruleType, policy := splitRulePolicy(rule.Value)
+			used[policy] = true
+			switch {
+			case policy == "":
+				report(LintError, rule.Line, "rule %q has no policy", rule.Value)
+			case ruleType == "SUB-RULE":
+				if _, ok := subRules[policy]; !ok {
+					report(LintError, rule.Line, "rule %q references missing sub-rule %q", rule.Value, policy)
+				}
+			case !isPolicy(policy):
+				report(LintError, rule.Line, "rule %q references missing policy %q", rule.Value, policy)
+			}
+			for _, match := range ruleSetPattern.FindAllStringSubmatch(rule.Value, -1) {
+				provider := strings.TrimSpace(match[1])
+				if _, ok := ruleProviders[provider]; !ok {
+					report(LintError, rule.Line, "rule %q references missing rule provider %q", rule.Value, provider)
+				}
+			}
+		}
+	}
+
+	// 未使用的节点，代理组包含全部节点时不检查
+	if !includeAll {
+		for _, proxy := range proxies {
+			if !used[proxy.name] {
+				report(LintWarning, proxy.line, "proxy %q is not used by any proxy group or rule", proxy.name)
+			}
+		}
+	}
+
+	// 代理组循环引用
+	for _, cycle := range findGroupCycles(groups, members) {
+		report(LintError, defined[cycle[0]], "proxy groups reference each other in a loop: %s", strings.Join(cycle, " -> "))
+	}
+
+	slices.SortStableFunc(findings, func(a, b LintFinding) int { return a.Line - b.Line })
+	return findings
+}
+
+// 映射节点的键及其所在行号
+func mappingKeys(node *yaml.Node) map[string]int {
+	keys := make(map[string]int)
+	if node == nil || node.Kind != yaml.MappingNode {
+		return keys
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		keys[node.Content[i].Value] = node.Content[i].Line
+	}
+	return keys
+}
+
+func isTrue(node *yaml.Node) bool {
+	return node != nil && node.Value == "true"
+}
+
+// 规则的类型和策略，如 AND,((DOMAIN,a.com),(DST-PORT,443)),PROXY,no-resolve 的策略为 PROXY，SUB-RULE 的策略为子规则名称
+func splitRulePolicy(rule string) (string, string) {
+	ruleType, rest, _ := strings.Cut(strings.TrimSpace(rule), ",")
+	ruleType = strings.ToUpper(strings.TrimSpace(ruleType))
+	switch ruleType {
+	case "MATCH":
+		return ruleType, strings.TrimSpace(rest)
+	case "AND", "OR", "NOT", "SUB-RULE":
+		// 括号中的规则内容
+		rest = strings.TrimSpace(rest)
+		end := closingParen(rest)
+		if end < 0 {
+			return ruleType, ""
+		}
+		rest = strings.TrimPrefix(strings.TrimSpace(rest[end+1:]), ",")
+	default:
+		_, rest, _ = strings.Cut(rest, ",")
+	}
+	policy, _, _ := strings.Cut(rest, ",")
+	return ruleType, strings.TrimSpace(policy)
+}
+
+// 查找代理组之间的循环引用，每个循环只返回一次，首尾为同一代理组
+func findGroupCycles(groups []lintName, members map[string][]string) [][]string {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int)
+	var cycles [][]string
+	var path []string
+	var visit func(name string)
+	visit = func(name string) {
+		state[name] = visiting
+		path = append(path, name)
+		for _, member := range members[name] {
+			switch state[member] {
+			case visiting:
+				start := slices.Index(path, member)
+				cycles = append(cycles, append(slices.Clone(path[start:]), member))
+			case unvisited:
+				if _, ok := members[member]; ok {
+					visit(member)
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		state[name] = visited
+	}
+	for _, group := range groups {
+		if state[group.name] == unvisited {
+			visit(group.name)
+		}
+	}
+	return cycles
+}
+
+// 将脚本转换后的配置中发现的问题对应到原配置中的同一问题，使用原配置的行号，原配置中没有的问题标记为转换后出现
+func locateFindings(findings, original []LintFinding) []LintFinding {
+	lines := make(map[LintFinding][]int)
+	for _, finding := range original {
+		key := LintFinding{Severity: finding.Severity, Message: finding.Message}
+		lines[key] = append(lines[key], finding.Line)
+	}
+	for i, finding := range findings {
+		key := LintFinding{Severity: finding.Severity, Message: finding.Message}
+		if queue := lines[key]; len(queue) > 0 {
+			findings[i].Line, lines[key] = queue[0], queue[1:]
+		} else {
+			findings[i].Transformed = true
+		}
+	}
+	slices.SortStableFunc(findings, func(a, b LintFinding) int {
+		if a.Transformed != b.Transformed {
+			if a.Transformed {
+				return 1
+			}
+			return -1
+		}
+		return a.Line - b.Line
+	})
+	return findings
+}
+
+// 检查配置文件 path 的内容 doc，original 为脚本转换前的内容，未经脚本转换时为空
+// 有错误时返回错误，只有警告时发送通知
+func checkCoreConfig(doc, original *yaml.Node, path string) error {
+	var imported []string
+	if proxies, err := loadImportedProxies(); err == nil {
+		for _, proxy := range proxies {
+			imported = append(imported, proxy.Name())
+		}
+	}
+	findings := lintCoreConfig(doc, imported)
+	if original != nil {
+		findings = locateFindings(findings, lintCoreConfig(original, imported))
+	}
+
+	var errs, warnings []string
+	for _, finding := range findings {
+		log.Println("Config check:", finding)
+		if finding.Severity == LintError {
+			errs = append(errs, finding.String())
+		} else {
+			warnings = append(warnings, finding.String())
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New(I.TranSys("msg.error.core.config.lint", map[string]any{
+			"Path":     path,
+			"Findings": strings.Join(previewLines("", errs), "\n"),
+		}))
+	}
+	if len(warnings) > 0 {
+		go sendNotification(I.TranSys("msg.info.config_lint", map[string]any{
+			"Count":    len(warnings),
+			"Findings": strings.Join(warnings[:min(len(warnings), 3)], "\n"),
+		}))
+	}
+	return nil
+}
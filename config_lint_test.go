@@ -0,0 +1,165 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.yaml.in/yaml/v3"
+)
+
+const testLintConfig = `proxies:
+  - {name: HK, type: ss, server: a, port: 1}
+  - {name: JP, type: ss, server: b, port: 1}
+  - {name: HK, type: ss, server: c, port: 1}
+  - {type: ss, server: d, port: 1}
+  - {name: Relay, type: ss, server: e, port: 1, dialer-proxy: Missing}
+  - {name: Chain, type: ss, server: f, port: 1, dialer-proxy: Proxy}
+proxy-providers:
+  sub: {type: http, url: http://example.com}
+proxy-groups:
+  - name: Proxy
+    type: select
+    proxies: [HK, Auto, DIRECT, Imported, US]
+  - name: Auto
+    type: url-test
+    proxies: [Fallback]
+    use: [sub, other]
+  - name: Fallback
+    type: fallback
+    proxies: [Auto]
+  - name: Empty
+    type: select
+rule-providers:
+  ads: {type: http, behavior: domain, url: http://example.com}
+sub-rules:
+  inner: ['MATCH,DIRECT']
+rules:
+  - RULE-SET,ads,REJECT
+  - RULE-SET,trackers,REJECT
+  - DOMAIN,a.com
+  - DOMAIN,b.com,Missing
+  - SUB-RULE,(NETWORK,tcp),inner
+  - SUB-RULE,(NETWORK,udp),outer
+  - AND,((DOMAIN,c.com),(RULE-SET,ads)),Proxy
+  - MATCH,Proxy
+`
+
+func lintTestConfig(t *testing.T, config string, extraProxies []string) []LintFinding {
+	t.Helper()
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(config), &doc); err != nil {
+		t.Fatal(err)
+	}
+	return lintCoreConfig(&doc, extraProxies)
+}
+
+func TestLintCoreConfig(t *testing.T) {
+	findings := lintTestConfig(t, testLintConfig, []string{"Imported"})
+	want := []struct {
+		severity string
+		line     int
+		message  string
+	}{
+		{LintWarning, 3, `proxy "JP" is not used`},
+		{LintError, 4, `duplicate name "HK", first defined at line 2`},
+		{LintError, 5, "proxies entry without a name"},
+		{LintError, 6, `dialer-proxy references missing proxy or group "Missing"`},
+		{LintWarning, 6, `proxy "Relay" is not used`},
+		{LintWarning, 7, `proxy "Chain" is not used`},
+		{LintError, 13, `proxy group "Proxy" references missing proxy or group "US"`},
+		{LintError, 14, "in a loop: Auto -> Fallback -> Auto"},
+		{LintError, 17, `proxy group "Auto" uses missing proxy provider "other"`},
+		{LintError, 21, `proxy group "Empty" has no proxies`},
+		{LintError, 29, `references missing rule provider "trackers"`},
+		{LintError, 30, `rule "DOMAIN,a.com" has no policy`},
+		{LintError, 31, `references missing policy "Missing"`},
+		{LintError, 33, `references missing sub-rule "outer"`},
+	}
+	if len(findings) != len(want) {
+		t.Fatalf("got %d findings, want %d:\n%v", len(findings), len(want), findings)
+	}
+	for i, w := range want {
+		f := findings[i]
+		if f.Severity != w.severity || f.Line != w.line || !strings.Contains(f.Message, w.message) {
+			t.Errorf("finding %d = %v, want %s: line %d: %s", i, f, w.severity, w.line, w.message)
+		}
+	}
+
+	// 注入的节点名称未提供时视为缺失
+	findings = lintTestConfig(t, testLintConfig, nil)
+	if !strings.Contains(findings[6].Message, `"Imported"`) || findings[6].Line != 13 {
+		t.Errorf("finding 6 = %v, want missing Imported", findings[6])
+	}
+}
+
+func TestLintCoreConfigIncludeAll(t *testing.T) {
+	config := `proxies:
+  - {name: HK, type: ss, server: a, port: 1}
+proxy-groups:
+  - {name: All, type: select, include-all: true}
+  - {name: Providers, type: select, include-all-providers: true}
+rules:
+  - MATCH,All
+`
+	// 包含全部节点时不检查未使用的节点，也不要求代理组列出节点
+	if findings := lintTestConfig(t, config, nil); len(findings) != 0 {
+		t.Fatalf("findings = %v", findings)
+	}
+	// 不是映射的文档不检查
+	if findings := lintTestConfig(t, "- a\n- b\n", nil); len(findings) != 0 {
+		t.Fatalf("findings = %v", findings)
+	}
+}
+
+func TestFindGroupCycles(t *testing.T) {
+	groups := []lintName{{"A", 1}, {"B", 2}, {"C", 3}, {"D", 4}, {"E", 5}}
+	members := map[string][]string{
+		"A": {"B", "HK"},
+		"B": {"C"},
+		"C": {"A", "DIRECT"},
+		"D": {"D"},
+		"E": {"B"},
+	}
+	// 每个循环只返回一次，引用循环中代理组的其他代理组不算循环
+	want := [][]string{{"A", "B", "C", "A"}, {"D", "D"}}
+	if cycles := findGroupCycles(groups, members); !reflect.DeepEqual(cycles, want) {
+		t.Fatalf("cycles = %v, want %v", cycles, want)
+	}
+	if cycles := findGroupCycles(groups[:2], map[string][]string{"A": {"B"}, "B": {"HK"}}); len(cycles) != 0 {
+		t.Fatalf("cycles = %v", cycles)
+	}
+}
+
+func TestLocateFindings(t *testing.T) {
+	original := lintTestConfig(t, `proxy-groups:
+  - name: Proxy
+    type: select
+    proxies: [HK]
+rules:
+  - DOMAIN,a.com,Missing
+  - DOMAIN,b.com,Missing
+  - MATCH,Proxy
+`, nil)
+	// 脚本转换后的内容顺序和行号不同，修复了一个问题并引入了新问题
+	transformed := lintTestConfig(t, `rules:
+  - DOMAIN,b.com,Missing
+  - DOMAIN,c.com,Added
+  - MATCH,Proxy
+proxy-groups:
+  - {name: Proxy, type: select, proxies: [HK, DIRECT]}
+`, nil)
+	findings := locateFindings(transformed, original)
+	want := []string{
+		`error: line 4: proxy group "Proxy" references missing proxy or group "HK"`,
+		`error: line 7: rule "DOMAIN,b.com,Missing" references missing policy "Missing"`,
+		`error: line 3 of the config transformed by scripts: rule "DOMAIN,c.com,Added" references missing policy "Added"`,
+	}
+	var got []string
+	for _, finding := range findings {
+		got = append(got, finding.String())
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("findings = %q, want %q", got, want)
+	}
+}
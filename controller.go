@@ -0,0 +1,70 @@
+package main
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// 外部控制器请求客户端，直连本地不走代理
+var controllerClient = &http.Client{
+	Timeout: 30 * time.Second,
+	Transport: &http.Transport{
+		Proxy: nil,
+		// 本地 https 控制器通常使用自签名证书
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	},
+}
+
+// 请求core外部控制器API，path 为 /configs 这样的接口路径
+func controllerRequest(method, path string, body io.Reader) (*http.Response, error) {
+	config := getCoreConfig()
+	if config.ControllerAddr == "" {
+		return nil, fmt.Errorf("external-controller is not configured")
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	scheme := "http"
+	if config.ControllerTLS {
+		scheme = "https"
+	}
+	req, err := http.NewRequest(method, fmt.Sprintf("%s://%s%s", scheme, config.ControllerAddr, path), body)
+	if err != nil {
+		return nil, err
+	}
+	if config.Secret != "" {
+		req.Header.Set("Authorization", "Bearer "+config.Secret)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := controllerClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("%s %s: %s %s", method, path, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return resp, nil
+}
+
+// 请求core外部控制器API并将返回的json解析到 out，out 为 nil 时忽略返回内容
+func controllerJSON(method, path string, body io.Reader, out any) error {
+	resp, err := controllerRequest(method, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -1,34 +1,51 @@
 package main
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"log"
 	"net"
+	"net/netip"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
 
 	"github.com/spf13/viper"
+	"go.yaml.in/yaml/v3"
 )
 
 // CoreConfig core配置信息
 type CoreConfig struct {
 	// 本程序需要的一些配置字段
-	Port               int
-	MixedPort          int
-	ExternalController string
-	Secret             string
-	ExternalUi         string
-	ExternalUiName     string
+	Port                  int
+	SocksPort             int
+	RedirPort             int
+	MixedPort             int
+	AllowLan              bool
+	BindAddress           string
+	Authentication        []string
+	SkipAuthPrefixes      []string
+	ExternalController    string
+	ExternalControllerTLS string
+	Secret                string
+	ExternalUi            string
+	ExternalUiName        string
+	GeoxUrl               map[string]string
 
 	// 额外自定义字段，不在yaml配置文件中
-	ExternalUiAddr  string // 外部ui地址
-	OfficialUiAddr  string // 官方ui地址
-	YACDUiAddr      string // Yet Another Clash Dashboard ui地址
-	ZashBoardUiAddr string // zashboard ui地址
-	HttpProxyPort   int    // http代理端口
+	ControllerHost string // 本机访问外部控制器的主机
+	ControllerPort string // 外部控制器端口
+	ControllerTLS  bool   // 外部控制器是否使用 https
+	ControllerAddr string // 本机访问外部控制器的地址
+	UiPath         string // 外部ui路径，未配置外部ui时为空
+	HttpProxyPort  int    // http代理端口
+	SocksProxyPort int    // socks代理端口
+	ProxyHost      string // 本机访问代理的地址
 }
 
 var (
@@ -73,39 +90,49 @@ func initCore() {
 		return nil
 	})
 	if corePath == "" {
-		fatal(I.TranSys("msg.error.core.not_found", map[string]any{"Dir": workDir}))
-	} else {
-		// 获取core文件名
-		coreName = filepath.Base(corePath)
+		// 没有core时询问是否下载最新版本
+		if !messageBoxConfirm(AppName, I.TranSys("msg.info.core_download", map[string]any{"Dir": workDir})) {
+			fatal(I.TranSys("msg.error.core.not_found", map[string]any{"Dir": workDir}))
+		}
+		path, err := installCore()
+		if err != nil {
+			fatal(I.TranSys("msg.error.core.download_failed", map[string]any{"Error": err}))
+		}
+		corePath = path
+		log.Println("Downloaded core:", corePath)
 	}
+	// 获取core文件名
+	coreName = filepath.Base(corePath)
 
 	// 运行配置文件路径
 	coreRunConfigPath = filepath.Join(coreDir, "config.auto-gen")
-	// 配置文件搜索路径
-	var configSearchPaths = []string{
-		filepath.Join(workDir, "config.yaml"),
-		filepath.Join(workDir, "config.yml"),
-		filepath.Join(coreDir, "config.yaml"),
-		filepath.Join(coreDir, "config.yml"),
+	// 生成配置文件
+	if err := syncProfileGenerators(false); err != nil {
+		log.Println("Failed to sync profile generators:", err)
 	}
-	for _, path := range configSearchPaths {
-		if isFileExist(path) {
-			coreConfigPath = path
-			break
-		}
+	// 优先使用选择的配置文件
+	coreConfigPath = getProfileStore().ActivePath()
+	if !isFileExist(coreConfigPath) {
+		coreConfigPath = findCoreConfigPath()
 	}
 	if !isFileExist(coreConfigPath) {
-		fatal(I.TranSys("msg.error.core.config.not_found", map[string]any{
-			"Dir1": workDir,
-			"Dir2": coreDir,
-		}))
+		// 首次运行没有配置文件，引导创建
+		path, err := runConfigWizard()
+		if err != nil {
+			fatal(I.TranSys("msg.error.wizard.failed", map[string]any{"Error": err}))
+		}
+		if path == "" {
+			fatal(I.TranSys("msg.error.core.config.not_found", map[string]any{
+				"Dir1": workDir,
+				"Dir2": coreDir,
+			}))
+		}
+		coreConfigPath = path
 	}
 
 	// 初始化配置对象
 	coreConfig.Store(&CoreConfig{})
 
-	coreConfigViper = viper.New()
-	coreConfigViper.SetConfigFile(coreConfigPath)
 	// 加载核心配置
 	if err := loadCoreConfig(); err != nil {
 		fatal(err)
@@ -114,62 +141,110 @@ func initCore() {
 	// 初始化日志输出
 	coreLogWriter = NewSwitchWriter(log.Writer(), getAppConfig().CoreLogEnabled)
 
+	if isGeoDataMissing() {
+		// 提前下载缺失的geodata，避免core首次启动时下载缓慢
+		_ = updateGeoDataAndNotify(false, false)
+	}
+
 	if startCore() {
 		// 设置系统代理
 		setCoreProxy()
+		// 检查外部ui
+		go checkExternalUi()
+		// 定时更新geodata
+		go runGeoDataUpdater()
+		// 定时同步订阅流量信息
+		go runSubscriptionMonitor()
+		// 定时更新订阅配置文件
+		go runProfileUpdater()
 	} else {
 		fatal(I.TranSys("msg.error.core.start_failed", nil))
 	}
 }
 
-// 加载配置文件
-func loadCoreConfig() error {
-	if err := coreConfigViper.ReadInConfig(); err != nil {
-		return fmt.Errorf(I.TranSys("msg.error.core.config.read_failed", map[string]any{"Error": err}))
+// 查找默认的配置文件，不存在时返回空
+func findCoreConfigPath() string {
+	// 配置文件搜索路径
+	var configSearchPaths = []string{
+		filepath.Join(workDir, "config.yaml"),
+		filepath.Join(workDir, "config.yml"),
+		filepath.Join(coreDir, "config.yaml"),
+		filepath.Join(coreDir, "config.yml"),
+	}
+	for _, path := range configSearchPaths {
+		if isFileExist(path) {
+			return path
+		}
 	}
+	return ""
+}
 
-	// 读取配置到临时配置对象
-	tempConfig := new(CoreConfig)
+// 命令行使用的配置文件，优先使用选择的配置文件，都不存在时返回错误
+func findActiveCoreConfigPath() (string, error) {
+	coreDir = filepath.Join(workDir, "core")
+	path := getProfileStore().ActivePath()
+	if !isFileExist(path) {
+		path = findCoreConfigPath()
+	}
+	if path == "" {
+		return "", errors.New(I.TranSys("msg.error.core.config.not_found", map[string]any{"Dir1": workDir, "Dir2": coreDir}))
+	}
+	return path, nil
+}
 
-	if mixedPort := coreConfigViper.GetInt("mixed-port"); mixedPort != 0 {
-		tempConfig.MixedPort = mixedPort
-		tempConfig.HttpProxyPort = mixedPort
-	} else if port := coreConfigViper.GetInt("port"); port != 0 {
-		tempConfig.Port = port
-		tempConfig.HttpProxyPort = port
+// 加载配置文件
+func loadCoreConfig() error {
+	// 订阅下载和生成的配置文件内容来自订阅服务，只替换本地配置文件中的占位符
+	profileName := ""
+	expand := true
+	if store := getProfileStore(); store.ActivePath() == coreConfigPath {
+		profileName = store.ActiveName()
+		if profile := store.Get(profileName); profile != nil {
+			expand = profile.Type == ProfileTypeLocal
+		}
+	}
+	data, err := os.ReadFile(coreConfigPath)
+	if err != nil {
+		return errors.New(I.TranSys("msg.error.core.config.read_failed", map[string]any{"Error": err}))
+	}
+	// 运行配置转换脚本，脚本在替换占位符前运行，输出的日志中不会包含替换后的值
+	transformed, err := transformCoreConfig(data, profileName)
+	if err != nil {
+		return errors.New(I.TranSys("msg.error.core.config.script", map[string]any{"Error": err}))
 	}
-	if tempConfig.HttpProxyPort == 0 {
-		return fmt.Errorf(I.TranSys("msg.error.core.config.missing_port", nil))
+	// 检查转换后的配置并替换占位符
+	if data, err = prepareCoreConfig(data, transformed, coreConfigPath, expand); err != nil {
+		return err
+	}
+	// 每次加载都使用新的解析器，避免上一次注入到运行配置的值残留
+	v := viper.New()
+	v.SetConfigType("yaml")
+	if err := v.ReadConfig(bytes.NewReader(data)); err != nil {
+		return errors.New(I.TranSys("msg.error.core.config.read_failed", map[string]any{"Error": err}))
 	}
 
-	tempConfig.ExternalController = coreConfigViper.GetString("external-controller")
-	tempConfig.Secret = coreConfigViper.GetString("secret")
-	tempConfig.ExternalUi = coreConfigViper.GetString("external-ui")
-	tempConfig.ExternalUiName = coreConfigViper.GetString("external-ui-name")
+	// 读取配置到临时配置对象
+	tempConfig, err := parseCoreConfig(v)
+	if err != nil {
+		return err
+	}
 
-	if host, port, err := net.SplitHostPort(tempConfig.ExternalController); err == nil && tempConfig.ExternalUi != "" {
-		// 需要配置了外部控制器API和外部用户UI时才能使用控制面板
-		uiUrlPath := "/ui"
-		if tempConfig.ExternalUiName != "" {
-			// 去除开头/末尾的斜杠
-			uiUrlPath += "/" + strings.Trim(tempConfig.ExternalUiName, "/")
+	if len(tempConfig.Authentication) > 0 {
+		// 开启了认证，系统代理无法携带认证信息，需要放行本机地址
+		if prefixes, changed := ensureSkipAuthPrefixes(tempConfig.SkipAuthPrefixes, tempConfig.ProxyHost); changed {
+			log.Println("Authentication is enabled, inject skip-auth-prefixes into running config:", prefixes)
+			tempConfig.SkipAuthPrefixes = prefixes
+			v.Set("skip-auth-prefixes", prefixes)
 		}
-		if host == "" || host == "0.0.0.0" || host == "::" {
-			// 形如 :9090 的格式，监听的是所有地址，管理面板就默认使用本地地址
-			host = "127.0.0.1"
-		}
-		// 本地面板地址
-		tempConfig.ExternalUiAddr = fmt.Sprintf("http://%s%s/#/setup?http=true&hostname=%s&port=%s&secret=%s",
-			net.JoinHostPort(host, port), uiUrlPath, host, port, tempConfig.Secret)
-		// 官方面板地址
-		tempConfig.OfficialUiAddr = fmt.Sprintf("https://metacubex.github.io/metacubexd/#/setup?http=true&hostname=%s&port=%s&secret=%s",
-			host, port, tempConfig.Secret)
-		// Yet Another Clash Dashboard
-		tempConfig.YACDUiAddr = fmt.Sprintf("https://yacd.metacubex.one/?hostname=%s&port=%s&secret=%s",
-			host, port, tempConfig.Secret)
-		// zashboard
-		tempConfig.ZashBoardUiAddr = fmt.Sprintf("https://board.zash.run.place/#/setup?http=true&hostname=%s&port=%s&secret=%s",
-			host, port, tempConfig.Secret)
+	}
+
+	// 注入从分享链接导入的节点
+	if err = injectImportedProxies(v); err != nil {
+		return err
+	}
+	// 注入用户规则，在导入节点之后注入，规则可以使用导入的节点和代理组
+	if err = injectUserRules(v); err != nil {
+		return err
 	}
 
 	// 保存到运行配置文件
@@ -180,20 +255,141 @@ func loadCoreConfig() error {
 		}
 		defer f.Close()
 
-		if err = coreConfigViper.WriteConfigTo(f); err != nil {
+		if err = v.WriteConfigTo(f); err != nil {
 			return err
 		}
 		return f.Sync()
 	}(); err != nil {
-		return fmt.Errorf(I.TranSys("msg.error.core.config.write_running_failed", map[string]any{"Error": err}))
+		return errors.New(I.TranSys("msg.error.core.config.write_running_failed", map[string]any{"Error": err}))
 	}
 
 	// 配置解析校验成功，临时配置提交给正式配置
+	coreConfigViper = v
 	coreConfig.Store(tempConfig)
 	log.Println("Core config loaded:", coreConfigPath)
 	return nil
 }
 
+// 检查配置文件 path 的内容 source 经脚本转换后的内容 data 中的引用错误，再替换占位符，返回用于生成运行配置的内容，
+// expand 为 false 时不替换。检查在替换前进行，发现的问题中不会包含替换后的值
+// 脚本转换了配置时，与原配置中相同的问题使用原配置的行号，其他问题和占位符错误说明行号对应转换后的内容
+func prepareCoreConfig(source, data []byte, path string, expand bool) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, errors.New(I.TranSys("msg.error.core.config.read_failed", map[string]any{"Error": err}))
+	}
+	var original *yaml.Node
+	if !bytes.Equal(source, data) {
+		original = &yaml.Node{}
+		if err := yaml.Unmarshal(source, original); err != nil {
+			return nil, errors.New(I.TranSys("msg.error.core.config.read_failed", map[string]any{"Error": err}))
+		}
+	}
+	if err := checkCoreConfig(&doc, original, path); err != nil {
+		return nil, err
+	}
+	if !expand {
+		return data, nil
+	}
+	changed, err := expandNodePlaceholders(&doc)
+	if err != nil {
+		key := "msg.error.core.config.placeholder"
+		if original != nil {
+			key = "msg.error.core.config.placeholder_transformed"
+		}
+		return nil, errors.New(I.TranSys(key, map[string]any{"Path": path, "Error": err}))
+	}
+	if !changed {
+		return data, nil
+	}
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err = encoder.Encode(&doc); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), encoder.Close()
+}
+
+// 读取core正在使用的运行配置，供命令行子命令访问正在运行的core
+func loadRunningCoreConfig() error {
+	coreDir = filepath.Join(workDir, "core")
+	coreRunConfigPath = filepath.Join(coreDir, "config.auto-gen")
+
+	v := viper.New()
+	v.SetConfigFile(coreRunConfigPath)
+	v.SetConfigType("yaml")
+	if err := v.ReadInConfig(); err != nil {
+		return errors.New(I.TranSys("msg.error.core.config.read_failed", map[string]any{"Error": err}))
+	}
+	config, err := parseCoreConfig(v)
+	if err != nil {
+		return err
+	}
+	coreConfigViper = v
+	coreConfig.Store(config)
+	return nil
+}
+
+// 从配置解析器中读取本程序需要的配置字段
+func parseCoreConfig(v *viper.Viper) (*CoreConfig, error) {
+	tempConfig := new(CoreConfig)
+
+	tempConfig.Port = v.GetInt("port")
+	tempConfig.SocksPort = v.GetInt("socks-port")
+	tempConfig.RedirPort = v.GetInt("redir-port")
+	tempConfig.MixedPort = v.GetInt("mixed-port")
+	tempConfig.AllowLan = v.GetBool("allow-lan")
+	tempConfig.BindAddress = v.GetString("bind-address")
+	tempConfig.Authentication = v.GetStringSlice("authentication")
+	tempConfig.SkipAuthPrefixes = v.GetStringSlice("skip-auth-prefixes")
+	tempConfig.ProxyHost = resolveProxyHost(tempConfig.AllowLan, tempConfig.BindAddress)
+	// 混合端口同时支持 http 和 socks，优先使用
+	if tempConfig.MixedPort != 0 {
+		tempConfig.HttpProxyPort = tempConfig.MixedPort
+		tempConfig.SocksProxyPort = tempConfig.MixedPort
+	} else {
+		tempConfig.HttpProxyPort = tempConfig.Port
+		tempConfig.SocksProxyPort = tempConfig.SocksPort
+	}
+	if tempConfig.HttpProxyPort == 0 && tempConfig.SocksProxyPort == 0 {
+		return nil, errors.New(I.TranSys("msg.error.core.config.missing_port", nil))
+	}
+
+	tempConfig.ExternalController = v.GetString("external-controller")
+	tempConfig.ExternalControllerTLS = v.GetString("external-controller-tls")
+	tempConfig.Secret = v.GetString("secret")
+	tempConfig.ExternalUi = v.GetString("external-ui")
+	tempConfig.ExternalUiName = v.GetString("external-ui-name")
+	tempConfig.GeoxUrl = v.GetStringMapString("geox-url")
+
+	// 外部控制器地址，未配置 http 控制器时使用 https 控制器
+	controller := tempConfig.ExternalController
+	if controller == "" && tempConfig.ExternalControllerTLS != "" {
+		controller = tempConfig.ExternalControllerTLS
+		tempConfig.ControllerTLS = true
+	}
+	if host, port, err := net.SplitHostPort(controller); err == nil {
+		if host == "" || host == "0.0.0.0" || host == "::" {
+			// 形如 :9090 的格式，监听的是所有地址，使用本地地址访问
+			host = "127.0.0.1"
+		}
+		tempConfig.ControllerHost = host
+		tempConfig.ControllerPort = port
+		tempConfig.ControllerAddr = net.JoinHostPort(host, port)
+		if tempConfig.ExternalUi != "" {
+			// 配置了外部用户UI时才能使用本地控制面板
+			tempConfig.UiPath = "/ui"
+			if tempConfig.ExternalUiName != "" {
+				// 去除开头/末尾的斜杠
+				tempConfig.UiPath += "/" + strings.Trim(tempConfig.ExternalUiName, "/")
+			}
+		}
+	}
+
+	return tempConfig, nil
+}
+
 // 启动core程序
 func startCore() bool {
 	coreMutex.Lock()
@@ -255,23 +451,156 @@ func isCoreRunning() bool {
 
 // 设置系统代理为core配置的代理
 func setCoreProxy() bool {
-	set := setProxy(true, "127.0.0.1", fmt.Sprintf("%d", getCoreConfig().HttpProxyPort), strings.Join(getAppConfig().ProxyByPass, ";"))
+	servers := getCoreProxyServers()
+	set := setProxy(true, servers, strings.Join(getCoreProxyBypass(), ";"))
 	if set {
-		proxyUrl := fmt.Sprintf("http://%s", getProxyServer())
 		// 设置环境变量
-		_ = os.Setenv("HTTP_PROXY", proxyUrl)
-		_ = os.Setenv("HTTPS_PROXY", proxyUrl)
+		for key, value := range getCoreProxyEnv() {
+			_ = os.Setenv(key, value)
+		}
+		// 同步代理到开发工具
+		syncToolProxy()
 	} else {
 		// 恢复环境变量
-		_ = os.Unsetenv("HTTP_PROXY")
-		_ = os.Unsetenv("HTTPS_PROXY")
+		for _, key := range proxyEnvKeys {
+			_ = os.Unsetenv(key)
+		}
 	}
 	return set
 }
 
+// 获取系统代理需要设置的各协议代理地址
+func getCoreProxyServers() map[string]string {
+	config := getCoreConfig()
+	servers := make(map[string]string)
+	for _, protocol := range getAppConfig().ProxyProtocols {
+		port := 0
+		switch strings.ToLower(protocol) {
+		case ProxyProtocolHttp, ProxyProtocolHttps:
+			port = config.HttpProxyPort
+		case ProxyProtocolSocks:
+			port = config.SocksProxyPort
+		default:
+			log.Println("Unsupported proxy protocol:", protocol)
+			continue
+		}
+		if port == 0 {
+			log.Println("No core port available for proxy protocol:", protocol)
+			continue
+		}
+		servers[strings.ToLower(protocol)] = net.JoinHostPort(config.ProxyHost, strconv.Itoa(port))
+	}
+	return servers
+}
+
+// 获取core代理对应的环境变量
+func getCoreProxyEnv() map[string]string {
+	config := getCoreConfig()
+	env := make(map[string]string)
+	if config.HttpProxyPort != 0 {
+		proxyUrl := fmt.Sprintf("http://%s", net.JoinHostPort(config.ProxyHost, strconv.Itoa(config.HttpProxyPort)))
+		env["HTTP_PROXY"] = proxyUrl
+		env["HTTPS_PROXY"] = proxyUrl
+	}
+	if config.SocksProxyPort != 0 {
+		env["ALL_PROXY"] = fmt.Sprintf("socks5://%s", net.JoinHostPort(config.ProxyHost, strconv.Itoa(config.SocksProxyPort)))
+	} else if proxyUrl, ok := env["HTTP_PROXY"]; ok {
+		env["ALL_PROXY"] = proxyUrl
+	}
+	if noProxy := bypassToNoProxy(getCoreProxyBypass()); noProxy != "" {
+		env["NO_PROXY"] = noProxy
+	}
+	return env
+}
+
+// 为子进程构建环境变量：移除继承的代理变量，core运行时写入大小写两种形式的代理变量
+func proxyEnviron(base []string) []string {
+	env := make([]string, 0, len(base)+len(proxyEnvKeys)*2)
+	for _, kv := range base {
+		key, _, _ := strings.Cut(kv, "=")
+		if slices.Contains(proxyEnvKeys, strings.ToUpper(key)) {
+			continue
+		}
+		env = append(env, kv)
+	}
+	if !isCoreRunning() {
+		return env
+	}
+	for key, value := range getCoreProxyEnv() {
+		// Windows 下环境变量名不区分大小写，重复的变量会被合并，保留小写形式供 curl 等工具读取
+		env = append(env, key+"="+value, strings.ToLower(key)+"="+value)
+	}
+	return env
+}
+
+// 获取外部ui根目录，与core一致，相对路径基于core工作目录，未配置时返回空
+func getExternalUiDir() string {
+	uiDir := getCoreConfig().ExternalUi
+	if uiDir == "" {
+		return ""
+	}
+	if !filepath.IsAbs(uiDir) {
+		uiDir = filepath.Join(coreDir, uiDir)
+	}
+	return uiDir
+}
+
+// 根据 allow-lan 和 bind-address 计算本机访问代理的地址
+func resolveProxyHost(allowLan bool, bindAddress string) string {
+	if !allowLan {
+		// 未允许局域网连接时，core只监听本地回环地址
+		return "127.0.0.1"
+	}
+	bindAddress = strings.Trim(strings.TrimSpace(bindAddress), "[]")
+	if bindAddress == "" || bindAddress == "*" {
+		return "127.0.0.1"
+	}
+	ip, err := netip.ParseAddr(bindAddress)
+	if err != nil {
+		// 非IP地址（如网卡名称）无法直接使用，回退到本地地址
+		return "127.0.0.1"
+	}
+	if ip.IsUnspecified() {
+		// 监听所有地址（:: 为双栈监听），使用本地地址
+		return "127.0.0.1"
+	}
+	return ip.Unmap().String()
+}
+
+// 确保认证放行列表中包含本机回环地址及代理地址，返回新的列表以及是否有修改
+func ensureSkipAuthPrefixes(prefixes []string, proxyHost string) ([]string, bool) {
+	required := []string{"127.0.0.1/8", "::1/128"}
+	if ip, err := netip.ParseAddr(proxyHost); err == nil && !ip.IsLoopback() {
+		required = append(required, netip.PrefixFrom(ip, ip.BitLen()).String())
+	}
+
+	result := slices.Clone(prefixes)
+	changed := false
+	for _, req := range required {
+		addr := netip.MustParsePrefix(req).Addr()
+		covered := false
+		for _, p := range prefixes {
+			if prefix, err := netip.ParsePrefix(strings.TrimSpace(p)); err == nil && prefix.Contains(addr) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			result = append(result, req)
+			changed = true
+		}
+	}
+	return result, changed
+}
+
 // 获取core版本号
 func getCoreVersion() string {
-	if output, err := execCommand(corePath, "-v").Output(); err == nil {
+	return coreBinaryVersion(corePath)
+}
+
+// 获取指定core程序的版本号
+func coreBinaryVersion(path string) string {
+	if output, err := execCommand(path, "-v").Output(); err == nil {
 		fields := strings.Fields(string(output))
 		if len(fields) >= 3 && fields[0] == CoreShowName {
 			return fields[2]
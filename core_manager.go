@@ -0,0 +1,314 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/cpu"
+)
+
+const (
+	defaultCoreRepo  = "MetaCubeX/mihomo" // 默认的core发布仓库
+	coreReadyTimeout = 15 * time.Second   // 等待core就绪的超时时间
+)
+
+// 发布中可能包含的校验文件名称
+var coreChecksumAssets = []string{"checksums.txt", "sha256sums.txt", "SHA256SUMS"}
+
+// CoreRelease 解析出的core发布附件
+type CoreRelease struct {
+	Version string
+	Asset   string
+	Url     string
+	Sha256  string
+}
+
+// CoreManager core程序管理器，负责下载、校验和替换core程序
+type CoreManager struct {
+	Client  *http.Client
+	ApiBase string // GitHub API 地址，为空时使用默认地址
+	Repo    string // 发布仓库
+	Level   string // amd64 构建级别 v1、v2、v3，为空时自动检测
+	Path    string // core程序路径
+
+	Stop    func() bool              // 停止core
+	Start   func() bool              // 启动core
+	Ready   func() error             // 等待core就绪
+	Version func(path string) string // 获取core程序版本，不能运行时返回空
+}
+
+// NewCoreManager 创建core程序管理器，使用当前的core启停方法
+func NewCoreManager(config CoreReleaseConfig, path string) *CoreManager {
+	return &CoreManager{
+		Client:  downloadClient,
+		ApiBase: config.ApiBase,
+		Repo:    config.Repo,
+		Level:   config.Level,
+		Path:    path,
+		Stop:    stopCore,
+		Start:   startCore,
+		Ready:   waitCoreReady,
+		Version: coreBinaryVersion,
+	}
+}
+
+// Latest 获取适合当前系统的最新core发布附件
+func (m *CoreManager) Latest() (*CoreRelease, error) {
+	repo := m.Repo
+	if repo == "" {
+		repo = defaultCoreRepo
+	}
+	release, err := fetchGitHubRelease(m.Client, m.ApiBase, repo, "")
+	if err != nil {
+		return nil, err
+	}
+
+	level := m.Level
+	if level == "" {
+		level = cpuLevel()
+	}
+	names := coreAssetNames(runtime.GOOS, runtime.GOARCH, level, release.TagName)
+	var asset *GitHubReleaseAsset
+	for _, name := range names {
+		if asset = release.Asset(name); asset != nil {
+			break
+		}
+	}
+	if asset == nil {
+		return nil, fmt.Errorf("no core asset for %s/%s (%s) in %s %s", runtime.GOOS, runtime.GOARCH, level, repo, release.TagName)
+	}
+
+	sum := asset.Sha256()
+	if sum == "" {
+		// 没有附件摘要时从发布的校验文件中查找
+		if sum, err = m.findChecksum(release, asset.Name); err != nil {
+			return nil, err
+		}
+	}
+	return &CoreRelease{
+		Version: release.TagName,
+		Asset:   asset.Name,
+		Url:     asset.BrowserDownloadUrl,
+		Sha256:  sum,
+	}, nil
+}
+
+// 从发布的校验文件中查找附件的 sha256 摘要
+func (m *CoreManager) findChecksum(release *GitHubRelease, name string) (string, error) {
+	candidates := append([]string{name + ".sha256"}, coreChecksumAssets...)
+	for _, candidate := range candidates {
+		asset := release.Asset(candidate)
+		if asset == nil {
+			continue
+		}
+		resp, err := m.Client.Get(asset.BrowserDownloadUrl)
+		if err != nil {
+			return "", err
+		}
+		content, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+		_ = resp.Body.Close()
+		if err != nil {
+			return "", err
+		}
+		if resp.StatusCode != http.StatusOK {
+			return "", fmt.Errorf("download %s: %s", asset.Name, resp.Status)
+		}
+		if sum := findChecksum(string(content), name); sum != "" {
+			return sum, nil
+		}
+	}
+	return "", fmt.Errorf("no checksum published for %s in %s", name, release.TagName)
+}
+
+// Download 下载并校验core发布附件，解压出core程序到 dst
+func (m *CoreManager) Download(release *CoreRelease, dst string) error {
+	archive := dst + ".download-" + release.Asset
+	defer os.Remove(archive)
+	if _, err := downloadFile(m.Client, release.Url, archive, release.Sha256); err != nil {
+		return err
+	}
+
+	tempDir := dst + ".extract"
+	_ = os.RemoveAll(tempDir)
+	defer os.RemoveAll(tempDir)
+	if err := extractArchive(archive, tempDir); err != nil {
+		return err
+	}
+	binary, err := findCoreBinary(tempDir)
+	if err != nil {
+		return err
+	}
+	// 确认程序可以运行
+	if v := m.Version(binary); v == "" {
+		return fmt.Errorf("downloaded core %s is not runnable", release.Asset)
+	}
+	_ = os.Remove(dst)
+	return os.Rename(binary, dst)
+}
+
+// Update 更新core程序到最新版本，force 为 false 时已是最新版本则跳过，返回最新发布以及是否有更新
+// 替换前停止core，新版本启动失败或未就绪时恢复旧版本
+func (m *CoreManager) Update(force bool) (*CoreRelease, bool, error) {
+	release, err := m.Latest()
+	if err != nil {
+		return nil, false, err
+	}
+	if !force && m.Version(m.Path) == release.Version {
+		return release, false, nil
+	}
+
+	newPath := m.Path + ".new"
+	defer os.Remove(newPath)
+	if err = m.Download(release, newPath); err != nil {
+		return nil, false, err
+	}
+	if err = m.swap(newPath); err != nil {
+		return nil, false, err
+	}
+	return release, true, nil
+}
+
+// 停止core后使用新程序替换，旧程序保留为 .old 文件用于回滚
+func (m *CoreManager) swap(newPath string) error {
+	backup := m.Path + ".old"
+	if !m.Stop() {
+		return fmt.Errorf("failed to stop core")
+	}
+	_ = os.Remove(backup)
+	if err := os.Rename(m.Path, backup); err != nil {
+		m.Start()
+		return err
+	}
+	if err := os.Rename(newPath, m.Path); err != nil {
+		_ = os.Rename(backup, m.Path)
+		m.Start()
+		return err
+	}
+
+	err := fmt.Errorf("failed to start new core")
+	if m.Start() {
+		if err = m.Ready(); err == nil {
+			return nil
+		}
+	}
+	// 新版本不可用，恢复旧版本
+	log.Println("New core is not ready, rolling back:", err)
+	m.Stop()
+	if rollbackErr := os.Rename(backup, m.Path); rollbackErr != nil {
+		return fmt.Errorf("%w, rollback failed: %v", err, rollbackErr)
+	}
+	m.Start()
+	return err
+}
+
+// 获取 amd64 处理器支持的构建级别
+func cpuLevel() string {
+	if runtime.GOARCH != "amd64" {
+		return ""
+	}
+	x := cpu.X86
+	if x.HasAVX && x.HasAVX2 && x.HasBMI1 && x.HasBMI2 && x.HasFMA && x.HasOSXSAVE {
+		return "v3"
+	}
+	if x.HasCX16 && x.HasPOPCNT && x.HasSSE3 && x.HasSSSE3 && x.HasSSE41 && x.HasSSE42 {
+		return "v2"
+	}
+	return "v1"
+}
+
+// 按优先级排列的core发布附件名称，如 mihomo-windows-amd64-v3-v1.19.0.zip
+// 不带级别的 amd64 构建为 v3，compatible 构建为 v1
+func coreAssetNames(goos, goarch, level, tag string) []string {
+	variants := []string{""}
+	if goarch == "amd64" {
+		switch level {
+		case "v3":
+			variants = []string{"-v3", ""}
+		case "v2":
+			variants = []string{"-v2", "-v1", "-compatible"}
+		default:
+			variants = []string{"-v1", "-compatible"}
+		}
+	}
+	names := make([]string, 0, len(variants))
+	for _, variant := range variants {
+		names = append(names, fmt.Sprintf("mihomo-%s-%s%s-%s.zip", goos, goarch, variant, tag))
+	}
+	return names
+}
+
+// 在解压目录中查找core程序
+func findCoreBinary(dir string) (string, error) {
+	var found string
+	_ = filepath.WalkDir(dir, func(path string, info os.DirEntry, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		name := strings.ToLower(info.Name())
+		if strings.HasPrefix(name, strings.ToLower(CoreShowName)) && strings.HasSuffix(name, ".exe") {
+			found = path
+			return filepath.SkipAll
+		}
+		return nil
+	})
+	if found == "" {
+		return "", fmt.Errorf("core executable not found in archive")
+	}
+	return found, nil
+}
+
+// 等待core就绪，配置了外部控制器时以接口可访问为准，否则以进程持续运行为准
+func waitCoreReady() error {
+	deadline := time.Now().Add(coreReadyTimeout)
+	for time.Now().Before(deadline) {
+		time.Sleep(500 * time.Millisecond)
+		if !isCoreRunning() {
+			return fmt.Errorf("core exited")
+		}
+		if getCoreConfig().ControllerAddr == "" {
+			return nil
+		}
+		if err := controllerJSON(http.MethodGet, "/version", nil, nil); err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("core is not ready after %s", coreReadyTimeout)
+}
+
+// 没有core时下载最新版本到工作目录
+func installCore() (string, error) {
+	path := filepath.Join(workDir, strings.ToLower(CoreShowName)+".exe")
+	manager := NewCoreManager(getAppConfig().CoreRelease, path)
+	release, err := manager.Latest()
+	if err != nil {
+		return "", err
+	}
+	log.Printf("Downloading core %s: %s\n", release.Version, release.Url)
+	if err = manager.Download(release, path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// 更新core并通知结果，notifyLatest 为 true 时已是最新版本也会通知
+func updateCore(force, notifyLatest bool) error {
+	release, updated, err := NewCoreManager(getAppConfig().CoreRelease, corePath).Update(force)
+	if err != nil {
+		log.Println("Failed to update core:", err)
+		return err
+	}
+	if updated {
+		log.Println("Core updated:", release.Version)
+		sendNotification(I.TranSys("msg.info.core_updated", map[string]any{"Version": release.Version}))
+	} else if notifyLatest {
+		sendNotification(I.TranSys("msg.info.core_latest", map[string]any{"Version": release.Version}))
+	}
+	return nil
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const testCoreRepo = "test/mihomo"
+
+// 模拟core发布源，assets 为附件名称和内容，digests 为附件摘要
+type testCoreSource struct {
+	server  *httptest.Server
+	tag     string
+	assets  map[string][]byte
+	digests map[string]string
+}
+
+func newTestCoreSource(t *testing.T, tag string) *testCoreSource {
+	t.Helper()
+	source := &testCoreSource{tag: tag, assets: make(map[string][]byte), digests: make(map[string]string)}
+	source.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/repos/"+testCoreRepo+"/releases/latest" {
+			release := GitHubRelease{TagName: source.tag}
+			names := make([]string, 0, len(source.assets))
+			for name := range source.assets {
+				names = append(names, name)
+			}
+			slices.Sort(names)
+			for _, name := range names {
+				release.Assets = append(release.Assets, GitHubReleaseAsset{
+					Name:               name,
+					BrowserDownloadUrl: source.server.URL + "/download/" + name,
+					Digest:             source.digests[name],
+				})
+			}
+			_ = json.NewEncoder(w).Encode(release)
+			return
+		}
+		if data, ok := source.assets[strings.TrimPrefix(r.URL.Path, "/download/")]; ok {
+			_, _ = w.Write(data)
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	t.Cleanup(source.server.Close)
+	return source
+}
+
+// 添加附件，withDigest 为 true 时发布附件摘要
+func (s *testCoreSource) add(name string, data []byte, withDigest bool) {
+	s.assets[name] = data
+	if withDigest {
+		s.digests[name] = "sha256:" + sha256Hex(data)
+	}
+}
+
+// 记录core启停调用的管理器，Version 返回程序文件内容
+func newTestCoreManager(t *testing.T, source *testCoreSource, calls *[]string) *CoreManager {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "mihomo.exe")
+	writeTestFile(t, path, "v1.0.0")
+	return &CoreManager{
+		Client:  source.server.Client(),
+		ApiBase: source.server.URL,
+		Repo:    testCoreRepo,
+		Level:   "v1",
+		Path:    path,
+		Stop:    func() bool { *calls = append(*calls, "stop"); return true },
+		Start:   func() bool { *calls = append(*calls, "start"); return true },
+		Ready:   func() error { *calls = append(*calls, "ready"); return nil },
+		Version: func(path string) string {
+			data, _ := os.ReadFile(path)
+			return string(data)
+		},
+	}
+}
+
+// 当前系统的core压缩包附件名称
+func testCoreAssetName(level, tag string) string {
+	return coreAssetNames(runtime.GOOS, runtime.GOARCH, level, tag)[0]
+}
+
+func testCoreArchive(t *testing.T, version string) []byte {
+	return buildTestZip(t, []testArchiveFile{{name: "mihomo-windows-amd64.exe", body: version}})
+}
+
+func TestCoreAssetNames(t *testing.T) {
+	tests := []struct {
+		goarch string
+		level  string
+		want   []string
+	}{
+		{"amd64", "v3", []string{"mihomo-windows-amd64-v3-v1.19.0.zip", "mihomo-windows-amd64-v1.19.0.zip"}},
+		{"amd64", "v2", []string{"mihomo-windows-amd64-v2-v1.19.0.zip", "mihomo-windows-amd64-v1-v1.19.0.zip", "mihomo-windows-amd64-compatible-v1.19.0.zip"}},
+		{"amd64", "v1", []string{"mihomo-windows-amd64-v1-v1.19.0.zip", "mihomo-windows-amd64-compatible-v1.19.0.zip"}},
+		{"amd64", "", []string{"mihomo-windows-amd64-v1-v1.19.0.zip", "mihomo-windows-amd64-compatible-v1.19.0.zip"}},
+		{"arm64", "v3", []string{"mihomo-windows-arm64-v1.19.0.zip"}},
+		{"386", "", []string{"mihomo-windows-386-v1.19.0.zip"}},
+	}
+	for _, tt := range tests {
+		if got := coreAssetNames("windows", tt.goarch, tt.level, "v1.19.0"); !slices.Equal(got, tt.want) {
+			t.Errorf("coreAssetNames(%s, %q) = %v, want %v", tt.goarch, tt.level, got, tt.want)
+		}
+	}
+}
+
+func TestCpuLevel(t *testing.T) {
+	level := cpuLevel()
+	if runtime.GOARCH != "amd64" {
+		if level != "" {
+			t.Fatalf("cpuLevel() = %q on %s", level, runtime.GOARCH)
+		}
+		return
+	}
+	if !slices.Contains([]string{"v1", "v2", "v3"}, level) {
+		t.Fatalf("cpuLevel() = %q", level)
+	}
+}
+
+func TestCoreManagerLatest(t *testing.T) {
+	if runtime.GOARCH != "amd64" {
+		t.Skip("asset variants only exist for amd64")
+	}
+	source := newTestCoreSource(t, "v1.19.0")
+	compatible := "mihomo-" + runtime.GOOS + "-amd64-compatible-v1.19.0.zip"
+	v3 := "mihomo-" + runtime.GOOS + "-amd64-v3-v1.19.0.zip"
+	source.add(compatible, []byte("compatible"), true)
+	source.add(v3, []byte("v3"), false)
+	source.add("mihomo-"+runtime.GOOS+"-arm64-v1.19.0.zip", []byte("arm64"), true)
+	source.add("checksums.txt", []byte(sha256Hex([]byte("v3"))+"  "+v3+"\n"), false)
+	var calls []string
+	manager := newTestCoreManager(t, source, &calls)
+
+	// v2 没有对应构建时使用 compatible 构建和附件摘要
+	manager.Level = "v2"
+	release, err := manager.Latest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := CoreRelease{Version: "v1.19.0", Asset: compatible, Url: source.server.URL + "/download/" + compatible, Sha256: sha256Hex([]byte("compatible"))}
+	if *release != want {
+		t.Fatalf("Latest() = %+v, want %+v", release, want)
+	}
+
+	// 没有附件摘要时从校验文件中查找
+	manager.Level = "v3"
+	if release, err = manager.Latest(); err != nil || release.Asset != v3 || release.Sha256 != sha256Hex([]byte("v3")) {
+		t.Fatalf("Latest() = %+v, %v", release, err)
+	}
+
+	// 没有发布摘要时拒绝下载
+	delete(source.assets, "checksums.txt")
+	if _, err = manager.Latest(); err == nil || !strings.Contains(err.Error(), "no checksum") {
+		t.Fatalf("expected missing checksum error, got %v", err)
+	}
+
+	delete(source.assets, compatible)
+	manager.Level = "v1"
+	if _, err = manager.Latest(); err == nil || !strings.Contains(err.Error(), "no core asset") {
+		t.Fatalf("expected missing asset error, got %v", err)
+	}
+	if len(calls) != 0 {
+		t.Fatalf("core touched while resolving release: %v", calls)
+	}
+}
+
+func TestCoreManagerUpdate(t *testing.T) {
+	source := newTestCoreSource(t, "v1.19.0")
+	source.add(testCoreAssetName("v1", "v1.19.0"), testCoreArchive(t, "v1.19.0"), true)
+	var calls []string
+	manager := newTestCoreManager(t, source, &calls)
+
+	release, updated, err := manager.Update(false)
+	if err != nil || !updated || release.Version != "v1.19.0" {
+		t.Fatalf("Update() = %+v, %v, %v", release, updated, err)
+	}
+	if data, _ := os.ReadFile(manager.Path); string(data) != "v1.19.0" {
+		t.Fatalf("core = %q", data)
+	}
+	if data, _ := os.ReadFile(manager.Path + ".old"); string(data) != "v1.0.0" {
+		t.Fatalf("backup = %q", data)
+	}
+	if !slices.Equal(calls, []string{"stop", "start", "ready"}) {
+		t.Fatalf("calls = %v", calls)
+	}
+	entries, _ := os.ReadDir(filepath.Dir(manager.Path))
+	if len(entries) != 2 {
+		t.Fatalf("leftover files: %v", entries)
+	}
+
+	// 已是最新版本时跳过
+	calls = nil
+	if _, updated, err = manager.Update(false); err != nil || updated || len(calls) != 0 {
+		t.Fatalf("updated = %v, calls = %v, err = %v", updated, calls, err)
+	}
+}
+
+func TestCoreManagerUpdateChecksumMismatch(t *testing.T) {
+	source := newTestCoreSource(t, "v1.19.0")
+	name := testCoreAssetName("v1", "v1.19.0")
+	source.add(name, testCoreArchive(t, "v1.19.0"), false)
+	source.digests[name] = "sha256:" + sha256Hex([]byte("other"))
+	var calls []string
+	manager := newTestCoreManager(t, source, &calls)
+
+	if _, _, err := manager.Update(false); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("expected checksum mismatch, got %v", err)
+	}
+	if data, _ := os.ReadFile(manager.Path); string(data) != "v1.0.0" {
+		t.Fatalf("core = %q", data)
+	}
+	if len(calls) != 0 {
+		t.Fatalf("core stopped for a bad download: %v", calls)
+	}
+	entries, _ := os.ReadDir(filepath.Dir(manager.Path))
+	if len(entries) != 1 {
+		t.Fatalf("leftover files: %v", entries)
+	}
+}
+
+func TestCoreManagerDownloadRejectsUnrunnableCore(t *testing.T) {
+	source := newTestCoreSource(t, "v1.19.0")
+	source.add(testCoreAssetName("v1", "v1.19.0"), testCoreArchive(t, ""), true)
+	var calls []string
+	manager := newTestCoreManager(t, source, &calls)
+
+	if _, _, err := manager.Update(false); err == nil || !strings.Contains(err.Error(), "not runnable") {
+		t.Fatalf("expected unrunnable core error, got %v", err)
+	}
+	if data, _ := os.ReadFile(manager.Path); string(data) != "v1.0.0" || len(calls) != 0 {
+		t.Fatalf("core = %q, calls = %v", data, calls)
+	}
+}
+
+func TestCoreManagerSwapRollback(t *testing.T) {
+	tests := []struct {
+		name  string
+		start []bool // 每次启动的结果
+		ready error
+		calls []string
+	}{
+		{"not ready", []bool{true, true}, os.ErrDeadlineExceeded, []string{"stop", "start", "ready", "stop", "start"}},
+		{"start failed", []bool{false, true}, nil, []string{"stop", "start", "stop", "start"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := newTestCoreSource(t, "v1.19.0")
+			var calls []string
+			manager := newTestCoreManager(t, source, &calls)
+			starts := tt.start
+			manager.Start = func() bool {
+				calls = append(calls, "start")
+				result := starts[0]
+				starts = starts[1:]
+				return result
+			}
+			manager.Ready = func() error { calls = append(calls, "ready"); return tt.ready }
+			newPath := manager.Path + ".new"
+			writeTestFile(t, newPath, "v1.19.0")
+
+			if err := manager.swap(newPath); err == nil {
+				t.Fatal("expected error")
+			}
+			if data, _ := os.ReadFile(manager.Path); string(data) != "v1.0.0" {
+				t.Fatalf("core not rolled back: %q", data)
+			}
+			if !slices.Equal(calls, tt.calls) {
+				t.Fatalf("calls = %v, want %v", calls, tt.calls)
+			}
+		})
+	}
+}
+
+func TestCoreManagerSwapStopFailed(t *testing.T) {
+	source := newTestCoreSource(t, "v1.19.0")
+	var calls []string
+	manager := newTestCoreManager(t, source, &calls)
+	manager.Stop = func() bool { return false }
+	newPath := manager.Path + ".new"
+	writeTestFile(t, newPath, "v1.19.0")
+
+	if err := manager.swap(newPath); err == nil {
+		t.Fatal("expected error")
+	}
+	if data, _ := os.ReadFile(manager.Path); string(data) != "v1.0.0" || len(calls) != 0 {
+		t.Fatalf("core = %q, calls = %v", data, calls)
+	}
+}
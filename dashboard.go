@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"text/template"
+)
+
+// DashboardData 面板地址模板可使用的数据
+type DashboardData struct {
+	Host   string // 外部控制器主机
+	Port   string // 外部控制器端口
+	Secret string // 外部控制器密钥
+	TLS    bool   // 外部控制器是否使用 https
+	UiPath string // 外部ui路径，如 /ui/metacubexd，未配置外部ui时为空
+}
+
+// 默认面板列表
+func defaultDashboards() []DashboardConfig {
+	return []DashboardConfig{
+		{
+			Name:  I.TranSys("tray.core_dashboard.options.local_ui", nil),
+			Url:   "{{if .TLS}}https{{else}}http{{end}}://{{.Host}}:{{.Port}}{{.UiPath}}/#/setup?{{if .TLS}}https{{else}}http{{end}}=true&hostname={{.Host}}&port={{.Port}}&secret={{urlquery .Secret}}",
+			Local: true,
+		},
+		{
+			Name: I.TranSys("tray.core_dashboard.options.official_ui", nil),
+			Url:  "https://metacubex.github.io/metacubexd/#/setup?{{if .TLS}}https{{else}}http{{end}}=true&hostname={{.Host}}&port={{.Port}}&secret={{urlquery .Secret}}",
+		},
+		{
+			Name: I.TranSys("tray.core_dashboard.options.yacd_ui", nil),
+			Url:  "https://yacd.metacubex.one/?hostname={{.Host}}&port={{.Port}}&secret={{urlquery .Secret}}",
+		},
+		{
+			Name: I.TranSys("tray.core_dashboard.options.zash_ui", nil),
+			Url:  "https://board.zash.run.place/#/setup?{{if .TLS}}https{{else}}http{{end}}=true&hostname={{.Host}}&port={{.Port}}&secret={{urlquery .Secret}}",
+		},
+	}
+}
+
+// 获取面板地址模板数据，未配置外部控制器时返回 nil
+func getDashboardData() *DashboardData {
+	config := getCoreConfig()
+	if config.ControllerAddr == "" {
+		return nil
+	}
+	host := config.ControllerHost
+	if strings.Contains(host, ":") {
+		// IPv6 地址需要加上方括号
+		host = "[" + host + "]"
+	}
+	return &DashboardData{
+		Host:   host,
+		Port:   config.ControllerPort,
+		Secret: config.Secret,
+		TLS:    config.ControllerTLS,
+		UiPath: config.UiPath,
+	}
+}
+
+// 判断面板当前是否可用，本地面板需要配置外部ui
+func isDashboardAvailable(dashboard DashboardConfig) bool {
+	data := getDashboardData()
+	if data == nil {
+		return false
+	}
+	return !dashboard.Local || data.UiPath != ""
+}
+
+// 使用模板数据渲染面板地址
+func renderDashboardUrl(dashboard DashboardConfig, data *DashboardData) (string, error) {
+	tpl, err := template.New(dashboard.Name).Option("missingkey=error").Parse(dashboard.Url)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	if err = tpl.Execute(&sb, data); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// 打开面板，启用控制面板网关时通过网关打开，地址中只包含一次性令牌
+func openDashboard(dashboard DashboardConfig) {
+	url, err := func() (string, error) {
+		data := getDashboardData()
+		if data == nil {
+			return "", fmt.Errorf("external-controller is not configured")
+		}
+		gateway, err := getDashboardGateway()
+		if err != nil {
+			return "", err
+		}
+		if gateway == nil {
+			return renderDashboardUrl(dashboard, data)
+		}
+		// 面板连接网关，由网关注入密钥，本地面板使用会话 cookie，外部面板中的密钥为登录时签发的访问令牌
+		data.Host, data.Port, _ = net.SplitHostPort(gateway.Addr())
+		data.TLS = false
+		return gateway.LoginUrl(func(access string) (string, error) {
+			accessData := *data
+			accessData.Secret = access
+			return renderDashboardUrl(dashboard, &accessData)
+		})
+	}()
+	if err != nil {
+		log.Printf("Failed to open dashboard %s: %v\n", dashboard.Name, err)
+		go messageBoxAlert(AppName, I.TranSys("msg.error.dashboard_failed", map[string]any{"Name": dashboard.Name, "Error": err}))
+		return
+	}
+	_ = openBrowser(url)
+}
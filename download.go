@@ -0,0 +1,395 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 默认的 GitHub API 地址
+const githubApiBase = "https://api.github.com"
+
+// 下载使用的客户端，core运行中时通过core代理下载
+var downloadClient = &http.Client{
+	Timeout:   10 * time.Minute,
+	Transport: newDownloadTransport(),
+}
+
+func newDownloadTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = downloadProxy
+	return transport
+}
+
+// 下载使用的代理，core运行中时使用core的http代理，否则使用环境变量中的代理
+func downloadProxy(req *http.Request) (*url.URL, error) {
+	if isCoreRunning() {
+		if config := getCoreConfig(); config.HttpProxyPort != 0 {
+			return &url.URL{Scheme: "http", Host: net.JoinHostPort(config.ProxyHost, strconv.Itoa(config.HttpProxyPort))}, nil
+		}
+	}
+	return http.ProxyFromEnvironment(req)
+}
+
+// GitHubRelease GitHub 发布信息
+type GitHubRelease struct {
+	TagName    string               `json:"tag_name"`
+	Name       string               `json:"name"`
+	Body       string               `json:"body"`
+	Prerelease bool                 `json:"prerelease"`
+	Assets     []GitHubReleaseAsset `json:"assets"`
+}
+
+// GitHubReleaseAsset GitHub 发布附件
+type GitHubReleaseAsset struct {
+	Name               string `json:"name"`
+	BrowserDownloadUrl string `json:"browser_download_url"`
+	Digest             string `json:"digest"` // 形如 sha256:xxx
+}
+
+// Asset 根据名称查找附件
+func (r *GitHubRelease) Asset(name string) *GitHubReleaseAsset {
+	for i := range r.Assets {
+		if r.Assets[i].Name == name {
+			return &r.Assets[i]
+		}
+	}
+	return nil
+}
+
+// Sha256 获取附件的 sha256 摘要，没有时返回空
+func (a *GitHubReleaseAsset) Sha256() string {
+	if digest, ok := strings.CutPrefix(a.Digest, "sha256:"); ok {
+		return digest
+	}
+	return ""
+}
+
+// 获取 GitHub 仓库的最新发布信息，apiBase 为空时使用默认地址
+func fetchGitHubRelease(client *http.Client, apiBase, repo, tag string) (*GitHubRelease, error) {
+	if apiBase == "" {
+		apiBase = githubApiBase
+	}
+	url := fmt.Sprintf("%s/repos/%s/releases/latest", strings.TrimSuffix(apiBase, "/"), repo)
+	if tag != "" {
+		url = fmt.Sprintf("%s/repos/%s/releases/tags/%s", strings.TrimSuffix(apiBase, "/"), repo, tag)
+	}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch release %s: %s", repo, resp.Status)
+	}
+	release := new(GitHubRelease)
+	if err = json.NewDecoder(resp.Body).Decode(release); err != nil {
+		return nil, err
+	}
+	return release, nil
+}
+
+// 获取 GitHub 仓库最近的发布列表（包括预发布），按创建时间倒序
+func fetchGitHubReleases(client *http.Client, apiBase, repo string) ([]GitHubRelease, error) {
+	if apiBase == "" {
+		apiBase = githubApiBase
+	}
+	resp, err := client.Get(fmt.Sprintf("%s/repos/%s/releases?per_page=20", strings.TrimSuffix(apiBase, "/"), repo))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch releases %s: %s", repo, resp.Status)
+	}
+	var releases []GitHubRelease
+	if err = json.NewDecoder(resp.Body).Decode(&releases); err != nil {
+		return nil, err
+	}
+	return releases, nil
+}
+
+// 下载文件到 dst，expectedSha256 不为空时校验摘要，返回文件的 sha256
+func downloadFile(client *http.Client, url, dst, expectedSha256 string) (string, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("download %s: %s", url, resp.Status)
+	}
+
+	if err = os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return "", err
+	}
+	f, err := os.Create(dst)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.New()
+	if _, err = io.Copy(io.MultiWriter(f, hash), resp.Body); err != nil {
+		_ = f.Close()
+		_ = os.Remove(dst)
+		return "", err
+	}
+	if err = f.Close(); err != nil {
+		_ = os.Remove(dst)
+		return "", err
+	}
+
+	sum := hex.EncodeToString(hash.Sum(nil))
+	if expectedSha256 != "" && !strings.EqualFold(sum, expectedSha256) {
+		_ = os.Remove(dst)
+		return "", fmt.Errorf("checksum mismatch for %s: expected %s, got %s", url, expectedSha256, sum)
+	}
+	return sum, nil
+}
+
+// 计算文件的 sha256
+func fileSha256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	hash := sha256.New()
+	if _, err = io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// 从 sha256sum 格式的校验文件内容中查找指定文件名的摘要
+// 校验文件只有一行时（如 xxx.sha256），不要求包含文件名
+func findChecksum(content, name string) string {
+	lines := strings.Split(strings.TrimSpace(content), "\n")
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && strings.TrimPrefix(fields[len(fields)-1], "*") == name {
+			return fields[0]
+		}
+	}
+	if fields := strings.Fields(lines[0]); len(lines) == 1 && len(fields) > 0 && len(fields[0]) == sha256.Size*2 {
+		return fields[0]
+	}
+	return ""
+}
+
+// 解压 zip 或 tar.gz 压缩包到 dst 目录
+// 所有文件位于同一个顶层目录时去除该目录，拒绝绝对路径、路径穿越和链接文件
+func extractArchive(src, dst string) error {
+	if strings.HasSuffix(src, ".zip") {
+		return extractZip(src, dst)
+	}
+	if strings.HasSuffix(src, ".tar.gz") || strings.HasSuffix(src, ".tgz") {
+		return extractTarGz(src, dst)
+	}
+	return fmt.Errorf("unsupported archive: %s", filepath.Base(src))
+}
+
+func extractZip(src, dst string) error {
+	reader, err := zip.OpenReader(src)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	names := make([]string, 0, len(reader.File))
+	for _, file := range reader.File {
+		names = append(names, file.Name)
+	}
+	prefix := archiveCommonPrefix(names)
+
+	for _, file := range reader.File {
+		if file.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("unsupported link in archive: %s", file.Name)
+		}
+		target, err := archiveTargetPath(dst, file.Name, prefix)
+		if err != nil {
+			return err
+		}
+		if target == "" {
+			continue
+		}
+		if file.FileInfo().IsDir() {
+			if err = os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+			continue
+		}
+		if err = func() error {
+			rc, err := file.Open()
+			if err != nil {
+				return err
+			}
+			defer rc.Close()
+			return writeArchiveFile(target, rc, file.Mode())
+		}(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func extractTarGz(src, dst string) error {
+	// 先遍历一次获取公共顶层目录
+	var names []string
+	if err := walkTarGz(src, func(header *tar.Header, _ io.Reader) error {
+		names = append(names, header.Name)
+		return nil
+	}); err != nil {
+		return err
+	}
+	prefix := archiveCommonPrefix(names)
+
+	return walkTarGz(src, func(header *tar.Header, r io.Reader) error {
+		target, err := archiveTargetPath(dst, header.Name, prefix)
+		if err != nil || target == "" {
+			return err
+		}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			return os.MkdirAll(target, 0755)
+		case tar.TypeReg:
+			return writeArchiveFile(target, r, header.FileInfo().Mode())
+		case tar.TypeSymlink, tar.TypeLink:
+			return fmt.Errorf("unsupported link in archive: %s", header.Name)
+		}
+		return nil
+	})
+}
+
+// 遍历 tar.gz 压缩包中的文件
+func walkTarGz(src string, fn func(header *tar.Header, r io.Reader) error) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err = fn(header, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// 获取压缩包中所有文件共同的顶层目录，不存在时返回空
+func archiveCommonPrefix(names []string) string {
+	top, nested := "", false
+	for _, name := range names {
+		name = strings.Trim(path.Clean(strings.ReplaceAll(name, "\\", "/")), "/")
+		if name == "" || name == "." {
+			continue
+		}
+		first, rest, _ := strings.Cut(name, "/")
+		if top == "" {
+			top = first
+		} else if first != top {
+			return ""
+		}
+		if rest != "" {
+			nested = true
+		}
+	}
+	if !nested {
+		// 只有一个顶层文件，不是目录
+		return ""
+	}
+	return top + "/"
+}
+
+// 计算压缩包内文件的解压路径，返回空表示跳过
+func archiveTargetPath(dst, name, prefix string) (string, error) {
+	name = strings.ReplaceAll(name, "\\", "/")
+	// 盘符路径在非 Windows 系统中不被视为绝对路径，单独检查
+	hasDrive := len(name) >= 2 && name[1] == ':'
+	if path.IsAbs(name) || filepath.IsAbs(name) || filepath.VolumeName(name) != "" || hasDrive {
+		return "", fmt.Errorf("illegal absolute path in archive: %s", name)
+	}
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." {
+			return "", fmt.Errorf("illegal path in archive: %s", name)
+		}
+	}
+	name = strings.TrimPrefix(path.Clean(name), "./")
+	if prefix != "" {
+		name = strings.TrimPrefix(name+"/", prefix)
+		name = strings.TrimSuffix(name, "/")
+	}
+	if name == "" || name == "." {
+		return "", nil
+	}
+	target := filepath.Join(dst, filepath.FromSlash(name))
+	if rel, err := filepath.Rel(dst, target); err != nil || strings.HasPrefix(rel, "..") {
+		return "", fmt.Errorf("illegal path in archive: %s", name)
+	}
+	return target, nil
+}
+
+// 写入解压的文件
+func writeArchiveFile(target string, r io.Reader, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	perm := mode.Perm()
+	if perm == 0 {
+		perm = 0644
+	}
+	f, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, perm)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(f, r); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// 使用新目录替换旧目录，替换失败时恢复旧目录
+func replaceDir(newDir, dir string) error {
+	backup := dir + ".old"
+	_ = os.RemoveAll(backup)
+	if isFileExist(dir) {
+		if err := os.Rename(dir, backup); err != nil {
+			return err
+		}
+	}
+	if err := os.Rename(newDir, dir); err != nil {
+		if isFileExist(backup) {
+			_ = os.Rename(backup, dir)
+		}
+		return err
+	}
+	_ = os.RemoveAll(backup)
+	return nil
+}
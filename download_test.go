@@ -0,0 +1,203 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// 压缩包中的文件，link 不为空时为链接
+type testArchiveFile struct {
+	name string
+	body string
+	link string
+}
+
+func buildTestZip(t *testing.T, files []testArchiveFile) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, file := range files {
+		header := &zip.FileHeader{Name: file.name, Method: zip.Deflate}
+		body := file.body
+		if file.link != "" {
+			header.SetMode(os.ModeSymlink | 0777)
+			body = file.link
+		}
+		f, err := w.CreateHeader(header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = f.Write([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func buildTestTarGz(t *testing.T, files []testArchiveFile) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	w := tar.NewWriter(gz)
+	for _, file := range files {
+		header := &tar.Header{Name: file.name, Mode: 0644, Size: int64(len(file.body)), Typeflag: tar.TypeReg}
+		if file.link != "" {
+			header.Typeflag, header.Linkname, header.Size = tar.TypeSymlink, file.link, 0
+		}
+		if err := w.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if file.link == "" {
+			if _, err := w.Write([]byte(file.body)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestDownloadFileChecksum(t *testing.T) {
+	content := []byte("archive content")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/file" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(content)
+	}))
+	defer server.Close()
+	dst := filepath.Join(t.TempDir(), "file")
+
+	sum, err := downloadFile(server.Client(), server.URL+"/file", dst, strings.ToUpper(sha256Hex(content)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum != sha256Hex(content) {
+		t.Fatalf("sha256 = %s", sum)
+	}
+
+	_ = os.Remove(dst)
+	if _, err = downloadFile(server.Client(), server.URL+"/file", dst, sha256Hex([]byte("other"))); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("expected checksum mismatch, got %v", err)
+	}
+	if isFileExist(dst) {
+		t.Fatal("file with mismatched checksum not removed")
+	}
+
+	if _, err = downloadFile(server.Client(), server.URL+"/missing", dst, ""); err == nil {
+		t.Fatal("expected error for 404")
+	}
+}
+
+func TestExtractArchive(t *testing.T) {
+	files := []testArchiveFile{
+		{name: "dist/index.html", body: "<html>"},
+		{name: "dist/assets/app.js", body: "app"},
+	}
+	for _, tt := range []struct {
+		name string
+		data []byte
+	}{
+		{"ui.zip", buildTestZip(t, files)},
+		{"ui.tar.gz", buildTestTarGz(t, files)},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			src := filepath.Join(dir, tt.name)
+			if err := os.WriteFile(src, tt.data, 0644); err != nil {
+				t.Fatal(err)
+			}
+			dst := filepath.Join(dir, "out")
+			if err := extractArchive(src, dst); err != nil {
+				t.Fatal(err)
+			}
+			// 公共顶层目录被去除
+			for name, want := range map[string]string{"index.html": "<html>", "assets/app.js": "app"} {
+				data, err := os.ReadFile(filepath.Join(dst, filepath.FromSlash(name)))
+				if err != nil || string(data) != want {
+					t.Fatalf("%s = %q, %v", name, data, err)
+				}
+			}
+		})
+	}
+}
+
+func TestExtractArchiveRejectsUnsafeEntries(t *testing.T) {
+	tests := []struct {
+		name  string
+		files []testArchiveFile
+	}{
+		{"parent path", []testArchiveFile{{name: "index.html"}, {name: "../evil.txt", body: "x"}}},
+		{"nested parent path", []testArchiveFile{{name: "dist/index.html"}, {name: "dist/../../evil.txt", body: "x"}}},
+		{"backslash parent path", []testArchiveFile{{name: "index.html"}, {name: "..\\evil.txt", body: "x"}}},
+		{"absolute path", []testArchiveFile{{name: "index.html"}, {name: "/tmp/evil.txt", body: "x"}}},
+		{"drive path", []testArchiveFile{{name: "index.html"}, {name: "C:/evil.txt", body: "x"}}},
+		{"symlink", []testArchiveFile{{name: "index.html"}, {name: "link", link: "/etc/passwd"}}},
+	}
+	for _, tt := range tests {
+		for _, format := range []string{"zip", "tar.gz"} {
+			t.Run(tt.name+" "+format, func(t *testing.T) {
+				dir := t.TempDir()
+				src := filepath.Join(dir, "ui."+format)
+				data := buildTestZip(t, tt.files)
+				if format == "tar.gz" {
+					data = buildTestTarGz(t, tt.files)
+				}
+				if err := os.WriteFile(src, data, 0644); err != nil {
+					t.Fatal(err)
+				}
+				dst := filepath.Join(dir, "a", "b", "out")
+				if err := extractArchive(src, dst); err == nil {
+					t.Fatal("expected error for unsafe entry")
+				}
+				for _, path := range []string{filepath.Join(dir, "evil.txt"), filepath.Join(dir, "a", "evil.txt"), filepath.Join(dir, "a", "b", "evil.txt")} {
+					if isFileExist(path) {
+						t.Fatalf("file written outside target: %s", path)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestFindChecksum(t *testing.T) {
+	sum := strings.Repeat("a", 64)
+	content := sum + "  mihomo-windows-amd64.zip\n" + strings.Repeat("b", 64) + " *mihomo-windows-arm64.zip\n"
+	if got := findChecksum(content, "mihomo-windows-amd64.zip"); got != sum {
+		t.Fatalf("findChecksum() = %q", got)
+	}
+	if got := findChecksum(content, "mihomo-windows-arm64.zip"); got != strings.Repeat("b", 64) {
+		t.Fatalf("findChecksum() = %q", got)
+	}
+	if got := findChecksum(content, "other.zip"); got != "" {
+		t.Fatalf("findChecksum() = %q", got)
+	}
+	// 只有一行时不要求包含文件名
+	if got := findChecksum(sum+"\n", "any.zip"); got != sum {
+		t.Fatalf("findChecksum() = %q", got)
+	}
+}
@@ -0,0 +1,354 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	gatewayLoginPath   = "/__gohomo/login" // 使用一次性令牌登录的路径
+	gatewayCookieName  = "gohomo_session"  // 会话 cookie 名称
+	gatewayTokenTTL    = time.Minute       // 一次性令牌有效期，外部面板访问令牌需在此时间内开始使用
+	gatewaySessionTTL  = 24 * time.Hour    // 会话有效期
+	gatewayAccessIdle  = 10 * time.Minute  // 外部面板访问令牌在没有请求后的有效期
+	gatewayDefaultHost = "127.0.0.1"       // 默认监听地址
+)
+
+// DashboardGateway 本地控制面板网关
+// 提供外部ui静态文件并反向代理外部控制器接口（包括 WebSocket），由网关在服务端注入密钥，
+// 浏览器只需持有一次性令牌换取的会话，地址中不再包含密钥
+type DashboardGateway struct {
+	listener net.Listener
+	server   *http.Server
+	proxy    *httputil.ReverseProxy
+
+	mutex    sync.Mutex
+	tokens   map[string]gatewayToken   // 一次性令牌
+	sessions map[string]time.Time      // 同源面板的会话及其过期时间
+	accesses map[string]*gatewayAccess // 外部面板的访问令牌
+}
+
+// 一次性令牌及其登录后跳转的地址，render 的参数为签发给外部面板的访问令牌，同源面板为空
+type gatewayToken struct {
+	render  func(access string) (string, error)
+	origin  string // 外部面板来源，同源面板为空
+	expires time.Time
+}
+
+// 外部面板的访问令牌，只允许签发时的来源使用，有进行中的请求（如 WebSocket 连接）时保持有效
+type gatewayAccess struct {
+	origin  string
+	expires time.Time // 没有进行中的请求时的过期时间
+	active  int       // 进行中的请求数量
+}
+
+var (
+	dashboardGateway      *DashboardGateway // 控制面板网关，首次使用时启动
+	dashboardGatewayMutex sync.Mutex        // 网关启动互斥锁
+)
+
+// NewDashboardGateway 在指定的本地回环地址上启动控制面板网关
+func NewDashboardGateway(listen string) (*DashboardGateway, error) {
+	host, _, err := net.SplitHostPort(listen)
+	if err != nil {
+		return nil, err
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return nil, fmt.Errorf("dashboard gateway must listen on a loopback address: %s", listen)
+	}
+	listener, err := net.Listen("tcp", listen)
+	if err != nil {
+		return nil, err
+	}
+
+	g := &DashboardGateway{
+		listener: listener,
+		tokens:   make(map[string]gatewayToken),
+		sessions: make(map[string]time.Time),
+		accesses: make(map[string]*gatewayAccess),
+	}
+	g.proxy = &httputil.ReverseProxy{
+		Rewrite:   g.rewrite,
+		Transport: controllerClient.Transport,
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(gatewayLoginPath, g.handleLogin)
+	mux.HandleFunc("/ui/", g.handleUi)
+	mux.HandleFunc("/", g.handleApi)
+	g.server = &http.Server{
+		Handler:           g.checkHost(mux),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		if err := g.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.Println("Dashboard gateway stopped:", err)
+		}
+	}()
+	log.Println("Dashboard gateway listening on:", g.Addr())
+	return g, nil
+}
+
+// Addr 网关监听地址
+func (g *DashboardGateway) Addr() string {
+	return g.listener.Addr().String()
+}
+
+// Close 关闭网关
+func (g *DashboardGateway) Close() error {
+	return g.server.Close()
+}
+
+// LoginUrl 生成一次性登录地址，登录后跳转到 render 生成的地址
+// 同源面板使用会话 cookie，render 的参数为空；地址为外部面板时，render 的参数为只允许该面板来源使用的访问令牌，
+// 面板使用访问令牌作为密钥跨域访问网关
+func (g *DashboardGateway) LoginUrl(render func(access string) (string, error)) (string, error) {
+	// 提前渲染一次，地址模板有误时直接返回错误，并确定面板来源
+	redirect, err := render("")
+	if err != nil {
+		return "", err
+	}
+	origin := ""
+	if target, err := url.Parse(redirect); err == nil && target.IsAbs() && target.Host != g.Addr() {
+		origin = target.Scheme + "://" + target.Host
+	}
+	token, err := randomHex(16)
+	if err != nil {
+		return "", err
+	}
+
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	now := time.Now()
+	for key, t := range g.tokens {
+		if now.After(t.expires) {
+			delete(g.tokens, key)
+		}
+	}
+	for key, expires := range g.sessions {
+		if now.After(expires) {
+			delete(g.sessions, key)
+		}
+	}
+	for key, access := range g.accesses {
+		if access.active == 0 && now.After(access.expires) {
+			delete(g.accesses, key)
+		}
+	}
+	g.tokens[token] = gatewayToken{render: render, origin: origin, expires: now.Add(gatewayTokenTTL)}
+	return fmt.Sprintf("http://%s%s?token=%s", g.Addr(), gatewayLoginPath, token), nil
+}
+
+// 校验 Host 请求头，防止 DNS 重绑定攻击
+func (g *DashboardGateway) checkHost(next http.Handler) http.Handler {
+	_, port, _ := net.SplitHostPort(g.Addr())
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, p, err := net.SplitHostPort(r.Host)
+		if err != nil || p != port || (host != "localhost" && !isLoopbackHost(host)) {
+			http.Error(w, "invalid host", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// 使用一次性令牌换取会话，外部面板换取访问令牌
+func (g *DashboardGateway) handleLogin(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	t, ok := g.tokens[token]
+	delete(g.tokens, token)
+	if !ok || time.Now().After(t.expires) {
+		http.Error(w, "invalid or expired token", http.StatusUnauthorized)
+		return
+	}
+	// 同源面板使用会话 cookie，地址中不包含任何凭据；外部面板无法使用网关的 cookie，地址中只包含绑定来源的访问令牌
+	credential, err := randomHex(32)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	access := ""
+	if t.origin != "" {
+		access = credential
+	}
+	redirect, err := t.render(access)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if t.origin != "" {
+		g.accesses[access] = &gatewayAccess{origin: t.origin, expires: time.Now().Add(gatewayTokenTTL)}
+		http.Redirect(w, r, redirect, http.StatusFound)
+		return
+	}
+
+	g.sessions[credential] = time.Now().Add(gatewaySessionTTL)
+	http.SetCookie(w, &http.Cookie{
+		Name:     gatewayCookieName,
+		Value:    credential,
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+		MaxAge:   int(gatewaySessionTTL.Seconds()),
+	})
+	http.Redirect(w, r, redirect, http.StatusFound)
+}
+
+// 提供外部ui静态文件
+func (g *DashboardGateway) handleUi(w http.ResponseWriter, r *http.Request) {
+	release, ok := g.authorize(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	defer release()
+	uiDir := getExternalUiDir()
+	if uiDir == "" {
+		http.NotFound(w, r)
+		return
+	}
+	http.StripPrefix("/ui/", http.FileServer(http.Dir(uiDir))).ServeHTTP(w, r)
+}
+
+// 反向代理外部控制器接口
+func (g *DashboardGateway) handleApi(w http.ResponseWriter, r *http.Request) {
+	release, ok := g.authorize(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	defer release()
+	if getCoreConfig().ControllerAddr == "" {
+		http.Error(w, "external-controller is not configured", http.StatusBadGateway)
+		return
+	}
+	g.proxy.ServeHTTP(w, r)
+}
+
+// 改写反向代理请求，注入外部控制器密钥
+func (g *DashboardGateway) rewrite(r *httputil.ProxyRequest) {
+	config := getCoreConfig()
+	scheme := "http"
+	if config.ControllerTLS {
+		scheme = "https"
+	}
+	r.SetURL(&url.URL{Scheme: scheme, Host: config.ControllerAddr})
+	// 会话 cookie 和会话令牌只在网关内使用，不转发给core
+	r.Out.Header.Del("Cookie")
+	r.Out.Header.Del("Authorization")
+	if query := r.Out.URL.Query(); query.Has("token") {
+		query.Del("token")
+		r.Out.URL.RawQuery = query.Encode()
+	}
+	if config.Secret != "" {
+		r.Out.Header.Set("Authorization", "Bearer "+config.Secret)
+	}
+}
+
+// 判断请求是否已授权，返回请求结束时调用的函数：同源请求需要会话 cookie，跨域请求需要携带签发给该来源的访问令牌
+// Origin 请求头可以伪造，不能单独作为授权依据
+func (g *DashboardGateway) authorize(r *http.Request) (func(), bool) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	now := time.Now()
+
+	if origin := r.Header.Get("Origin"); origin != "" && origin != "http://"+r.Host {
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			// 跨域预检请求不携带令牌，只允许有有效访问令牌的来源
+			for _, access := range g.accesses {
+				if access.origin == origin && (access.active > 0 || now.Before(access.expires)) {
+					return func() {}, true
+				}
+			}
+			return nil, false
+		}
+		token := accessToken(r)
+		access, ok := g.accesses[token]
+		if !ok || access.origin != origin {
+			return nil, false
+		}
+		if access.active == 0 && now.After(access.expires) {
+			delete(g.accesses, token)
+			return nil, false
+		}
+		// 请求结束后重新计算空闲有效期
+		access.active++
+		return func() {
+			g.mutex.Lock()
+			defer g.mutex.Unlock()
+			access.active--
+			access.expires = time.Now().Add(gatewayAccessIdle)
+		}, true
+	}
+	cookie, err := r.Cookie(gatewayCookieName)
+	if err != nil {
+		return nil, false
+	}
+	expires, ok := g.sessions[cookie.Value]
+	if !ok {
+		return nil, false
+	}
+	if now.After(expires) {
+		delete(g.sessions, cookie.Value)
+		return nil, false
+	}
+	return func() {}, true
+}
+
+// 外部面板请求中的访问令牌，与访问core时的密钥位置相同：Authorization 请求头或 WebSocket 的 token 参数
+func accessToken(r *http.Request) string {
+	if scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " "); ok && strings.EqualFold(scheme, "Bearer") {
+		return token
+	}
+	return r.URL.Query().Get("token")
+}
+
+// 判断主机是否为本地回环地址
+func isLoopbackHost(host string) bool {
+	ip := net.ParseIP(strings.Trim(host, "[]"))
+	return ip != nil && ip.IsLoopback()
+}
+
+// 生成指定字节长度的随机十六进制字符串
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// 获取控制面板网关，未启用时返回 nil，首次调用时启动
+func getDashboardGateway() (*DashboardGateway, error) {
+	config := getAppConfig().DashboardGateway
+	if !config.Enabled {
+		return nil, nil
+	}
+
+	dashboardGatewayMutex.Lock()
+	defer dashboardGatewayMutex.Unlock()
+	if dashboardGateway != nil {
+		return dashboardGateway, nil
+	}
+	listen := config.Listen
+	if listen == "" {
+		listen = net.JoinHostPort(gatewayDefaultHost, "0")
+	}
+	gateway, err := NewDashboardGateway(listen)
+	if err != nil {
+		return nil, err
+	}
+	dashboardGateway = gateway
+	return dashboardGateway, nil
+}
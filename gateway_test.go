@@ -0,0 +1,243 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testDashboardOrigin = "https://board.example.com"
+
+// 启动网关和模拟的外部控制器，控制器返回收到的 Authorization 请求头和查询参数
+func newTestGateway(t *testing.T) *DashboardGateway {
+	t.Helper()
+	controller := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, r.Header.Get("Authorization")+"|"+r.URL.RawQuery)
+	}))
+	t.Cleanup(controller.Close)
+	coreConfig.Store(&CoreConfig{
+		ControllerAddr: strings.TrimPrefix(controller.URL, "http://"),
+		Secret:         "controller-secret",
+	})
+	gateway, err := NewDashboardGateway("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = gateway.Close() })
+	return gateway
+}
+
+// 打开登录地址，返回跳转的地址和会话 cookie
+func loginTestGateway(t *testing.T, gateway *DashboardGateway, render func(access string) (string, error)) (*url.URL, *http.Cookie) {
+	t.Helper()
+	loginUrl, err := gateway.LoginUrl(render)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(loginUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("login status = %d", resp.StatusCode)
+	}
+	location, err := resp.Location()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cookie *http.Cookie
+	for _, c := range resp.Cookies() {
+		if c.Name == gatewayCookieName {
+			cookie = c
+		}
+	}
+	// 一次性令牌不能再次使用
+	if resp, err = client.Get(loginUrl); err == nil {
+		_ = resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("reused login token status = %d", resp.StatusCode)
+		}
+	}
+	return location, cookie
+}
+
+func gatewayRequest(t *testing.T, gateway *DashboardGateway, method, path string, header map[string]string, cookie *http.Cookie) (int, string) {
+	t.Helper()
+	req, err := http.NewRequest(method, "http://"+gateway.Addr()+path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, value := range header {
+		req.Header.Set(key, value)
+	}
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+func TestGatewayCrossOriginRequiresAccessToken(t *testing.T) {
+	gateway := newTestGateway(t)
+	location, cookie := loginTestGateway(t, gateway, func(access string) (string, error) {
+		return testDashboardOrigin + "/setup?secret=" + access, nil
+	})
+	access := location.Query().Get("secret")
+	if access == "" || access == "controller-secret" {
+		t.Fatalf("dashboard url = %s", location)
+	}
+	// 外部面板不签发会话，访问令牌不能作为会话 cookie 使用
+	if cookie != nil {
+		t.Fatalf("session cookie set for hosted dashboard: %v", cookie)
+	}
+	sessionCookie := &http.Cookie{Name: gatewayCookieName, Value: access}
+	if status, _ := gatewayRequest(t, gateway, http.MethodGet, "/version", nil, sessionCookie); status != http.StatusUnauthorized {
+		t.Fatalf("access token as cookie status = %d", status)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		header map[string]string
+		status int
+	}{
+		{"origin only", http.MethodGet, "/version", map[string]string{"Origin": testDashboardOrigin}, http.StatusUnauthorized},
+		{"wrong token", http.MethodGet, "/version", map[string]string{"Origin": testDashboardOrigin, "Authorization": "Bearer other"}, http.StatusUnauthorized},
+		{"other origin", http.MethodGet, "/version", map[string]string{"Origin": "https://evil.example.com", "Authorization": "Bearer " + access}, http.StatusUnauthorized},
+		{"bearer token", http.MethodGet, "/version", map[string]string{"Origin": testDashboardOrigin, "Authorization": "Bearer " + access}, http.StatusOK},
+		{"websocket token", http.MethodGet, "/logs?level=info&token=" + access, map[string]string{"Origin": testDashboardOrigin}, http.StatusOK},
+		{"preflight", http.MethodOptions, "/version", map[string]string{"Origin": testDashboardOrigin, "Access-Control-Request-Method": "GET"}, http.StatusOK},
+		{"preflight other origin", http.MethodOptions, "/version", map[string]string{"Origin": "https://evil.example.com", "Access-Control-Request-Method": "GET"}, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := gatewayRequest(t, gateway, tt.method, tt.path, tt.header, nil)
+			if status != tt.status {
+				t.Fatalf("status = %d, want %d (%s)", status, tt.status, body)
+			}
+			if status != http.StatusOK || tt.method == http.MethodOptions {
+				return
+			}
+			// 转发给core时使用真实密钥，不包含访问令牌
+			if !strings.HasPrefix(body, "Bearer controller-secret|") || strings.Contains(body, access) {
+				t.Fatalf("forwarded request = %q", body)
+			}
+		})
+	}
+
+	// 请求结束后按空闲时间延长有效期
+	gateway.mutex.Lock()
+	expires := gateway.accesses[access].expires
+	gateway.mutex.Unlock()
+	if time.Until(expires) < gatewayAccessIdle-time.Minute {
+		t.Fatalf("access token expires in %s", time.Until(expires))
+	}
+
+	// 访问令牌空闲过期后来源也不再允许访问
+	expireTestAccess(gateway, access)
+	header := map[string]string{"Origin": testDashboardOrigin, "Authorization": "Bearer " + access}
+	if status, _ := gatewayRequest(t, gateway, http.MethodGet, "/version", header, nil); status != http.StatusUnauthorized {
+		t.Fatalf("expired access token status = %d", status)
+	}
+	preflight := map[string]string{"Origin": testDashboardOrigin, "Access-Control-Request-Method": "GET"}
+	if status, _ := gatewayRequest(t, gateway, http.MethodOptions, "/version", preflight, nil); status != http.StatusUnauthorized {
+		t.Fatalf("expired access token preflight status = %d", status)
+	}
+}
+
+// 使访问令牌过期
+func expireTestAccess(gateway *DashboardGateway, access string) {
+	gateway.mutex.Lock()
+	defer gateway.mutex.Unlock()
+	if a := gateway.accesses[access]; a != nil {
+		a.expires = time.Now().Add(-time.Second)
+	}
+}
+
+func TestGatewayAccessTokenLifetime(t *testing.T) {
+	gateway := newTestGateway(t)
+	location, _ := loginTestGateway(t, gateway, func(access string) (string, error) {
+		return testDashboardOrigin + "/setup?secret=" + access, nil
+	})
+	access := location.Query().Get("secret")
+	header := map[string]string{"Origin": testDashboardOrigin, "Authorization": "Bearer " + access}
+
+	// 签发后只在很短的时间内可以开始使用
+	gateway.mutex.Lock()
+	expires := gateway.accesses[access].expires
+	gateway.mutex.Unlock()
+	if time.Until(expires) > gatewayTokenTTL {
+		t.Fatalf("unused access token expires in %s", time.Until(expires))
+	}
+
+	// 进行中的请求（如 WebSocket 连接）期间不会过期
+	release, ok := gateway.authorize(newTestRequest(header))
+	if !ok {
+		t.Fatal("access token rejected")
+	}
+	expireTestAccess(gateway, access)
+	if status, _ := gatewayRequest(t, gateway, http.MethodGet, "/version", header, nil); status != http.StatusOK {
+		t.Fatalf("access token with active request status = %d", status)
+	}
+	release()
+
+	expireTestAccess(gateway, access)
+	if status, _ := gatewayRequest(t, gateway, http.MethodGet, "/version", header, nil); status != http.StatusUnauthorized {
+		t.Fatalf("expired access token status = %d", status)
+	}
+}
+
+func newTestRequest(header map[string]string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/connections", nil)
+	for key, value := range header {
+		req.Header.Set(key, value)
+	}
+	return req
+}
+
+func TestGatewaySameOriginCookie(t *testing.T) {
+	gateway := newTestGateway(t)
+	location, cookie := loginTestGateway(t, gateway, func(access string) (string, error) {
+		return "http://" + gateway.Addr() + "/ui/#/setup?secret=" + access, nil
+	})
+	if cookie == nil {
+		t.Fatal("session cookie not set")
+	}
+	// 同源面板地址中不包含任何凭据
+	if strings.Contains(location.String(), cookie.Value) || !strings.HasSuffix(location.String(), "secret=") {
+		t.Fatalf("dashboard url = %s", location)
+	}
+	if status, _ := gatewayRequest(t, gateway, http.MethodGet, "/version", nil, nil); status != http.StatusUnauthorized {
+		t.Fatalf("request without cookie status = %d", status)
+	}
+	status, body := gatewayRequest(t, gateway, http.MethodGet, "/version", nil, cookie)
+	if status != http.StatusOK || body != "Bearer controller-secret|" {
+		t.Fatalf("request with cookie = %d %q", status, body)
+	}
+}
+
+func TestGatewayRejectsOtherHost(t *testing.T) {
+	gateway := newTestGateway(t)
+	req, _ := http.NewRequest(http.MethodGet, "http://"+gateway.Addr()+"/version", nil)
+	req.Host = "attacker.example.com"
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("status = %d", resp.StatusCode)
+	}
+}
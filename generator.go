@@ -0,0 +1,313 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+
+	"github.com/junlongzzz/gohomo/sharelink"
+	"go.yaml.in/yaml/v3"
+)
+
+const ProfileTypeGenerated = "generated" // 由模板和多个订阅生成的配置文件
+
+// 模板中代理组按节点名称筛选的字段，生成时移除
+const (
+	generatorFilterKey  = "gohomo-filter"  // 包含名称匹配的节点
+	generatorExcludeKey = "gohomo-exclude" // 排除名称匹配的节点
+)
+
+// 通过订阅地址添加的订阅配置文件的默认更新间隔
+const defaultSourceInterval = "24h"
+
+// GeneratorSource 生成配置文件使用的订阅节点
+type GeneratorSource struct {
+	Name    string           // 订阅名称
+	Proxies []map[string]any // 订阅中的节点
+}
+
+// 使用模板和订阅节点生成配置文件
+// 合并所有订阅的节点到 proxies，重名节点添加序号，代理组根据 gohomo-filter 和 gohomo-exclude 正则筛选节点名称
+func generateProfile(template []byte, sources []GeneratorSource) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(template, &doc); err != nil {
+		return nil, fmt.Errorf("template: %w", err)
+	}
+	if len(doc.Content) == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("template: not a yaml mapping")
+	}
+
+	// 模板中已有的节点名称
+	proxiesNode := mappingValue(root, "proxies", yaml.SequenceNode)
+	names := make(map[string]int)
+	for _, node := range proxiesNode.Content {
+		if name := mappingValue(node, "name", 0); name != nil {
+			names[name.Value]++
+		}
+	}
+	// 合并订阅节点
+	var merged []string
+	for _, source := range sources {
+		for _, proxy := range source.Proxies {
+			name := fmt.Sprint(proxy["name"])
+			unique := name
+			for i := 2; names[unique] > 0; i++ {
+				unique = fmt.Sprintf("%s %d", name, i)
+			}
+			names[unique]++
+			// 复制后再重命名，不修改订阅中缓存的节点
+			proxy = maps.Clone(proxy)
+			proxy["name"] = unique
+			var node yaml.Node
+			if err := node.Encode(sharelink.Proxy(proxy)); err != nil {
+				return nil, fmt.Errorf("%s: %w", source.Name, err)
+			}
+			proxiesNode.Content = append(proxiesNode.Content, &node)
+			merged = append(merged, unique)
+		}
+	}
+
+	// 根据正则筛选代理组的节点
+	if groups := mappingValue(root, "proxy-groups", 0); groups != nil {
+		for _, group := range groups.Content {
+			if err := fillProxyGroup(group, merged); err != nil {
+				return nil, err
+			}
+		}
+	}
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&doc); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), encoder.Close()
+}
+
+// 将匹配筛选条件的节点添加到代理组，没有任何节点时添加 DIRECT
+func fillProxyGroup(group *yaml.Node, names []string) error {
+	filter := removeMappingKey(group, generatorFilterKey)
+	exclude := removeMappingKey(group, generatorExcludeKey)
+	if filter == nil && exclude == nil {
+		return nil
+	}
+	groupName := ""
+	if name := mappingValue(group, "name", 0); name != nil {
+		groupName = name.Value
+	}
+	include, err := compileFilter(filter)
+	if err != nil {
+		return fmt.Errorf("proxy group %s: %w", groupName, err)
+	}
+	excludeRegex, err := compileFilter(exclude)
+	if err != nil {
+		return fmt.Errorf("proxy group %s: %w", groupName, err)
+	}
+	proxies := mappingValue(group, "proxies", yaml.SequenceNode)
+	for _, name := range names {
+		if (include == nil || include.MatchString(name)) && (excludeRegex == nil || !excludeRegex.MatchString(name)) {
+			proxies.Content = append(proxies.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: name})
+		}
+	}
+	if len(proxies.Content) == 0 && mappingValue(group, "use", 0) == nil &&
+		mappingValue(group, "include-all", 0) == nil && mappingValue(group, "include-all-proxies", 0) == nil {
+		// 代理组不能为空
+		proxies.Content = append(proxies.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: "DIRECT"})
+	}
+	return nil
+}
+
+func compileFilter(node *yaml.Node) (*regexp.Regexp, error) {
+	if node == nil || node.Value == "" {
+		return nil, nil
+	}
+	return regexp.Compile(node.Value)
+}
+
+// 获取映射节点中键对应的值，kind 不为 0 时不存在则创建
+func mappingValue(node *yaml.Node, key string, kind yaml.Kind) *yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			value := node.Content[i+1]
+			if kind != 0 && value.Kind != kind {
+				// 如 proxies: null
+				*value = yaml.Node{Kind: kind}
+			}
+			return value
+		}
+	}
+	if kind == 0 {
+		return nil
+	}
+	value := &yaml.Node{Kind: kind}
+	node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, value)
+	return value
+}
+
+// 删除映射节点中的键，返回删除的值
+func removeMappingKey(node *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			value := node.Content[i+1]
+			node.Content = slices.Delete(node.Content, i, i+2)
+			return value
+		}
+	}
+	return nil
+}
+
+// 读取订阅配置文件中的节点
+func loadProfileProxies(store *ProfileStore, profile *Profile) ([]map[string]any, error) {
+	var content struct {
+		Proxies []map[string]any `yaml:"proxies"`
+	}
+	if err := readYamlFile(store.Path(profile), &content); err != nil {
+		return nil, err
+	}
+	return content.Proxies, nil
+}
+
+// 将生成配置文件的订阅地址添加为订阅配置文件，返回订阅配置文件名称
+func ensureSourceProfile(store *ProfileStore, source string) (string, error) {
+	u, err := url.Parse(source)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		// 订阅配置文件名称
+		return source, nil
+	}
+	for _, profile := range store.List() {
+		if profile.Url == source {
+			return profile.Name, nil
+		}
+	}
+	profile := &Profile{Name: u.Hostname(), Type: ProfileTypeRemote, Url: source, UpdateInterval: defaultSourceInterval}
+	store.Add(profile)
+	if err = fetchRemoteProfile(store, profile); err != nil {
+		store.Remove(profile.Name)
+		return "", err
+	}
+	return profile.Name, nil
+}
+
+// 根据应用配置同步生成的配置文件，添加新的生成配置并生成所有配置文件
+// 新添加生成配置时切换到第一个新生成的配置文件，reload 为 true 时正在使用的配置文件变化后重新加载core配置
+func syncProfileGenerators(reload bool) error {
+	store := getProfileStore()
+	var errs []error
+	var added string
+	for _, config := range getAppConfig().ProfileGenerators {
+		if config.Name == "" || config.Template == "" {
+			continue
+		}
+		var sources []string
+		for _, source := range config.Sources {
+			name, err := ensureSourceProfile(store, source)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", config.Name, err))
+				continue
+			}
+			sources = append(sources, name)
+		}
+		profile := store.Get(config.Name)
+		if profile == nil {
+			profile = &Profile{Name: config.Name, Type: ProfileTypeGenerated}
+			store.Add(profile)
+			if added == "" {
+				added = profile.Name
+			}
+		}
+		if profile.Type != ProfileTypeGenerated {
+			errs = append(errs, fmt.Errorf("%s: profile name is already used", config.Name))
+			continue
+		}
+		profile.Template = config.Template
+		profile.Sources = sources
+	}
+
+	regenerated, err := regenerateProfiles(nil)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	activated := false
+	if added != "" && slices.Contains(regenerated, added) {
+		activated = store.SetActive(added) == nil
+		log.Println("Activate generated profile:", added)
+	}
+	errs = append(errs, store.Save())
+	if reload && (activated || slices.Contains(regenerated, store.ActiveName())) {
+		coreConfigPath = store.ActivePath()
+		errs = append(errs, reloadCoreConfig())
+	}
+	return errors.Join(errs...)
+}
+
+// 重新生成依赖已更新订阅的配置文件，updated 为空时全部重新生成，返回生成的配置文件名称
+func regenerateProfiles(updated []string) ([]string, error) {
+	store := getProfileStore()
+	var regenerated []string
+	var errs []error
+	for _, profile := range store.List() {
+		if profile.Type != ProfileTypeGenerated {
+			continue
+		}
+		if updated != nil && !slices.ContainsFunc(profile.Sources, func(s string) bool { return slices.Contains(updated, s) }) {
+			continue
+		}
+		if err := writeGeneratedProfile(store, profile); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", profile.Name, err))
+			continue
+		}
+		regenerated = append(regenerated, profile.Name)
+	}
+	return regenerated, errors.Join(errs...)
+}
+
+// 生成配置文件并写入 profiles 目录
+func writeGeneratedProfile(store *ProfileStore, profile *Profile) error {
+	template, err := os.ReadFile(resolveWorkPath(profile.Template))
+	if err != nil {
+		return err
+	}
+	var sources []GeneratorSource
+	for _, name := range profile.Sources {
+		source := store.Get(name)
+		if source == nil {
+			return fmt.Errorf("source profile not found: %s", name)
+		}
+		proxies, err := loadProfileProxies(store, source)
+		if err != nil {
+			return err
+		}
+		sources = append(sources, GeneratorSource{Name: name, Proxies: proxies})
+	}
+	content, err := generateProfile(template, sources)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(store.dir, 0755); err != nil {
+		return err
+	}
+	log.Println("Generate profile:", profile.Name)
+	return os.WriteFile(store.Path(profile), content, 0644)
+}
+
+// 相对路径基于工作目录
+func resolveWorkPath(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(workDir, path)
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.yaml.in/yaml/v3"
+)
+
+const testGeneratorTemplate = `mixed-port: 7890
+proxies:
+  - {name: HK 01, type: direct}
+proxy-groups:
+  - name: Proxy
+    type: select
+    proxies: [Auto, DIRECT]
+  - name: Auto
+    type: url-test
+    gohomo-filter: .
+    gohomo-exclude: (?i)expire|traffic
+  - name: HK
+    type: select
+    proxies: [HK 01]
+    gohomo-filter: HK|香港
+  - name: US
+    type: url-test
+    gohomo-filter: US|美国
+  - name: Provider
+    type: select
+    use: [extra]
+    gohomo-filter: US
+rules:
+  - MATCH,Proxy
+`
+
+// 生成配置文件中用于检查的字段
+type testGeneratedProfile struct {
+	MixedPort int `yaml:"mixed-port"`
+	Proxies   []struct {
+		Name   string `yaml:"name"`
+		Server string `yaml:"server"`
+	} `yaml:"proxies"`
+	Groups []map[string]any `yaml:"proxy-groups"`
+	Rules  []string         `yaml:"rules"`
+}
+
+func testGeneratorProxy(name, server string) map[string]any {
+	return map[string]any{"name": name, "type": "ss", "server": server, "port": 8388, "cipher": "aes-128-gcm", "password": "x"}
+}
+
+func TestGenerateProfile(t *testing.T) {
+	sources := []GeneratorSource{
+		{Name: "a", Proxies: []map[string]any{
+			testGeneratorProxy("HK 01", "a-hk.example.com"),
+			testGeneratorProxy("Expire: 2026-01-01", "0.0.0.0"),
+			testGeneratorProxy("JP 01", "a-jp.example.com"),
+		}},
+		{Name: "b", Proxies: []map[string]any{
+			testGeneratorProxy("HK 01", "b-hk.example.com"),
+			testGeneratorProxy("JP 01", "b-jp.example.com"),
+		}},
+	}
+	data, err := generateProfile([]byte(testGeneratorTemplate), sources)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var profile testGeneratedProfile
+	if err = yaml.Unmarshal(data, &profile); err != nil {
+		t.Fatal(err)
+	}
+
+	// 与模板和其他订阅重名的节点添加序号
+	var names, servers []string
+	for _, proxy := range profile.Proxies {
+		names = append(names, proxy.Name)
+		servers = append(servers, proxy.Server)
+	}
+	wantNames := []string{"HK 01", "HK 01 2", "Expire: 2026-01-01", "JP 01", "HK 01 3", "JP 01 2"}
+	if !reflect.DeepEqual(names, wantNames) {
+		t.Fatalf("proxies = %v, want %v", names, wantNames)
+	}
+	if servers[1] != "a-hk.example.com" || servers[4] != "b-hk.example.com" {
+		t.Fatalf("servers = %v", servers)
+	}
+	// 不修改订阅中的节点
+	if sources[0].Proxies[0]["name"] != "HK 01" || sources[1].Proxies[0]["name"] != "HK 01" || sources[1].Proxies[1]["name"] != "JP 01" {
+		t.Fatalf("source proxies renamed: %v", sources)
+	}
+
+	groups := make(map[string]any)
+	for _, group := range profile.Groups {
+		if _, ok := group[generatorFilterKey]; ok {
+			t.Fatalf("%s not removed from group %v", generatorFilterKey, group["name"])
+		}
+		if _, ok := group[generatorExcludeKey]; ok {
+			t.Fatalf("%s not removed from group %v", generatorExcludeKey, group["name"])
+		}
+		groups[group["name"].(string)] = group["proxies"]
+	}
+	wantGroups := map[string]any{
+		// 没有筛选条件的代理组保持不变
+		"Proxy": []any{"Auto", "DIRECT"},
+		"Auto":  []any{"HK 01 2", "JP 01", "HK 01 3", "JP 01 2"},
+		// 模板中已有的节点保留在前面
+		"HK": []any{"HK 01", "HK 01 2", "HK 01 3"},
+		// 没有匹配的节点时使用 DIRECT
+		"US": []any{"DIRECT"},
+		// 使用代理集合的组可以为空
+		"Provider": []any{},
+	}
+	if !reflect.DeepEqual(groups, wantGroups) {
+		t.Fatalf("groups = %v, want %v", groups, wantGroups)
+	}
+	if profile.MixedPort != 7890 || len(profile.Rules) != 1 || profile.Rules[0] != "MATCH,Proxy" {
+		t.Fatalf("template fields = %+v", profile)
+	}
+
+	// 再次生成时结果相同
+	again, err := generateProfile([]byte(testGeneratorTemplate), sources)
+	if err != nil || string(again) != string(data) {
+		t.Fatalf("regenerated profile differs: %v\n%s\n%s", err, data, again)
+	}
+}
+
+func TestGenerateProfileErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		err      string
+	}{
+		{"invalid filter", "proxy-groups:\n  - {name: Bad, type: select, gohomo-filter: '('}\n", "proxy group Bad"},
+		{"invalid exclude", "proxy-groups:\n  - {name: Bad, type: select, gohomo-exclude: '['}\n", "proxy group Bad"},
+		{"not a mapping", "- a\n- b\n", "not a yaml mapping"},
+		{"invalid yaml", "proxies: [", "template"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := generateProfile([]byte(tt.template), nil)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+
+	// 空模板生成只包含订阅节点的配置文件
+	data, err := generateProfile(nil, []GeneratorSource{{Name: "a", Proxies: []map[string]any{testGeneratorProxy("SG 01", "sg.example.com")}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var profile testGeneratedProfile
+	if err = yaml.Unmarshal(data, &profile); err != nil || len(profile.Proxies) != 1 || profile.Proxies[0].Name != "SG 01" {
+		t.Fatalf("profile = %+v, %v", profile, err)
+	}
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultGeoDataInterval = 24 * time.Hour // 默认的geodata更新间隔
+
+// GeoDatabase core使用的geodata数据库
+type GeoDatabase struct {
+	Key  string // geox-url 中的键
+	File string // core目录中的文件名
+	Url  string // 下载地址
+}
+
+// core使用的geodata数据库及默认下载地址，与 mihomo 的默认 geox-url 一致
+var geoDatabases = []GeoDatabase{
+	{Key: "geoip", File: "GeoIP.dat", Url: "https://github.com/MetaCubeX/meta-rules-dat/releases/download/latest/geoip.dat"},
+	{Key: "geosite", File: "GeoSite.dat", Url: "https://github.com/MetaCubeX/meta-rules-dat/releases/download/latest/geosite.dat"},
+	{Key: "mmdb", File: "Country.mmdb", Url: "https://github.com/MetaCubeX/meta-rules-dat/releases/download/latest/country.mmdb"},
+	{Key: "asn", File: "ASN.mmdb", Url: "https://github.com/xishang0128/geoip/releases/download/latest/GeoLite2-ASN.mmdb"},
+}
+
+// mmdb 文件元数据标记
+var mmdbMetadataMarker = []byte("\xab\xcd\xefMaxMind.com")
+
+// 直连下载的客户端
+var directClient = &http.Client{
+	Timeout:   10 * time.Minute,
+	Transport: newDirectTransport(),
+}
+
+var geoDataMutex sync.Mutex // geodata更新互斥锁
+
+func newDirectTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	return transport
+}
+
+// GeoDataUpdater geodata数据库更新器
+type GeoDataUpdater struct {
+	Clients []*http.Client // 依次尝试的下载客户端
+	Dir     string         // 数据库所在目录
+}
+
+// NewGeoDataUpdater 创建geodata更新器，优先通过代理下载，失败时直连
+func NewGeoDataUpdater(dir string) *GeoDataUpdater {
+	return &GeoDataUpdater{
+		Clients: []*http.Client{downloadClient, directClient},
+		Dir:     dir,
+	}
+}
+
+// Download 下载并校验数据库到临时文件，返回临时文件路径，内容与现有文件相同时返回空
+func (u *GeoDataUpdater) Download(db GeoDatabase) (string, error) {
+	target := filepath.Join(u.Dir, db.File)
+	tmp := target + ".new"
+	var errs []error
+	for _, client := range u.Clients {
+		sum, err := downloadFile(client, db.Url, tmp, u.fetchChecksum(client, db.Url))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err = validateGeoDatabase(tmp); err != nil {
+			_ = os.Remove(tmp)
+			return "", fmt.Errorf("%s: %w", db.File, err)
+		}
+		if current, err := fileSha256(target); err == nil && current == sum {
+			// 内容未变化，只更新修改时间
+			_ = os.Remove(tmp)
+			now := time.Now()
+			_ = os.Chtimes(target, now, now)
+			return "", nil
+		}
+		return tmp, nil
+	}
+	return "", errors.Join(errs...)
+}
+
+// 获取发布的 .sha256sum 校验文件中的摘要，没有时返回空
+func (u *GeoDataUpdater) fetchChecksum(client *http.Client, url string) string {
+	resp, err := client.Get(url + ".sha256sum")
+	if err != nil {
+		return ""
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return ""
+	}
+	content, err := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if err != nil {
+		return ""
+	}
+	name := url[strings.LastIndex(url, "/")+1:]
+	return findChecksum(string(content), name)
+}
+
+// 校验数据库文件格式
+func validateGeoDatabase(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	switch strings.ToLower(filepath.Ext(path[:len(path)-len(".new")])) {
+	case ".mmdb":
+		if !bytes.Contains(data[max(0, len(data)-128*1024):], mmdbMetadataMarker) {
+			return fmt.Errorf("invalid mmdb file")
+		}
+	case ".dat":
+		// protobuf 编码的列表，第一个字段为长度分隔的条目
+		if len(data) == 0 || data[0] != 0x0a {
+			return fmt.Errorf("invalid dat file")
+		}
+	}
+	return nil
+}
+
+// 获取core配置使用的geodata数据库，geox-url 中配置的地址优先
+func getGeoDatabases() []GeoDatabase {
+	urls := getCoreConfig().GeoxUrl
+	dbs := make([]GeoDatabase, 0, len(geoDatabases))
+	for _, db := range geoDatabases {
+		if url := urls[db.Key]; url != "" {
+			db.Url = url
+		}
+		dbs = append(dbs, db)
+	}
+	return dbs
+}
+
+// 获取geodata的更新间隔，配置为 0 时不定时更新
+func getGeoDataInterval() time.Duration {
+	interval := getAppConfig().GeoData.UpdateInterval
+	if interval == "" {
+		return defaultGeoDataInterval
+	}
+	d, err := time.ParseDuration(interval)
+	if err != nil {
+		log.Println("Invalid geodata update-interval:", interval)
+		return defaultGeoDataInterval
+	}
+	return d
+}
+
+// 更新geodata数据库，force 为 false 时只更新缺失或超过更新间隔的数据库
+// 替换后通知core重新加载配置，core占用文件无法替换时重启core，返回更新的数据库文件名
+func updateGeoData(force bool) ([]string, error) {
+	geoDataMutex.Lock()
+	defer geoDataMutex.Unlock()
+
+	updater := NewGeoDataUpdater(coreDir)
+	interval := getGeoDataInterval()
+	var errs []error
+	var downloaded, names []string
+	for _, db := range getGeoDatabases() {
+		if !force {
+			info, err := os.Stat(filepath.Join(coreDir, db.File))
+			if err == nil && (interval <= 0 || time.Since(info.ModTime()) < interval) {
+				continue
+			}
+		}
+		tmp, err := updater.Download(db)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if tmp != "" {
+			downloaded = append(downloaded, tmp)
+			names = append(names, db.File)
+		}
+	}
+	if len(downloaded) == 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	// 替换数据库文件，core运行中时可能占用文件
+	var locked []string
+	for _, tmp := range downloaded {
+		if err := os.Rename(tmp, strings.TrimSuffix(tmp, ".new")); err != nil {
+			locked = append(locked, tmp)
+		}
+	}
+	if !isCoreRunning() {
+		for _, tmp := range locked {
+			errs = append(errs, os.Rename(tmp, strings.TrimSuffix(tmp, ".new")))
+		}
+		return names, errors.Join(errs...)
+	}
+	if len(locked) > 0 || getCoreConfig().ControllerAddr == "" {
+		// 文件被core占用或无法通过外部控制器重新加载，停止后替换再启动
+		log.Println("Restarting core to load new geodata")
+		if stopCore() {
+			for _, tmp := range locked {
+				errs = append(errs, os.Rename(tmp, strings.TrimSuffix(tmp, ".new")))
+			}
+		}
+		if !startCore() {
+			errs = append(errs, fmt.Errorf("failed to restart core"))
+		}
+		return names, errors.Join(errs...)
+	}
+	// 重新加载运行配置，使core使用新的数据库
+	body, _ := json.Marshal(map[string]string{"path": coreRunConfigPath})
+	if err := controllerJSON(http.MethodPut, "/configs", bytes.NewReader(body), nil); err != nil {
+		errs = append(errs, fmt.Errorf("reload core config: %w", err))
+	}
+	return names, errors.Join(errs...)
+}
+
+// 是否有geodata数据库缺失
+func isGeoDataMissing() bool {
+	for _, db := range geoDatabases {
+		if !isFileExist(filepath.Join(coreDir, db.File)) {
+			return true
+		}
+	}
+	return false
+}
+
+// 更新geodata并通知结果
+func updateGeoDataAndNotify(force, notify bool) error {
+	names, err := updateGeoData(force)
+	if len(names) > 0 {
+		log.Println("GeoData updated:", names)
+		if notify {
+			sendNotification(I.TranSys("msg.info.geodata_updated", map[string]any{"Files": strings.Join(names, ", ")}))
+		}
+	} else if err == nil && notify {
+		sendNotification(I.TranSys("msg.info.geodata_latest", nil))
+	}
+	if err != nil {
+		log.Println("Failed to update geodata:", err)
+	}
+	return err
+}
+
+// 按更新间隔定时更新geodata
+func runGeoDataUpdater() {
+	ticker := time.NewTicker(10 * time.Minute)
+	defer ticker.Stop()
+	for range ticker.C {
+		if getGeoDataInterval() > 0 && isCoreRunning() {
+			_ = updateGeoDataAndNotify(false, false)
+		}
+	}
+}
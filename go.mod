@@ -3,6 +3,7 @@ module github.com/junlongzzz/gohomo
 go 1.25
 
 require (
+	github.com/dop251/goja v0.0.0-20260106131823-651366fbe6e3
 	github.com/energye/systray v1.0.2
 	github.com/fsnotify/fsnotify v1.9.0
 	github.com/go-toast/toast v0.0.0-20190211030409-01e6764cf0a4
@@ -16,10 +17,13 @@ require (
 
 require (
 	github.com/ajg/form v1.5.1 // indirect
+	github.com/dlclark/regexp2 v1.11.4 // indirect
 	github.com/go-chi/chi/v5 v5.2.4 // indirect
 	github.com/go-chi/render v1.0.3 // indirect
+	github.com/go-sourcemap/sourcemap v2.1.3+incompatible // indirect
 	github.com/go-viper/mapstructure/v2 v2.5.0 // indirect
 	github.com/godbus/dbus/v5 v5.2.2 // indirect
+	github.com/google/pprof v0.0.0-20230207041349-798e818bf904 // indirect
 	github.com/nu7hatch/gouuid v0.0.0-20131221200532-179d4d0c4d8d // indirect
 	github.com/pelletier/go-toml/v2 v2.2.4 // indirect
 	github.com/sagikazarmark/locafero v0.12.0 // indirect
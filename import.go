@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/junlongzzz/gohomo/sharelink"
+	"github.com/spf13/viper"
+	"go.yaml.in/yaml/v3"
+)
+
+const (
+	importedProxiesFile = "imported-proxies.yaml" // 从分享链接导入的节点文件，位于core目录
+	importedProxyGroup  = "Imported"              // 包含导入节点的选择组
+)
+
+// 导入节点文件的内容
+type importedProxies struct {
+	Proxies []sharelink.Proxy `yaml:"proxies"`
+}
+
+// 导入节点文件路径
+func getImportedProxiesPath() string {
+	return filepath.Join(coreDir, importedProxiesFile)
+}
+
+// 读取已导入的节点，文件不存在时返回空
+func loadImportedProxies() ([]sharelink.Proxy, error) {
+	data, err := os.ReadFile(getImportedProxiesPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var imported importedProxies
+	if err = yaml.Unmarshal(data, &imported); err != nil {
+		return nil, fmt.Errorf("%s: %w", importedProxiesFile, err)
+	}
+	return imported.Proxies, nil
+}
+
+// 将解析出的节点合并到导入节点文件，同名节点被替换，返回合并后的节点数量
+func saveImportedProxies(proxies []sharelink.Proxy) (int, error) {
+	existing, err := loadImportedProxies()
+	if err != nil {
+		return 0, err
+	}
+	for _, proxy := range proxies {
+		index := slices.IndexFunc(existing, func(p sharelink.Proxy) bool { return p.Name() == proxy.Name() })
+		if index >= 0 {
+			existing[index] = proxy
+		} else {
+			existing = append(existing, proxy)
+		}
+	}
+	out, err := yaml.Marshal(importedProxies{Proxies: existing})
+	if err != nil {
+		return 0, err
+	}
+	return len(existing), os.WriteFile(getImportedProxiesPath(), out, 0644)
+}
+
+// 将导入的节点注入到运行配置的 proxies 中，与配置文件中同名的节点以配置文件为准
+// 导入的节点放入 Imported 选择组，该组加入第一个选择组，规则模式下也可以使用
+// 节点中的占位符在注入时替换，导入节点文件中保持原样
+func injectImportedProxies(v *viper.Viper) error {
+	imported, err := loadImportedProxies()
+	if err != nil {
+		log.Println("Failed to load imported proxies:", err)
+		return nil
+	}
+	if len(imported) == 0 {
+		return nil
+	}
+	proxies, _ := v.Get("proxies").([]any)
+	names := make([]string, 0, len(proxies))
+	for _, proxy := range proxies {
+		if m, ok := proxy.(map[string]any); ok {
+			names = append(names, fmt.Sprint(m["name"]))
+		}
+	}
+	var injected []any
+	for _, proxy := range imported {
+		if slices.Contains(names, proxy.Name()) {
+			log.Println("Skip imported proxy with duplicate name:", proxy.Name())
+			continue
+		}
+		if _, err = expandValuePlaceholders(map[string]any(proxy)); err != nil {
+			return errors.New(I.TranSys("msg.error.core.config.placeholder", map[string]any{
+				"Path":  getImportedProxiesPath(),
+				"Error": fmt.Errorf("%s: %w", proxy.Name(), err),
+			}))
+		}
+		proxies = append(proxies, map[string]any(proxy))
+		injected = append(injected, proxy.Name())
+	}
+	log.Println("Inject imported proxies into running config:", len(injected))
+	v.Set("proxies", proxies)
+	if len(injected) > 0 {
+		injectImportedProxyGroup(v, injected)
+	}
+	return nil
+}
+
+// 在运行配置中添加包含导入节点的选择组，并加入第一个选择组，配置文件中已有同名组时不添加
+func injectImportedProxyGroup(v *viper.Viper, names []any) {
+	groups, _ := v.Get("proxy-groups").([]any)
+	var selector map[string]any
+	for _, group := range groups {
+		m, ok := group.(map[string]any)
+		if !ok {
+			continue
+		}
+		if fmt.Sprint(m["name"]) == importedProxyGroup {
+			log.Println("Skip imported proxy group, name already used:", importedProxyGroup)
+			return
+		}
+		if selector == nil && fmt.Sprint(m["type"]) == "select" {
+			selector = m
+		}
+	}
+	if selector != nil {
+		members, _ := selector["proxies"].([]any)
+		selector["proxies"] = append(members, importedProxyGroup)
+	}
+	groups = append(groups, map[string]any{
+		"name":    importedProxyGroup,
+		"type":    "select",
+		"proxies": names,
+	})
+	v.Set("proxy-groups", groups)
+}
+
+// 从剪贴板导入分享链接，保存后重新加载core配置
+func importClipboardLinks() {
+	content, err := readClipboardText()
+	if err != nil {
+		messageBoxAlert(AppName, I.TranSys("msg.error.import_failed", map[string]any{"Error": err}))
+		return
+	}
+	proxies, err := sharelink.ParseAll(content)
+	if len(proxies) == 0 {
+		messageBoxAlert(AppName, I.TranSys("msg.error.import_failed", map[string]any{"Error": err}))
+		return
+	}
+	if err != nil {
+		// 部分链接解析失败，继续导入成功的节点
+		log.Println("Failed to parse some share links:", err)
+	}
+	total, err := saveImportedProxies(proxies)
+	if err != nil {
+		messageBoxAlert(AppName, I.TranSys("msg.error.import_failed", map[string]any{"Error": err}))
+		return
+	}
+	if err = reloadCoreConfig(); err != nil {
+		messageBoxAlert(AppName, fmt.Sprint(err))
+		return
+	}
+	names := make([]string, 0, len(proxies))
+	for _, proxy := range proxies {
+		names = append(names, proxy.Name())
+	}
+	sendNotification(I.TranSys("msg.info.imported", map[string]any{
+		"Count": len(proxies),
+		"Total": total,
+		"Names": strings.Join(names, ", "),
+	}))
+}
+
+// 重新生成运行配置并通知core重新加载，无法通过外部控制器重新加载时重启core
+func reloadCoreConfig() error {
+	if err := loadCoreConfig(); err != nil {
+		return err
+	}
+	if !isCoreRunning() {
+		return nil
+	}
+	if getCoreConfig().ControllerAddr != "" {
+		body, _ := json.Marshal(map[string]string{"path": coreRunConfigPath})
+		err := controllerJSON(http.MethodPut, "/configs", bytes.NewReader(body), nil)
+		if err == nil {
+			refreshDirectBypass()
+			return nil
+		}
+		log.Println("Failed to reload core config:", err)
+	}
+	if !restartCore() {
+		return errors.New(I.TranSys("msg.error.core.restart_failed", nil))
+	}
+	refreshDirectBypass()
+	return nil
+}
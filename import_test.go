@@ -0,0 +1,95 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+// 使用临时core目录中的导入节点文件生成运行配置
+func injectTestImportedProxies(t *testing.T, config, imported string) *viper.Viper {
+	t.Helper()
+	dir := coreDir
+	coreDir = t.TempDir()
+	t.Cleanup(func() { coreDir = dir })
+	writeTestFile(t, filepath.Join(coreDir, importedProxiesFile), imported)
+
+	v := viper.New()
+	v.SetConfigType("yaml")
+	if err := v.ReadConfig(strings.NewReader(config)); err != nil {
+		t.Fatal(err)
+	}
+	if err := injectImportedProxies(v); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+// 获取运行配置中的代理组成员
+func testGroupMembers(v *viper.Viper) map[string][]any {
+	members := make(map[string][]any)
+	groups, _ := v.Get("proxy-groups").([]any)
+	for _, group := range groups {
+		m := group.(map[string]any)
+		members[m["name"].(string)], _ = m["proxies"].([]any)
+	}
+	return members
+}
+
+const testImportedProxies = `proxies:
+  - {name: HK 01, type: ss, server: 1.2.3.4, port: 8388, cipher: aes-128-gcm, password: a}
+  - {name: Local, type: ss, server: 1.2.3.5, port: 8388, cipher: aes-128-gcm, password: b}
+  - {name: JP 01, type: trojan, server: 1.2.3.6, port: 443, password: c}
+`
+
+func TestInjectImportedProxies(t *testing.T) {
+	v := injectTestImportedProxies(t, `
+proxies:
+  - {name: Local, type: direct}
+proxy-groups:
+  - {name: Auto, type: url-test, proxies: [Local]}
+  - {name: Proxy, type: select, proxies: [Auto, DIRECT]}
+  - {name: Media, type: select, proxies: [Proxy]}
+`, testImportedProxies)
+
+	proxies, _ := v.Get("proxies").([]any)
+	if len(proxies) != 3 {
+		t.Fatalf("proxies = %v", proxies)
+	}
+	// 导入节点放入 Imported 组，该组加入第一个选择组
+	want := map[string][]any{
+		"Auto":             {"Local"},
+		"Proxy":            {"Auto", "DIRECT", importedProxyGroup},
+		"Media":            {"Proxy"},
+		importedProxyGroup: {"HK 01", "JP 01"},
+	}
+	if got := testGroupMembers(v); !reflect.DeepEqual(got, want) {
+		t.Fatalf("groups = %v, want %v", got, want)
+	}
+}
+
+func TestInjectImportedProxiesWithoutSelector(t *testing.T) {
+	v := injectTestImportedProxies(t, "mode: rule\n", testImportedProxies)
+	want := map[string][]any{importedProxyGroup: {"HK 01", "Local", "JP 01"}}
+	if got := testGroupMembers(v); !reflect.DeepEqual(got, want) {
+		t.Fatalf("groups = %v, want %v", got, want)
+	}
+}
+
+func TestInjectImportedProxiesKeepsExistingGroup(t *testing.T) {
+	v := injectTestImportedProxies(t, `
+proxy-groups:
+  - {name: Proxy, type: select, proxies: [DIRECT]}
+  - {name: Imported, type: select, proxies: [DIRECT]}
+`, testImportedProxies)
+	want := map[string][]any{"Proxy": {"DIRECT"}, importedProxyGroup: {"DIRECT"}}
+	if got := testGroupMembers(v); !reflect.DeepEqual(got, want) {
+		t.Fatalf("groups = %v, want %v", got, want)
+	}
+	if proxies, _ := v.Get("proxies").([]any); len(proxies) != 3 {
+		t.Fatalf("proxies = %v", proxies)
+	}
+}
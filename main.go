@@ -26,12 +26,23 @@ var (
 )
 
 func main() {
+	// 更新辅助进程，替换程序文件后退出
+	if len(os.Args) > 1 && os.Args[1] == updateHelperCommand {
+		applyUpdate(os.Args[2:])
+		return
+	}
+
 	// 初始化i18n
 	I = i18n.New()
 	if err := I.Init(); err != nil {
 		fatal("Failed to init i18n:", err)
 	}
 
+	// 命令行子命令，执行后退出
+	if len(os.Args) > 1 {
+		os.Exit(runCli(os.Args[1:]))
+	}
+
 	// 检查是否为单实例
 	checkSingleInstance()
 	defer windows.CloseHandle(lockFileHandle)
@@ -42,6 +53,8 @@ func main() {
 		fatal("Failed to get executable path:", err)
 	}
 	workDir = filepath.Dir(executable)
+	// 清理上次更新的暂存文件
+	go cleanupUpdate()
 
 	logDir = filepath.Join(workDir, "logs")
 	if !isFileExist(logDir) {
@@ -79,6 +92,8 @@ func main() {
 	initAppConfig()
 	// 初始化核心
 	initCore()
+	// 后台检查更新
+	go runUpdateChecker()
 	// 系统托盘
 	initSystray()
 }
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// 匹配所有订阅的过滤规则名称
+const allSubscriptions = "*"
+
+// NodeFilter 订阅节点过滤及重命名规则
+type NodeFilter struct {
+	include *regexp.Regexp
+	exclude *regexp.Regexp
+	renames []nodeRename
+	flag    bool
+}
+
+type nodeRename struct {
+	pattern *regexp.Regexp
+	replace string
+}
+
+// NodeFilterResult 节点过滤结果
+type NodeFilterResult struct {
+	Name    string // 原名称
+	NewName string // 重命名后的名称
+	Kept    bool   // 是否保留
+}
+
+// NewNodeFilter 根据配置创建节点过滤规则
+func NewNodeFilter(config SubscriptionFilterConfig) (*NodeFilter, error) {
+	filter := &NodeFilter{flag: config.Flag}
+	var err error
+	if config.Include != "" {
+		if filter.include, err = regexp.Compile(config.Include); err != nil {
+			return nil, fmt.Errorf("include: %w", err)
+		}
+	}
+	if config.Exclude != "" {
+		if filter.exclude, err = regexp.Compile(config.Exclude); err != nil {
+			return nil, fmt.Errorf("exclude: %w", err)
+		}
+	}
+	for _, rename := range config.Rename {
+		pattern, err := regexp.Compile(rename.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("rename: %w", err)
+		}
+		filter.renames = append(filter.renames, nodeRename{pattern: pattern, replace: rename.Replace})
+	}
+	return filter, nil
+}
+
+// Apply 过滤并重命名节点，先按 include、exclude 筛选，再依次重命名，最后添加地区旗帜
+func (f *NodeFilter) Apply(name string) (string, bool) {
+	if f.include != nil && !f.include.MatchString(name) {
+		return name, false
+	}
+	if f.exclude != nil && f.exclude.MatchString(name) {
+		return name, false
+	}
+	for _, rename := range f.renames {
+		name = rename.pattern.ReplaceAllString(name, rename.replace)
+	}
+	name = strings.TrimSpace(name)
+	if f.flag {
+		name = addRegionFlag(name)
+	}
+	return name, name != ""
+}
+
+// 获取应用于订阅的过滤规则，按配置顺序依次应用
+func getNodeFilters(profile string) ([]*NodeFilter, error) {
+	var filters []*NodeFilter
+	for _, config := range getAppConfig().SubscriptionFilters {
+		if config.Profile != profile && config.Profile != allSubscriptions {
+			continue
+		}
+		filter, err := NewNodeFilter(config)
+		if err != nil {
+			return nil, fmt.Errorf("subscription filter %s: %w", config.Profile, err)
+		}
+		filters = append(filters, filter)
+	}
+	return filters, nil
+}
+
+// 对节点名称依次应用过滤规则，重命名后重名的节点添加序号
+func filterNodeNames(names []string, filters []*NodeFilter) []NodeFilterResult {
+	results := make([]NodeFilterResult, 0, len(names))
+	used := make(map[string]bool)
+	for _, name := range names {
+		result := NodeFilterResult{Name: name, NewName: name, Kept: true}
+		for _, filter := range filters {
+			if result.NewName, result.Kept = filter.Apply(result.NewName); !result.Kept {
+				break
+			}
+		}
+		if result.Kept {
+			unique := result.NewName
+			for i := 2; used[unique]; i++ {
+				unique = fmt.Sprintf("%s %d", result.NewName, i)
+			}
+			used[unique] = true
+			result.NewName = unique
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// 对订阅配置应用过滤规则，删除或重命名 proxies 中的节点，并同步修改代理组中引用的节点名称
+func filterSubscription(data []byte, filters []*NodeFilter) ([]byte, []NodeFilterResult, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, nil, err
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return data, nil, nil
+	}
+	root := doc.Content[0]
+	proxies := mappingValue(root, "proxies", 0)
+	if proxies == nil || proxies.Kind != yaml.SequenceNode {
+		return data, nil, nil
+	}
+
+	var names []string
+	for _, node := range proxies.Content {
+		if name := mappingValue(node, "name", 0); name != nil {
+			names = append(names, name.Value)
+		} else {
+			names = append(names, "")
+		}
+	}
+	results := filterNodeNames(names, filters)
+	renamed := make(map[string]string)
+	kept := proxies.Content[:0]
+	for i, node := range proxies.Content {
+		result := results[i]
+		renamed[result.Name] = ""
+		if !result.Kept {
+			continue
+		}
+		renamed[result.Name] = result.NewName
+		mappingValue(node, "name", 0).Value = result.NewName
+		kept = append(kept, node)
+	}
+	proxies.Content = kept
+
+	// 同步代理组中的节点
+	if groups := mappingValue(root, "proxy-groups", 0); groups != nil {
+		for _, group := range groups.Content {
+			members := mappingValue(group, "proxies", 0)
+			if members == nil || members.Kind != yaml.SequenceNode {
+				continue
+			}
+			content := members.Content[:0]
+			for _, member := range members.Content {
+				newName, ok := renamed[member.Value]
+				if !ok {
+					// 其他代理组或内置策略
+					content = append(content, member)
+				} else if newName != "" {
+					member.Value = newName
+					content = append(content, member)
+				}
+			}
+			members.Content = content
+			if len(content) == 0 && mappingValue(group, "use", 0) == nil &&
+				mappingValue(group, "include-all", 0) == nil && mappingValue(group, "include-all-proxies", 0) == nil {
+				members.Content = append(members.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: "DIRECT"})
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&doc); err != nil {
+		return nil, nil, err
+	}
+	return buf.Bytes(), results, encoder.Close()
+}
+
+// 地区及其在节点名称中的常见写法，两个字母的代码只匹配前后不是字母、前面不是数字的大写形式，
+// 避免匹配 in、us、ca 等单词，以及流量信息中的 GB
+var regionPatterns = []struct {
+	code    string
+	pattern *regexp.Regexp
+}{
+	{"HK", regionPattern(`香港|港|hong ?kong`, "HK")},
+	{"TW", regionPattern(`台湾|台灣|台北|taiwan`, "TW")},
+	{"JP", regionPattern(`日本|东京|東京|大阪|japan|tokyo|osaka`, "JP")},
+	{"SG", regionPattern(`新加坡|狮城|獅城|singapore`, "SG")},
+	{"KR", regionPattern(`韩国|韓國|首尔|首爾|korea|seoul`, "KR")},
+	{"US", regionPattern(`美国|美國|洛杉矶|圣何塞|硅谷|西雅图|united states|america|los angeles`, "US", "USA")},
+	{"GB", regionPattern(`英国|英國|伦敦|united kingdom|britain|london`, "UK")},
+	{"DE", regionPattern(`德国|德國|法兰克福|germany|frankfurt`, "DE")},
+	{"FR", regionPattern(`法国|法國|巴黎|france|paris`, "FR")},
+	{"NL", regionPattern(`荷兰|荷蘭|阿姆斯特丹|netherlands|amsterdam`, "NL")},
+	{"CA", regionPattern(`加拿大|canada|toronto`, "CA")},
+	{"AU", regionPattern(`澳大利亚|澳洲|悉尼|australia|sydney`, "AU")},
+	{"RU", regionPattern(`俄罗斯|俄羅斯|莫斯科|russia|moscow`, "RU")},
+	{"IN", regionPattern(`印度|india|mumbai`, "IN")},
+	{"TR", regionPattern(`土耳其|turkey|istanbul`, "TR")},
+}
+
+// 地区名称不区分大小写，地区代码区分大小写
+func regionPattern(names string, codes ...string) *regexp.Regexp {
+	return regexp.MustCompile(`(?i:` + names + `)|(^|[^A-Za-z0-9])(` + strings.Join(codes, "|") + `)([^A-Za-z]|$)`)
+}
+
+// 根据节点名称识别地区，在名称前添加旗帜，已有旗帜时不添加
+func addRegionFlag(name string) string {
+	if first, _ := utf8.DecodeRuneInString(name); isRegionalIndicator(first) {
+		return name
+	}
+	for _, region := range regionPatterns {
+		if region.pattern.MatchString(name) {
+			return regionFlag(region.code) + " " + name
+		}
+	}
+	return name
+}
+
+// 两个字母的地区代码对应的旗帜
+func regionFlag(code string) string {
+	var flag strings.Builder
+	for _, c := range code {
+		flag.WriteRune(0x1F1E6 + c - 'A')
+	}
+	return flag.String()
+}
+
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1F1E6 && r <= 0x1F1FF
+}
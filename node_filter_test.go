@@ -0,0 +1,185 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"go.yaml.in/yaml/v3"
+)
+
+func TestAddRegionFlag(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"香港 01", "🇭🇰 香港 01"},
+		{"HK-IPLC 02", "🇭🇰 HK-IPLC 02"},
+		{"[Premium] HK01 x1.5", "🇭🇰 [Premium] HK01 x1.5"},
+		{"Hong Kong BGP", "🇭🇰 Hong Kong BGP"},
+		{"台湾家宽", "🇹🇼 台湾家宽"},
+		{"日本 东京 03", "🇯🇵 日本 东京 03"},
+		{"JP|Osaka", "🇯🇵 JP|Osaka"},
+		{"新加坡 02", "🇸🇬 新加坡 02"},
+		{"US 01 | 1x", "🇺🇸 US 01 | 1x"},
+		{"USA Seattle", "🇺🇸 USA Seattle"},
+		{"美国 洛杉矶", "🇺🇸 美国 洛杉矶"},
+		{"UK London", "🇬🇧 UK London"},
+		{"Canada Toronto", "🇨🇦 Canada Toronto"},
+		{"Vancouver CA-1", "🇨🇦 Vancouver CA-1"},
+		{"IN Mumbai", "🇮🇳 IN Mumbai"},
+		{"Istanbul TR", "🇹🇷 Istanbul TR"},
+		{"🇯🇵 Tokyo", "🇯🇵 Tokyo"},
+		// 普通单词和流量信息不是地区代码
+		{"Log in to renew", "Log in to renew"},
+		{"Backup us-west", "Backup us-west"},
+		{"Plus Line 02", "Plus Line 02"},
+		{"Free ca node", "Free ca node"},
+		{"Traffic: 100 GB", "Traffic: 100 GB"},
+		{"Remaining 2.5GB", "Remaining 2.5GB"},
+		{"Expire: 2026-01-01", "Expire: 2026-01-01"},
+		{"Official Website", "Official Website"},
+		{"Cascade", "Cascade"},
+		{"DEMO node", "DEMO node"},
+		{"01HK", "01HK"},
+	}
+	for _, tt := range tests {
+		if got := addRegionFlag(tt.name); got != tt.want {
+			t.Errorf("addRegionFlag(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNodeFilterApply(t *testing.T) {
+	filter, err := NewNodeFilter(SubscriptionFilterConfig{
+		Include: "(?i)hk|jp|香港|日本",
+		Exclude: "(?i)expire|traffic|剩余|到期",
+		Rename: []RenameRuleConfig{
+			{Pattern: `\s*\|.*$`, Replace: ""},
+			{Pattern: `^(\S+)-(\d+).*$`, Replace: "$1 $2"},
+		},
+		Flag: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		want string
+		kept bool
+	}{
+		{"HK-01 IPLC | 1x", "🇭🇰 HK 01", true},
+		{"日本-02", "🇯🇵 日本 02", true},
+		{"香港 03", "🇭🇰 香港 03", true},
+		{"SG-01", "SG-01", false},
+		{"HK traffic reset", "HK traffic reset", false},
+		{"剩余流量：100 GB | 香港", "剩余流量：100 GB | 香港", false},
+		{"| HK", "", false},
+	}
+	for _, tt := range tests {
+		got, kept := filter.Apply(tt.name)
+		if got != tt.want || kept != tt.kept {
+			t.Errorf("Apply(%q) = %q, %v, want %q, %v", tt.name, got, kept, tt.want, tt.kept)
+		}
+	}
+
+	for _, config := range []SubscriptionFilterConfig{
+		{Include: "("},
+		{Exclude: "["},
+		{Rename: []RenameRuleConfig{{Pattern: "(?P<"}}},
+	} {
+		if _, err = NewNodeFilter(config); err == nil {
+			t.Errorf("NewNodeFilter(%+v) expected error", config)
+		}
+	}
+}
+
+func TestFilterNodeNames(t *testing.T) {
+	dropInfo, _ := NewNodeFilter(SubscriptionFilterConfig{Exclude: "官网|到期"})
+	stripSuffix, _ := NewNodeFilter(SubscriptionFilterConfig{Rename: []RenameRuleConfig{{Pattern: `\s*\[.*\]$`}}})
+	results := filterNodeNames([]string{
+		"官网 example.com",
+		"HK 01 [IPLC]",
+		"HK 01 [BGP]",
+		"HK 01",
+		"到期：2026-01-01",
+		"JP 01",
+	}, []*NodeFilter{dropInfo, stripSuffix})
+	want := []NodeFilterResult{
+		{Name: "官网 example.com", NewName: "官网 example.com"},
+		{Name: "HK 01 [IPLC]", NewName: "HK 01", Kept: true},
+		{Name: "HK 01 [BGP]", NewName: "HK 01 2", Kept: true},
+		{Name: "HK 01", NewName: "HK 01 3", Kept: true},
+		{Name: "到期：2026-01-01", NewName: "到期：2026-01-01"},
+		{Name: "JP 01", NewName: "JP 01", Kept: true},
+	}
+	if !reflect.DeepEqual(results, want) {
+		t.Fatalf("filterNodeNames() =\n%+v\nwant\n%+v", results, want)
+	}
+}
+
+func TestFilterSubscription(t *testing.T) {
+	data := []byte(`proxies:
+  - {name: "剩余流量：100 GB", type: ss, server: 0.0.0.0, port: 1, cipher: aes-128-gcm, password: x}
+  - {name: "香港 01 | 1x", type: ss, server: hk.example.com, port: 8388, cipher: aes-128-gcm, password: x}
+  - {name: "Japan 02 | 2x", type: ss, server: jp.example.com, port: 8388, cipher: aes-128-gcm, password: x}
+  - {name: "Log in to renew", type: ss, server: 0.0.0.0, port: 1, cipher: aes-128-gcm, password: x}
+proxy-groups:
+  - {name: Proxy, type: select, proxies: [Auto, "香港 01 | 1x", "Japan 02 | 2x", "剩余流量：100 GB", DIRECT]}
+  - {name: Auto, type: url-test, proxies: ["香港 01 | 1x", "Japan 02 | 2x"]}
+  - {name: Info, type: select, proxies: ["剩余流量：100 GB", "Log in to renew"]}
+  - {name: Provider, type: select, use: [sub], proxies: ["Log in to renew"]}
+rules:
+  - MATCH,Proxy
+`)
+	filter, err := NewNodeFilter(SubscriptionFilterConfig{
+		Exclude: "剩余|renew",
+		Rename:  []RenameRuleConfig{{Pattern: `\s*\|.*$`}},
+		Flag:    true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, results, err := filterSubscription(data, []*NodeFilter{filter})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 4 || results[1].NewName != "🇭🇰 香港 01" || results[2].NewName != "🇯🇵 Japan 02" {
+		t.Fatalf("results = %+v", results)
+	}
+
+	var config struct {
+		Proxies []struct {
+			Name   string `yaml:"name"`
+			Server string `yaml:"server"`
+		} `yaml:"proxies"`
+		Groups []struct {
+			Name    string   `yaml:"name"`
+			Proxies []string `yaml:"proxies"`
+		} `yaml:"proxy-groups"`
+		Rules []string `yaml:"rules"`
+	}
+	if err = yaml.Unmarshal(out, &config); err != nil {
+		t.Fatal(err)
+	}
+	if len(config.Proxies) != 2 || config.Proxies[0].Name != "🇭🇰 香港 01" || config.Proxies[0].Server != "hk.example.com" ||
+		config.Proxies[1].Name != "🇯🇵 Japan 02" {
+		t.Fatalf("proxies = %+v", config.Proxies)
+	}
+	groups := make(map[string][]string)
+	for _, group := range config.Groups {
+		groups[group.Name] = group.Proxies
+	}
+	want := map[string][]string{
+		"Proxy": {"Auto", "🇭🇰 香港 01", "🇯🇵 Japan 02", "DIRECT"},
+		"Auto":  {"🇭🇰 香港 01", "🇯🇵 Japan 02"},
+		// 节点全部删除后使用 DIRECT，使用代理集合的组保持为空
+		"Info":     {"DIRECT"},
+		"Provider": {},
+	}
+	if !reflect.DeepEqual(groups, want) {
+		t.Fatalf("groups = %v, want %v", groups, want)
+	}
+	if len(config.Rules) != 1 || config.Rules[0] != "MATCH,Proxy" {
+		t.Fatalf("rules = %v", config.Rules)
+	}
+}
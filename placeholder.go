@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// 配置中的占位符：${VAR} 替换为环境变量，${file:path} 替换为文件内容，$${...} 转义为 ${...}
+var placeholderPattern = regexp.MustCompile(`\$?\$\{(file:[^}]+|[A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// 替换字符串中的占位符，错误信息中只包含变量名称和文件路径，不包含替换后的值
+func expandPlaceholders(s string) (string, error) {
+	var errs []error
+	result := placeholderPattern.ReplaceAllStringFunc(s, func(match string) string {
+		if strings.HasPrefix(match, "$$") {
+			return match[1:]
+		}
+		name := match[2 : len(match)-1]
+		if path, ok := strings.CutPrefix(name, "file:"); ok {
+			data, err := os.ReadFile(resolveWorkPath(strings.TrimSpace(path)))
+			if err != nil {
+				errs = append(errs, fmt.Errorf("${%s}: %w", name, err))
+				return match
+			}
+			// 忽略文件末尾的换行
+			return strings.TrimRight(string(data), "\r\n")
+		}
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			errs = append(errs, fmt.Errorf("${%s}: environment variable %s is not set", name, name))
+			return match
+		}
+		return value
+	})
+	return result, errors.Join(errs...)
+}
+
+// 替换 yaml 文档中所有字符串的占位符，返回是否有替换
+// 端口等数字键未加引号且只有一个占位符的值按替换后的内容重新识别类型，其他情况替换后仍为字符串
+func expandNodePlaceholders(node *yaml.Node) (bool, error) {
+	changed := false
+	var errs []error
+	var walk func(node *yaml.Node, key string)
+	walk = func(node *yaml.Node, key string) {
+		if node.Kind == yaml.ScalarNode && strings.Contains(node.Value, "${") {
+			value, err := expandPlaceholders(node.Value)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("line %d: %w", node.Line, err))
+				return
+			}
+			if value != node.Value {
+				whole := placeholderPattern.FindString(node.Value) == node.Value
+				if node.Style == 0 && whole && isNumericKey(key) {
+					node.Tag = ""
+				}
+				node.Value = value
+				changed = true
+			}
+		}
+		if node.Kind == yaml.MappingNode {
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				walk(node.Content[i], "")
+				walk(node.Content[i+1], node.Content[i].Value)
+			}
+			return
+		}
+		for _, child := range node.Content {
+			walk(child, "")
+		}
+	}
+	walk(node, "")
+	return changed, errors.Join(errs...)
+}
+
+// 值为数字的键，如 port、mixed-port
+func isNumericKey(key string) bool {
+	return key == "port" || strings.HasSuffix(key, "-port")
+}
+
+// 替换解析后的值中字符串的占位符，用于节点等覆盖配置
+func expandValuePlaceholders(value any) (any, error) {
+	switch v := value.(type) {
+	case string:
+		return expandPlaceholders(v)
+	case map[string]any:
+		var errs []error
+		for key, item := range v {
+			expanded, err := expandValuePlaceholders(item)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", key, err))
+				continue
+			}
+			v[key] = expanded
+		}
+		return v, errors.Join(errs...)
+	case []any:
+		var errs []error
+		for i, item := range v {
+			expanded, err := expandValuePlaceholders(item)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			v[i] = expanded
+		}
+		return v, errors.Join(errs...)
+	default:
+		return value, nil
+	}
+}
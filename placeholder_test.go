@@ -0,0 +1,158 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// 使用临时工作目录中的文件替换占位符
+func setTestWorkDir(t *testing.T) string {
+	t.Helper()
+	dir := workDir
+	workDir = t.TempDir()
+	t.Cleanup(func() { workDir = dir })
+	return workDir
+}
+
+func TestExpandPlaceholders(t *testing.T) {
+	dir := setTestWorkDir(t)
+	writeTestFile(t, filepath.Join(dir, "secrets", "token.txt"), "tok3n\r\n\n")
+	writeTestFile(t, filepath.Join(dir, "abs.txt"), "  spaced  \n")
+	t.Setenv("GOHOMO_TEST_HOST", "example.com")
+	t.Setenv("GOHOMO_TEST_EMPTY", "")
+
+	tests := []struct {
+		input string
+		want  string
+		err   string
+	}{
+		{"https://${GOHOMO_TEST_HOST}/sub", "https://example.com/sub", ""},
+		{"${GOHOMO_TEST_EMPTY}", "", ""},
+		// 文件内容只忽略末尾的换行，路径相对于工作目录
+		{"token=${file:secrets/token.txt}", "token=tok3n", ""},
+		{"${file: " + filepath.Join(dir, "abs.txt") + " }", "  spaced  ", ""},
+		// 转义
+		{"$${GOHOMO_TEST_HOST}", "${GOHOMO_TEST_HOST}", ""},
+		{"$${file:secrets/token.txt}-${GOHOMO_TEST_HOST}", "${file:secrets/token.txt}-example.com", ""},
+		// 不是占位符的内容保持不变
+		{"$HOME ${1abc} ${}", "$HOME ${1abc} ${}", ""},
+		{"${GOHOMO_TEST_MISSING}", "", "environment variable GOHOMO_TEST_MISSING is not set"},
+		{"${file:secrets/missing.txt}", "", "${file:secrets/missing.txt}"},
+	}
+	for _, tt := range tests {
+		got, err := expandPlaceholders(tt.input)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("expandPlaceholders(%q) error = %v, want %q", tt.input, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("expandPlaceholders(%q) = %q, %v, want %q", tt.input, got, err, tt.want)
+		}
+	}
+}
+
+func TestExpandNodePlaceholders(t *testing.T) {
+	setTestWorkDir(t)
+	t.Setenv("GOHOMO_TEST_PORT", "7890")
+	t.Setenv("GOHOMO_TEST_SECRET", "0123")
+	t.Setenv("GOHOMO_TEST_BOOL", "true")
+	config := `mixed-port: ${GOHOMO_TEST_PORT}
+secret: ${GOHOMO_TEST_SECRET}
+allow-lan: ${GOHOMO_TEST_BOOL}
+quoted-port: '${GOHOMO_TEST_PORT}'
+proxies:
+  - name: ${GOHOMO_TEST_PORT}
+    port: ${GOHOMO_TEST_PORT}
+    socks-port: "${GOHOMO_TEST_PORT}"
+    redir-port: ${GOHOMO_TEST_PORT}1
+    password: ${GOHOMO_TEST_SECRET}
+    path: $${GOHOMO_TEST_PORT}
+`
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(config), &doc); err != nil {
+		t.Fatal(err)
+	}
+	changed, err := expandNodePlaceholders(&doc)
+	if err != nil || !changed {
+		t.Fatalf("expandNodePlaceholders = %v, %v", changed, err)
+	}
+	data, err := yaml.Marshal(&doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]any
+	if err = yaml.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	// 只有数字键中未加引号的完整占位符按替换后的内容识别类型
+	want := map[string]any{
+		"mixed-port":  7890,
+		"secret":      "0123",
+		"allow-lan":   "true",
+		"quoted-port": "7890",
+		"proxies": []any{map[string]any{
+			"name":       "7890",
+			"port":       7890,
+			"socks-port": "7890",
+			"redir-port": "78901",
+			"password":   "0123",
+			"path":       "${GOHOMO_TEST_PORT}",
+		}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expanded config = %#v\nwant %#v\n%s", got, want, data)
+	}
+
+	// 没有占位符时不修改
+	doc = yaml.Node{}
+	if err = yaml.Unmarshal([]byte("mixed-port: 7890\n"), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if changed, err = expandNodePlaceholders(&doc); changed || err != nil {
+		t.Fatalf("expandNodePlaceholders = %v, %v", changed, err)
+	}
+}
+
+func TestPlaceholderErrorsHideValues(t *testing.T) {
+	setTestWorkDir(t)
+	const secret = "s3cret-value"
+	t.Setenv("GOHOMO_TEST_SECRET", secret)
+	config := `secret: ${GOHOMO_TEST_SECRET}
+proxies:
+  - name: a
+    password: ${GOHOMO_TEST_SECRET}:${GOHOMO_TEST_MISSING}
+    token: x${file:missing-token.txt}${GOHOMO_TEST_SECRET}
+`
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(config), &doc); err != nil {
+		t.Fatal(err)
+	}
+	_, err := expandNodePlaceholders(&doc)
+	if err == nil {
+		t.Fatal("expandNodePlaceholders succeeded with a missing variable")
+	}
+	// 错误信息包含行号、变量名称和文件路径
+	for _, s := range []string{"line 4", "GOHOMO_TEST_MISSING", "line 5", "missing-token.txt"} {
+		if !strings.Contains(err.Error(), s) {
+			t.Errorf("error %q does not contain %q", err, s)
+		}
+	}
+	if strings.Contains(err.Error(), secret) {
+		t.Errorf("error contains the secret: %q", err)
+	}
+
+	proxy := map[string]any{"name": "a", "password": "${GOHOMO_TEST_SECRET}", "plugin-opts": map[string]any{"host": "${GOHOMO_TEST_SECRET}${GOHOMO_TEST_MISSING}"}}
+	_, err = expandValuePlaceholders(proxy)
+	if err == nil || !strings.Contains(err.Error(), "plugin-opts: host") || strings.Contains(err.Error(), secret) {
+		t.Fatalf("expandValuePlaceholders error = %v", err)
+	}
+	if proxy["password"] != secret {
+		t.Fatalf("password = %v", proxy["password"])
+	}
+}
@@ -0,0 +1,514 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/junlongzzz/gohomo/sharelink"
+	"go.yaml.in/yaml/v3"
+)
+
+const (
+	profilesDir      = "profiles"      // 配置文件目录，位于工作目录
+	profileStateFile = "profiles.json" // 配置文件列表的保存文件
+)
+
+// 配置文件类型
+const (
+	ProfileTypeRemote = "remote" // 从订阅地址下载
+	ProfileTypeLocal  = "local"  // 本地文件
+)
+
+// 下载订阅使用的 User-Agent，订阅服务据此返回 Clash 格式的配置
+const profileUserAgent = "clash.meta"
+
+// Profile 配置文件
+type Profile struct {
+	Name           string    `json:"name"`
+	Type           string    `json:"type"`
+	Url            string    `json:"url,omitempty"`             // 订阅地址
+	File           string    `json:"file"`                      // profiles 目录中的文件名
+	UpdateInterval string    `json:"update-interval,omitempty"` // 订阅的更新间隔，如 24h，为空时不定时更新
+	UpdatedAt      time.Time `json:"updated-at"`
+	Template       string    `json:"template,omitempty"` // 生成配置文件使用的模板
+	Sources        []string  `json:"sources,omitempty"`  // 生成配置文件使用的订阅配置文件名称
+}
+
+// Interval 订阅的更新间隔，未配置或无效时返回 0
+func (p *Profile) Interval() time.Duration {
+	if p.UpdateInterval == "" {
+		return 0
+	}
+	d, err := time.ParseDuration(p.UpdateInterval)
+	if err != nil {
+		log.Println("Invalid profile update-interval:", p.Name, p.UpdateInterval)
+		return 0
+	}
+	return d
+}
+
+// ProfileStore 配置文件列表
+// 命令行和托盘程序是不同的进程，访问时合并其他进程保存到文件中的修改，避免保存时相互覆盖
+type ProfileStore struct {
+	path     string
+	dir      string
+	mutex    sync.Mutex
+	Active   string     `json:"active"` // 正在使用的配置文件名称，为空时使用 config.yaml
+	Profiles []*Profile `json:"profiles"`
+
+	fileInfo   os.FileInfo        // 最近一次加载或保存时的文件信息
+	base       map[string]Profile // 最近一次加载或保存时文件中的配置文件，用于判断本进程的修改
+	baseActive string
+}
+
+// NewProfileStore 从文件加载配置文件列表，dir 为配置文件所在目录
+func NewProfileStore(path, dir string) *ProfileStore {
+	store := &ProfileStore{path: path, dir: dir}
+	store.refresh()
+	return store
+}
+
+// 文件被其他进程修改后重新加载并合并，本进程添加、修改或删除的配置文件以及切换的配置文件以本进程为准
+func (s *ProfileStore) refresh() {
+	info, err := os.Stat(s.path)
+	if err != nil || s.fileInfo != nil && info.ModTime().Equal(s.fileInfo.ModTime()) && info.Size() == s.fileInfo.Size() {
+		return
+	}
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		log.Println("Failed to read profiles:", err)
+		return
+	}
+	var disk struct {
+		Active   string     `json:"active"`
+		Profiles []*Profile `json:"profiles"`
+	}
+	if err = json.Unmarshal(data, &disk); err != nil {
+		log.Println("Failed to parse profiles:", err)
+		return
+	}
+
+	// 本进程添加或修改过的配置文件
+	changed := func(profile *Profile) bool {
+		base, ok := s.base[profile.Name]
+		return !ok || !reflect.DeepEqual(*profile, base)
+	}
+	current := make(map[string]*Profile, len(s.Profiles))
+	for _, profile := range s.Profiles {
+		current[profile.Name] = profile
+	}
+	merged := make([]*Profile, 0, len(disk.Profiles))
+	for _, profile := range disk.Profiles {
+		mine, ok := current[profile.Name]
+		delete(current, profile.Name)
+		switch {
+		case ok && changed(mine):
+			merged = append(merged, mine)
+		case ok:
+			// 原地更新，保持已获取的配置文件指针有效
+			*mine = *profile
+			merged = append(merged, mine)
+		default:
+			// 其他进程添加的加入，本进程删除的不再加入
+			if _, removed := s.base[profile.Name]; !removed {
+				merged = append(merged, profile)
+			}
+		}
+	}
+	for _, profile := range s.Profiles {
+		// 文件中没有的配置文件，本进程添加的保留，其他进程删除的不再保留
+		if _, ok := current[profile.Name]; ok && changed(profile) {
+			merged = append(merged, profile)
+		}
+	}
+	s.Profiles = merged
+	if s.Active == s.baseActive {
+		s.Active = disk.Active
+	}
+	if s.get(s.Active) == nil {
+		s.Active = ""
+	}
+	s.snapshot(info, disk.Active, disk.Profiles)
+}
+
+// 记录文件中的内容
+func (s *ProfileStore) snapshot(info os.FileInfo, active string, profiles []*Profile) {
+	s.fileInfo = info
+	s.baseActive = active
+	s.base = make(map[string]Profile, len(profiles))
+	for _, profile := range profiles {
+		s.base[profile.Name] = *profile
+	}
+}
+
+// Path 配置文件的完整路径
+func (s *ProfileStore) Path(profile *Profile) string {
+	return filepath.Join(s.dir, profile.File)
+}
+
+// Get 根据名称获取配置文件，不存在时返回 nil
+func (s *ProfileStore) Get(name string) *Profile {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.refresh()
+	return s.get(name)
+}
+
+func (s *ProfileStore) get(name string) *Profile {
+	index := slices.IndexFunc(s.Profiles, func(p *Profile) bool { return p.Name == name })
+	if index < 0 {
+		return nil
+	}
+	return s.Profiles[index]
+}
+
+// List 所有配置文件
+func (s *ProfileStore) List() []*Profile {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.refresh()
+	return slices.Clone(s.Profiles)
+}
+
+// ActiveName 正在使用的配置文件名称
+func (s *ProfileStore) ActiveName() string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.refresh()
+	return s.Active
+}
+
+// ActivePath 正在使用的配置文件路径，未选择时返回空
+func (s *ProfileStore) ActivePath() string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.refresh()
+	if profile := s.get(s.Active); profile != nil {
+		return s.Path(profile)
+	}
+	return ""
+}
+
+// Add 添加配置文件，名称重复时添加序号，未指定文件名时根据名称生成
+func (s *ProfileStore) Add(profile *Profile) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.refresh()
+	name := profile.Name
+	for i := 2; s.get(profile.Name) != nil; i++ {
+		profile.Name = fmt.Sprintf("%s %d", name, i)
+	}
+	if profile.File == "" {
+		profile.File = profileFileName(profile.Name)
+		for i := 2; slices.ContainsFunc(s.Profiles, func(p *Profile) bool { return strings.EqualFold(p.File, profile.File) }); i++ {
+			profile.File = profileFileName(fmt.Sprintf("%s %d", profile.Name, i))
+		}
+	}
+	s.Profiles = append(s.Profiles, profile)
+}
+
+// Remove 删除配置文件及其文件，正在使用时切换回 config.yaml
+func (s *ProfileStore) Remove(name string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.refresh()
+	index := slices.IndexFunc(s.Profiles, func(p *Profile) bool { return p.Name == name })
+	if index < 0 {
+		return
+	}
+	_ = os.Remove(s.Path(s.Profiles[index]))
+	s.Profiles = slices.Delete(s.Profiles, index, index+1)
+	if s.Active == name {
+		s.Active = ""
+	}
+}
+
+// SetActive 设置正在使用的配置文件，名称为空时使用 config.yaml
+func (s *ProfileStore) SetActive(name string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.refresh()
+	if name != "" && s.get(name) == nil {
+		return fmt.Errorf("profile not found: %s", name)
+	}
+	s.Active = name
+	return nil
+}
+
+// Save 合并其他进程的修改后保存到文件
+func (s *ProfileStore) Save() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.refresh()
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.WriteFile(s.path, data, 0644); err != nil {
+		return err
+	}
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return err
+	}
+	s.snapshot(info, s.Active, s.Profiles)
+	return nil
+}
+
+// 文件名中不允许的字符
+var profileFileNameRegex = regexp.MustCompile(`[<>:"/\\|?*\x00-\x1f]+`)
+
+// 根据配置文件名称生成文件名
+func profileFileName(name string) string {
+	name = strings.Trim(profileFileNameRegex.ReplaceAllString(name, "_"), " .")
+	if name == "" {
+		name = "profile"
+	}
+	return name + ".yaml"
+}
+
+var (
+	profileStore     *ProfileStore // 配置文件列表
+	profileStoreOnce sync.Once
+)
+
+// 获取配置文件列表
+func getProfileStore() *ProfileStore {
+	profileStoreOnce.Do(func() {
+		profileStore = NewProfileStore(filepath.Join(workDir, profileStateFile), filepath.Join(workDir, profilesDir))
+	})
+	return profileStore
+}
+
+// 下载订阅内容，分享链接格式的订阅转换为只包含 proxies 的配置
+func downloadSubscription(profile *Profile) ([]byte, http.Header, error) {
+	req, err := http.NewRequest(http.MethodGet, profile.Url, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("User-Agent", profileUserAgent)
+	resp, err := downloadClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("%s: %s", profile.Name, resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	// 订阅返回的必须是 yaml 配置或分享链接，避免错误页面覆盖可用的配置
+	var content map[string]any
+	if err = yaml.Unmarshal(data, &content); err != nil || content == nil {
+		proxies, _ := sharelink.ParseAll(string(data))
+		if len(proxies) == 0 {
+			return nil, nil, fmt.Errorf("%s: subscription is not a valid yaml config", profile.Name)
+		}
+		if data, err = yaml.Marshal(importedProxies{Proxies: proxies}); err != nil {
+			return nil, nil, err
+		}
+	}
+	return data, resp.Header, nil
+}
+
+// 下载订阅配置文件，应用节点过滤规则，记录响应头中的订阅流量信息
+func fetchRemoteProfile(store *ProfileStore, profile *Profile) error {
+	data, header, err := downloadSubscription(profile)
+	if err != nil {
+		return err
+	}
+	filters, err := getNodeFilters(profile.Name)
+	if err != nil {
+		return err
+	}
+	if len(filters) > 0 {
+		var results []NodeFilterResult
+		if data, results, err = filterSubscription(data, filters); err != nil {
+			return fmt.Errorf("%s: %w", profile.Name, err)
+		}
+		kept := 0
+		for _, result := range results {
+			if result.Kept {
+				kept++
+			}
+		}
+		log.Printf("Subscription %s filtered: %d kept, %d dropped\n", profile.Name, kept, len(results)-kept)
+	}
+	if err = os.MkdirAll(store.dir, 0755); err != nil {
+		return err
+	}
+	if err = os.WriteFile(store.Path(profile), data, 0644); err != nil {
+		return err
+	}
+	profile.UpdatedAt = time.Now()
+
+	recordSubscriptionUserinfo(profile.Name, header)
+	log.Println("Profile updated:", profile.Name)
+	return nil
+}
+
+// 下载订阅并预览过滤规则的结果，不保存
+func previewSubscriptionFilter(profile *Profile) ([]NodeFilterResult, error) {
+	data, _, err := downloadSubscription(profile)
+	if err != nil {
+		return nil, err
+	}
+	filters, err := getNodeFilters(profile.Name)
+	if err != nil {
+		return nil, err
+	}
+	_, results, err := filterSubscription(data, filters)
+	return results, err
+}
+
+// 在消息框中预览所有订阅的过滤结果
+func showSubscriptionFilterPreview() {
+	var lines []string
+	for _, profile := range getProfileStore().List() {
+		if profile.Type != ProfileTypeRemote {
+			continue
+		}
+		results, err := previewSubscriptionFilter(profile)
+		if err != nil {
+			lines = append(lines, fmt.Sprintf("%s: %v", profile.Name, err))
+			continue
+		}
+		var kept, dropped []string
+		for _, result := range results {
+			if !result.Kept {
+				dropped = append(dropped, result.Name)
+			} else if result.NewName != result.Name {
+				kept = append(kept, result.Name+" → "+result.NewName)
+			} else {
+				kept = append(kept, result.Name)
+			}
+		}
+		lines = append(lines, I.TranSys("msg.info.filter_preview", map[string]any{
+			"Name":    profile.Name,
+			"Kept":    len(kept),
+			"Dropped": len(dropped),
+		}))
+		lines = append(lines, previewLines("+ ", kept)...)
+		lines = append(lines, previewLines("- ", dropped)...)
+	}
+	if len(lines) == 0 {
+		lines = append(lines, I.TranSys("msg.info.profiles_no_remote", nil))
+	}
+	messageBoxAlert(AppName, strings.Join(lines, "\n"))
+}
+
+// 预览的节点行，数量过多时截断
+func previewLines(prefix string, names []string) []string {
+	const limit = 10
+	lines := make([]string, 0, min(len(names), limit)+1)
+	for i, name := range names {
+		if i == limit {
+			lines = append(lines, fmt.Sprintf("%s... (+%d)", prefix, len(names)-limit))
+			break
+		}
+		lines = append(lines, prefix+name)
+	}
+	return lines
+}
+
+// 更新订阅配置文件，force 为 false 时只更新超过更新间隔的配置文件
+// 正在使用的配置文件更新后重新加载core配置，返回更新的配置文件名称
+func updateProfiles(force bool) ([]string, error) {
+	store := getProfileStore()
+	var names []string
+	var errs []error
+	for _, profile := range store.List() {
+		if profile.Type != ProfileTypeRemote {
+			continue
+		}
+		if !force && (profile.Interval() <= 0 || time.Since(profile.UpdatedAt) < profile.Interval()) {
+			continue
+		}
+		if err := fetchRemoteProfile(store, profile); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		names = append(names, profile.Name)
+	}
+	if len(names) == 0 {
+		return nil, errors.Join(errs...)
+	}
+	if err := store.Save(); err != nil {
+		errs = append(errs, err)
+	}
+	// 重新生成使用了这些订阅的配置文件
+	regenerated, err := regenerateProfiles(names)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	if slices.Contains(names, store.ActiveName()) || slices.Contains(regenerated, store.ActiveName()) {
+		if err := reloadCoreConfig(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return names, errors.Join(errs...)
+}
+
+// 更新订阅配置文件并通知结果
+func updateProfilesAndNotify() {
+	names, err := updateProfiles(true)
+	if err != nil {
+		log.Println("Failed to update profiles:", err)
+		messageBoxAlert(AppName, I.TranSys("msg.error.profiles_failed", map[string]any{"Error": err}))
+	}
+	if len(names) > 0 {
+		sendNotification(I.TranSys("msg.info.profiles_updated", map[string]any{"Names": strings.Join(names, ", ")}))
+	}
+}
+
+// 按更新间隔定时更新订阅配置文件
+func runProfileUpdater() {
+	ticker := time.NewTicker(10 * time.Minute)
+	defer ticker.Stop()
+	for range ticker.C {
+		if _, err := updateProfiles(false); err != nil {
+			log.Println("Failed to update profiles:", err)
+		}
+	}
+}
+
+// 切换正在使用的配置文件并重新加载core配置，名称为空时切换回 config.yaml
+func switchProfile(name string) error {
+	store := getProfileStore()
+	previous := store.ActiveName()
+	if err := store.SetActive(name); err != nil {
+		return err
+	}
+	path := store.ActivePath()
+	if path == "" {
+		path = findCoreConfigPath()
+	}
+	if !isFileExist(path) {
+		_ = store.SetActive(previous)
+		return errors.New(I.TranSys("msg.error.core.config.not_found", map[string]any{"Dir1": workDir, "Dir2": coreDir}))
+	}
+	previousPath := coreConfigPath
+	coreConfigPath = path
+	if err := reloadCoreConfig(); err != nil {
+		// 新配置无法使用时恢复
+		coreConfigPath = previousPath
+		_ = store.SetActive(previous)
+		return err
+	}
+	log.Println("Switched profile:", name)
+	return store.Save()
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// 托盘程序和命令行各自加载的配置文件列表，保存时不能覆盖对方的修改
+func TestProfileStoreMergesChangesFromOtherProcess(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, profileStateFile)
+	gui := NewProfileStore(path, dir)
+	gui.Add(&Profile{Name: "A", Type: ProfileTypeLocal})
+	if err := gui.Save(); err != nil {
+		t.Fatal(err)
+	}
+	a := gui.Get("A")
+
+	cli := NewProfileStore(path, dir)
+	cli.Add(&Profile{Name: "B", Type: ProfileTypeRemote, Url: "https://sub.example.com/b"})
+	cli.Get("A").UpdateInterval = "6h"
+	if err := cli.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 未修改的配置文件原地更新
+	if gui.Get("B") == nil || a.UpdateInterval != "6h" {
+		t.Fatalf("changes from other process not loaded: %+v", gui.List())
+	}
+
+	updatedAt := time.Now().Truncate(time.Second)
+	a.UpdatedAt = updatedAt
+	if err := gui.SetActive("A"); err != nil {
+		t.Fatal(err)
+	}
+	cli.Remove("B")
+	cli.Add(&Profile{Name: "C", Type: ProfileTypeLocal})
+	if err := cli.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gui.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	saved := NewProfileStore(path, dir)
+	var names []string
+	for _, profile := range saved.List() {
+		names = append(names, profile.Name)
+	}
+	if len(names) != 2 || names[0] != "A" || names[1] != "C" {
+		t.Fatalf("profiles = %v", names)
+	}
+	if saved.ActiveName() != "A" || !saved.Get("A").UpdatedAt.Equal(updatedAt) || saved.Get("A").UpdateInterval != "6h" {
+		t.Fatalf("saved = %+v, active = %s", saved.Get("A"), saved.ActiveName())
+	}
+
+	// 本进程删除的配置文件不会被其他进程的保存恢复
+	gui.Remove("A")
+	cli.Get("C").UpdateInterval = "1h"
+	if err := cli.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gui.Save(); err != nil {
+		t.Fatal(err)
+	}
+	saved = NewProfileStore(path, dir)
+	if saved.Get("A") != nil || saved.Get("C") == nil || saved.Get("C").UpdateInterval != "1h" || saved.ActiveName() != "" {
+		t.Fatalf("profiles = %+v, active = %s", saved.List(), saved.ActiveName())
+	}
+}
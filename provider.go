@@ -0,0 +1,180 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// 提供者类型，对应外部控制器接口 /providers/{kind}
+const (
+	ProviderKindProxy = "proxies"
+	ProviderKindRule  = "rules"
+)
+
+// Provider 代理集合或规则集合
+type Provider struct {
+	Kind        string    `json:"-"`
+	Name        string    `json:"name"`
+	VehicleType string    `json:"vehicleType"` // HTTP、File、Inline，Compatible 为core内置的集合
+	Behavior    string    `json:"behavior"`    // 规则集合的类型
+	UpdatedAt   time.Time `json:"updatedAt"`
+	RuleCount   int       `json:"ruleCount"`
+	Proxies     []struct {
+		Name string `json:"name"`
+	} `json:"proxies"`
+	SubscriptionInfo *SubscriptionInfo `json:"subscriptionInfo"` // 代理集合的订阅流量信息
+}
+
+// Count 节点或规则数量
+func (p *Provider) Count() int {
+	if p.Kind == ProviderKindProxy {
+		return len(p.Proxies)
+	}
+	return p.RuleCount
+}
+
+// 获取指定类型的提供者列表，按名称排序，忽略core内置的集合
+func fetchProviders(kind string) ([]*Provider, error) {
+	var resp struct {
+		Providers map[string]*Provider `json:"providers"`
+	}
+	if err := controllerJSON(http.MethodGet, "/providers/"+kind, nil, &resp); err != nil {
+		return nil, err
+	}
+	providers := make([]*Provider, 0, len(resp.Providers))
+	for _, provider := range resp.Providers {
+		if provider.VehicleType == "Compatible" {
+			continue
+		}
+		provider.Kind = kind
+		providers = append(providers, provider)
+	}
+	slices.SortFunc(providers, func(a, b *Provider) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return providers, nil
+}
+
+// 获取代理集合和规则集合
+func fetchAllProviders() ([]*Provider, error) {
+	proxies, err := fetchProviders(ProviderKindProxy)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := fetchProviders(ProviderKindRule)
+	if err != nil {
+		return nil, err
+	}
+	return append(proxies, rules...), nil
+}
+
+// 更新提供者
+func updateProvider(provider *Provider) error {
+	return controllerJSON(http.MethodPut, fmt.Sprintf("/providers/%s/%s", provider.Kind, url.PathEscape(provider.Name)), nil, nil)
+}
+
+// 对代理集合进行健康检查，规则集合不支持
+func healthcheckProvider(provider *Provider) error {
+	if provider.Kind != ProviderKindProxy {
+		return fmt.Errorf("healthcheck is not supported for %s providers", provider.Kind)
+	}
+	return controllerJSON(http.MethodGet, fmt.Sprintf("/providers/proxies/%s/healthcheck", url.PathEscape(provider.Name)), nil, nil)
+}
+
+// 对提供者逐个执行操作，返回成功数量以及失败的错误
+func eachProvider(providers []*Provider, fn func(*Provider) error) (int, error) {
+	succeeded := 0
+	var errs []error
+	for _, provider := range providers {
+		if err := fn(provider); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", provider.Name, err))
+			continue
+		}
+		succeeded++
+	}
+	return succeeded, errors.Join(errs...)
+}
+
+// 过滤出支持健康检查的代理集合
+func proxyProviders(providers []*Provider) []*Provider {
+	var result []*Provider
+	for _, provider := range providers {
+		if provider.Kind == ProviderKindProxy {
+			result = append(result, provider)
+		}
+	}
+	return result
+}
+
+// 过滤指定名称的提供者，names 为空时返回全部，有名称不存在时返回错误
+func filterProviders(providers []*Provider, names []string) ([]*Provider, error) {
+	if len(names) == 0 {
+		return providers, nil
+	}
+	var result []*Provider
+	for _, name := range names {
+		found := false
+		for _, provider := range providers {
+			if provider.Name == name {
+				result = append(result, provider)
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("provider not found: %s", name)
+		}
+	}
+	return result, nil
+}
+
+// 格式化提供者的更新时间
+func formatProviderTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format("2006-01-02 15:04")
+}
+
+// 在托盘中对提供者执行操作并通知结果
+func runProviderAction(providers []*Provider, action string) {
+	fn := updateProvider
+	if action == "healthcheck" {
+		fn = healthcheckProvider
+	}
+	succeeded, err := eachProvider(providers, fn)
+	if err != nil {
+		log.Println("Provider", action, "failed:", err)
+	}
+	sendNotification(I.TranSys("msg.info.providers_"+action, map[string]any{
+		"Succeeded": succeeded,
+		"Failed":    len(providers) - succeeded,
+	}))
+}
+
+// 在消息框中显示提供者列表
+func showProviders() {
+	providers, err := fetchAllProviders()
+	if err != nil {
+		messageBoxAlert(AppName, I.TranSys("msg.error.providers_failed", map[string]any{"Error": err}))
+		return
+	}
+	if len(providers) == 0 {
+		messageBoxAlert(AppName, I.TranSys("msg.info.providers_empty", nil))
+		return
+	}
+	lines := make([]string, 0, len(providers))
+	for _, provider := range providers {
+		lines = append(lines, I.TranSys("msg.info.provider_"+provider.Kind, map[string]any{
+			"Name":      provider.Name,
+			"Count":     provider.Count(),
+			"UpdatedAt": formatProviderTime(provider.UpdatedAt),
+		}))
+	}
+	messageBoxAlert(AppName, strings.Join(lines, "\n"))
+}
@@ -0,0 +1,20 @@
+package main
+
+import "testing"
+
+func TestProxyProvidersSkipsRuleProviders(t *testing.T) {
+	providers := []*Provider{
+		{Kind: ProviderKindProxy, Name: "airport"},
+		{Kind: ProviderKindRule, Name: "reject"},
+		{Kind: ProviderKindProxy, Name: "backup"},
+	}
+	got := proxyProviders(providers)
+	if len(got) != 2 || got[0].Name != "airport" || got[1].Name != "backup" {
+		t.Fatalf("proxyProviders() = %v", got)
+	}
+	// 指定规则集合进行健康检查时报错，不能计为成功
+	succeeded, err := eachProvider(providers[1:2], healthcheckProvider)
+	if succeeded != 0 || err == nil {
+		t.Fatalf("eachProvider() = %d, %v", succeeded, err)
+	}
+}
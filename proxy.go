@@ -1,11 +1,24 @@
 package main
 
 import (
+	"fmt"
+	"net"
+	"slices"
 	"strings"
 
 	"github.com/xishang0128/sysproxy-go/sysproxy"
 )
 
+// 系统代理支持的协议
+const (
+	ProxyProtocolHttp  = "http"
+	ProxyProtocolHttps = "https"
+	ProxyProtocolSocks = "socks"
+)
+
+// 默认系统代理协议
+var defaultProxyProtocols = []string{ProxyProtocolHttp, ProxyProtocolHttps}
+
 // 默认代理白名单
 var defaultBypassHosts = []string{
 	"localhost",
@@ -31,6 +44,9 @@ var defaultBypassHosts = []string{
 	"<local>",
 }
 
+// 代理相关的环境变量名称
+var proxyEnvKeys = []string{"HTTP_PROXY", "HTTPS_PROXY", "ALL_PROXY", "NO_PROXY"}
+
 // 获取代理开启状态
 func getProxyEnable() bool {
 	proxyConfig, err := sysproxy.QueryProxySettings("", false)
@@ -58,15 +74,18 @@ func getProxyBypass() string {
 	return proxyConfig.Proxy.Bypass
 }
 
-// 设置代理
-func setProxy(enable bool, host, port, bypass string) bool {
+// 设置代理，servers 为协议到代理地址的映射，如 http -> 127.0.0.1:7890
+func setProxy(enable bool, servers map[string]string, bypass string) bool {
 	var err error
 	if enable {
+		if len(servers) == 0 {
+			return false
+		}
 		if bypass == "" {
 			// 使用默认白名单
 			bypass = strings.Join(defaultBypassHosts, ";")
 		}
-		err = sysproxy.SetProxy(sysproxy.FormatServer(host, port), bypass, "", false)
+		err = sysproxy.SetProxy(formatProxyServers(servers), bypass, "", false)
 	} else {
 		err = sysproxy.DisableProxy("", false)
 	}
@@ -75,5 +94,63 @@ func setProxy(enable bool, host, port, bypass string) bool {
 
 // 取消代理
 func unsetProxy() bool {
-	return setProxy(false, "", "", "")
+	// 恢复开发工具的代理配置
+	restoreToolProxy()
+	return setProxy(false, nil, "")
+}
+
+// 格式化代理服务器地址
+// 所有协议使用同一地址时返回 host:port，否则返回 http=host:port;https=host:port;socks=host:port 形式
+func formatProxyServers(servers map[string]string) string {
+	// http 和 https 使用同一地址且未设置 socks 时，直接使用通用格式
+	if len(servers) == 2 && servers[ProxyProtocolHttp] != "" && servers[ProxyProtocolHttp] == servers[ProxyProtocolHttps] {
+		return servers[ProxyProtocolHttp]
+	}
+
+	protocols := make([]string, 0, len(servers))
+	for protocol := range servers {
+		protocols = append(protocols, protocol)
+	}
+	slices.Sort(protocols)
+
+	parts := make([]string, 0, len(protocols))
+	for _, protocol := range protocols {
+		parts = append(parts, fmt.Sprintf("%s=%s", protocol, servers[protocol]))
+	}
+	return strings.Join(parts, ";")
+}
+
+// 将 Windows 代理白名单转换为 NO_PROXY 环境变量格式
+// 例如 127.* -> 127.0.0.0/8，*.example.com -> .example.com，<local> 会被忽略
+func bypassToNoProxy(bypass []string) string {
+	noProxy := make([]string, 0, len(bypass))
+	for _, host := range bypass {
+		host = strings.TrimSpace(host)
+		if host == "" || host == "<local>" {
+			continue
+		}
+		if strings.HasSuffix(host, ".*") {
+			// IPv4 通配符转换为 CIDR
+			octets := strings.Split(strings.TrimSuffix(host, ".*"), ".")
+			if len(octets) < 4 {
+				ip := make([]string, 4)
+				for i := range ip {
+					if i < len(octets) {
+						ip[i] = octets[i]
+					} else {
+						ip[i] = "0"
+					}
+				}
+				if net.ParseIP(strings.Join(ip, ".")) != nil {
+					host = fmt.Sprintf("%s/%d", strings.Join(ip, "."), len(octets)*8)
+				}
+			}
+		} else if strings.HasPrefix(host, "*.") {
+			host = strings.TrimPrefix(host, "*")
+		}
+		if !slices.Contains(noProxy, host) {
+			noProxy = append(noProxy, host)
+		}
+	}
+	return strings.Join(noProxy, ",")
 }
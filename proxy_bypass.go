@@ -0,0 +1,249 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/netip"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+)
+
+const defaultDirectBypassMaxEntries = 200 // 从配置文件生成的白名单地址数量上限
+
+// 从配置文件生成的白名单缓存，core配置重新加载后重新生成
+var directBypassCache struct {
+	sync.Mutex
+	config *CoreConfig
+	hosts  []string
+}
+
+// 设置系统代理时使用的白名单，启用 direct-bypass 时合并当前配置文件中直连规则的地址
+func getCoreProxyBypass() []string {
+	bypass := getAppConfig().ProxyByPass
+	if len(bypass) == 0 {
+		bypass = defaultBypassHosts
+	}
+	config := getAppConfig().DirectBypass
+	if !config.Enabled {
+		return bypass
+	}
+	limit := config.MaxEntries
+	if limit <= 0 {
+		limit = defaultDirectBypassMaxEntries
+	}
+	merged, dropped := mergeBypassHosts(bypass, getDirectBypassHosts(), limit)
+	if dropped > 0 {
+		log.Printf("Direct bypass hosts exceed the limit of %d, %d dropped", limit, dropped)
+	}
+	return merged
+}
+
+// 启用 direct-bypass 且已开启系统代理时，按重新加载后的配置文件更新白名单
+func refreshDirectBypass() {
+	if getAppConfig().DirectBypass.Enabled && getProxyEnable() {
+		setCoreProxy()
+	}
+}
+
+// 当前运行配置直连规则的地址，包含用户规则和脚本的修改，每次加载core配置后只生成一次
+func getDirectBypassHosts() []string {
+	config := getCoreConfig()
+	directBypassCache.Lock()
+	defer directBypassCache.Unlock()
+	if directBypassCache.config == config {
+		return directBypassCache.hosts
+	}
+	hosts, err := loadDirectBypassHosts(coreRunConfigPath)
+	if err != nil {
+		log.Println("Failed to load direct bypass hosts:", err)
+	}
+	directBypassCache.config = config
+	directBypassCache.hosts = hosts
+	return hosts
+}
+
+// 读取配置文件 path 中策略为 DIRECT 的 DOMAIN、DOMAIN-SUFFIX、IP-CIDR 规则以及 hosts，转换为系统代理白名单格式
+func loadDirectBypassHosts(path string) ([]string, error) {
+	var config struct {
+		Hosts map[string]any `yaml:"hosts"`
+	}
+	if err := readYamlFile(path, &config); err != nil {
+		return nil, err
+	}
+	matcher, err := loadRuleMatcher(path)
+	if err != nil {
+		return nil, err
+	}
+	return directBypassHosts(matcher, config.Hosts), nil
+}
+
+// 从规则和 hosts 生成白名单，地址按前面的规则匹配到非直连策略时不加入
+func directBypassHosts(matcher *RuleMatcher, hosts map[string]any) []string {
+	// 地址按规则顺序实际匹配到直连策略
+	direct := func(target RuleTarget) bool {
+		result := matcher.Match(target)
+		return result.Rule != nil && result.Rule.Policy == "DIRECT"
+	}
+	var bypass []string
+	for i, rule := range matcher.rules {
+		if rule.Policy != "DIRECT" {
+			continue
+		}
+		ruleType, rest, _ := strings.Cut(rule.Text, ",")
+		payload, _, _ := strings.Cut(rest, ",")
+		payload = strings.ToLower(strings.TrimSpace(payload))
+		switch strings.ToUpper(strings.TrimSpace(ruleType)) {
+		case "DOMAIN":
+			if direct(RuleTarget{Host: payload}) {
+				bypass = append(bypass, payload)
+			}
+		case "DOMAIN-SUFFIX":
+			if !direct(RuleTarget{Host: payload}) {
+				continue
+			}
+			bypass = append(bypass, payload)
+			// 前面有子域名的非直连规则时不能使用通配符
+			if !hasProxiedSubdomain(matcher.rules[:i], payload) {
+				bypass = append(bypass, "*."+payload)
+			}
+		case "IP-CIDR":
+			prefix, err := netip.ParsePrefix(payload)
+			if err != nil || !prefix.Addr().Is4() || !direct(RuleTarget{IP: prefix.Addr()}) {
+				continue
+			}
+			bypass = append(bypass, cidrBypassHosts(prefix.Masked())...)
+		}
+	}
+
+	// hosts 中的域名，MATCH 兜底规则不影响
+	names := make([]string, 0, len(hosts))
+	for name := range hosts {
+		names = append(names, strings.ToLower(strings.TrimSpace(name)))
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		host := strings.TrimPrefix(strings.TrimPrefix(name, "+."), "*.")
+		if host == "" || strings.ContainsAny(host, "*+") {
+			continue
+		}
+		result := matcher.Match(RuleTarget{Host: host})
+		if result.Rule != nil && result.Rule.Policy != "DIRECT" && !strings.HasPrefix(strings.ToUpper(result.Rule.Text), "MATCH") {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(name, "+."):
+			bypass = append(bypass, host, "*."+host)
+		case strings.HasPrefix(name, "*."):
+			bypass = append(bypass, "*."+host)
+		default:
+			bypass = append(bypass, host)
+		}
+	}
+	return bypass
+}
+
+// 规则中是否有 domain 子域名的非直连 DOMAIN 或 DOMAIN-SUFFIX 规则
+func hasProxiedSubdomain(rules []*CoreRule, domain string) bool {
+	for _, rule := range rules {
+		ruleType, rest, _ := strings.Cut(rule.Text, ",")
+		payload, _, _ := strings.Cut(rest, ",")
+		switch strings.ToUpper(strings.TrimSpace(ruleType)) {
+		case "DOMAIN", "DOMAIN-SUFFIX":
+			payload = strings.ToLower(strings.TrimSpace(payload))
+			if rule.Policy != "DIRECT" && strings.HasSuffix(payload, "."+domain) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// 将 IPv4 网段转换为白名单通配符，如 10.0.0.0/8 -> 10.*，不是整字节的前缀展开为多个通配符，数量过多时忽略
+func cidrBypassHosts(prefix netip.Prefix) []string {
+	const maxExpand = 16
+	bits := prefix.Bits()
+	if bits == 0 {
+		// 0.0.0.0/0 会绕过全部地址
+		return nil
+	}
+	if bits == 32 {
+		return []string{prefix.Addr().String()}
+	}
+	octets := (bits + 7) / 8
+	count := 1 << (octets*8 - bits)
+	if count > maxExpand {
+		return nil
+	}
+	ip := prefix.Addr().As4()
+	hosts := make([]string, 0, count)
+	for i := range count {
+		parts := make([]string, 0, octets+1)
+		for j := range octets {
+			value := int(ip[j])
+			if j == octets-1 {
+				value += i
+			}
+			parts = append(parts, fmt.Sprint(value))
+		}
+		if octets < 4 {
+			parts = append(parts, "*")
+		}
+		hosts = append(hosts, strings.Join(parts, "."))
+	}
+	return hosts
+}
+
+// 合并白名单，不区分大小写去重，并去掉已被其他通配符覆盖的生成地址，生成的地址最多保留 limit 个，返回合并结果和超出数量
+func mergeBypassHosts(manual, derived []string, limit int) ([]string, int) {
+	var all []string
+	seen := make(map[string]bool)
+	manualCount := 0
+	for i, host := range append(slices.Clone(manual), derived...) {
+		key := strings.ToLower(strings.TrimSpace(host))
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		all = append(all, key)
+		if i < len(manual) {
+			manualCount = len(all)
+		}
+	}
+
+	patterns := make(map[string]*regexp.Regexp)
+	for _, host := range all {
+		if strings.Contains(host, "*") {
+			patterns[host] = bypassPattern(host)
+		}
+	}
+	merged := slices.Clone(all[:manualCount])
+	dropped := 0
+	for _, host := range all[manualCount:] {
+		if bypassCovered(patterns, host) {
+			continue
+		}
+		if len(merged)-manualCount >= limit {
+			dropped++
+			continue
+		}
+		merged = append(merged, host)
+	}
+	return merged, dropped
+}
+
+// 地址是否被其他通配符覆盖，如 a.example.com 被 *.example.com 覆盖
+func bypassCovered(patterns map[string]*regexp.Regexp, host string) bool {
+	for pattern, re := range patterns {
+		if pattern != host && re.MatchString(host) {
+			return true
+		}
+	}
+	return false
+}
+
+// 白名单通配符对应的正则表达式，* 匹配任意字符
+func bypassPattern(host string) *regexp.Regexp {
+	return regexp.MustCompile("^" + strings.ReplaceAll(regexp.QuoteMeta(host), `\*`, ".*") + "$")
+}
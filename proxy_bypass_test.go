@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"net/netip"
+	"reflect"
+	"testing"
+)
+
+// 172.16.0.0/12 展开后的通配符
+func testPrivateBypassHosts() []string {
+	var hosts []string
+	for i := 16; i < 32; i++ {
+		hosts = append(hosts, fmt.Sprintf("172.%d.*", i))
+	}
+	return hosts
+}
+
+func TestCidrBypassHosts(t *testing.T) {
+	tests := []struct {
+		prefix string
+		want   []string
+	}{
+		{"10.0.0.0/8", []string{"10.*"}},
+		{"192.168.0.0/16", []string{"192.168.*"}},
+		{"192.168.1.0/24", []string{"192.168.1.*"}},
+		{"1.2.3.4/32", []string{"1.2.3.4"}},
+		// 不是整字节的前缀展开为多个通配符
+		{"172.16.0.0/12", testPrivateBypassHosts()},
+		{"10.0.0.0/7", []string{"10.*", "11.*"}},
+		{"1.2.3.16/30", []string{"1.2.3.16", "1.2.3.17", "1.2.3.18", "1.2.3.19"}},
+		// 展开超过 16 个时忽略
+		{"100.64.0.0/10", nil},
+		{"1.2.3.0/27", nil},
+		// 0.0.0.0/0 会绕过全部地址
+		{"0.0.0.0/0", nil},
+	}
+	for _, tt := range tests {
+		if got := cidrBypassHosts(netip.MustParsePrefix(tt.prefix)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("cidrBypassHosts(%s) = %v, want %v", tt.prefix, got, tt.want)
+		}
+	}
+	if got := cidrBypassHosts(netip.MustParsePrefix("1.2.3.0/28")); len(got) != 16 || got[0] != "1.2.3.0" || got[15] != "1.2.3.15" {
+		t.Errorf("cidrBypassHosts(1.2.3.0/28) = %v", got)
+	}
+}
+
+func TestMergeBypassHosts(t *testing.T) {
+	manual := []string{"localhost", "10.*", "LOCALHOST", " *.local ", "192.168.*", "192.168.1.*"}
+	derived := []string{
+		"10.1.*",
+		"Example.com",
+		"*.example.com",
+		"a.example.com",
+		"example.com",
+		"x.local",
+		"*.b.example.com",
+		"",
+		"one.test",
+		"two.test",
+		"three.test",
+		"localhost",
+	}
+	tests := []struct {
+		limit   int
+		want    []string
+		dropped int
+	}{
+		// 手动配置的地址全部保留，生成的地址去掉重复和已被通配符覆盖的
+		{10, []string{"localhost", "10.*", "*.local", "192.168.*", "192.168.1.*", "example.com", "*.example.com", "one.test", "two.test", "three.test"}, 0},
+		// 超出数量的生成地址按顺序丢弃
+		{3, []string{"localhost", "10.*", "*.local", "192.168.*", "192.168.1.*", "example.com", "*.example.com", "one.test"}, 2},
+		{1, []string{"localhost", "10.*", "*.local", "192.168.*", "192.168.1.*", "example.com"}, 4},
+	}
+	for _, tt := range tests {
+		got, dropped := mergeBypassHosts(manual, derived, tt.limit)
+		if !reflect.DeepEqual(got, tt.want) || dropped != tt.dropped {
+			t.Errorf("mergeBypassHosts(limit %d) = %v, %d, want %v, %d", tt.limit, got, dropped, tt.want, tt.dropped)
+		}
+	}
+}
+
+func TestDirectBypassHosts(t *testing.T) {
+	matcher := NewRuleMatcher([]string{
+		"DOMAIN,api.corp.example,Proxy",
+		"DOMAIN-SUFFIX,corp.example,DIRECT",
+		"DOMAIN-SUFFIX,Intranet.example,DIRECT",
+		"DOMAIN,blocked.example,REJECT",
+		"DOMAIN-SUFFIX,blocked.example,DIRECT",
+		"DOMAIN,nas.home,DIRECT",
+		"IP-CIDR,172.16.0.0/12,DIRECT,no-resolve",
+		"IP-CIDR6,fd00::/8,DIRECT",
+		// 10.1.0.0/16 被前面的代理规则覆盖，0.0.0.0/0 不加入
+		"IP-CIDR,10.0.0.0/8,Proxy",
+		"IP-CIDR,10.1.0.0/16,DIRECT",
+		"IP-CIDR,0.0.0.0/0,DIRECT",
+		"DOMAIN-KEYWORD,lan,DIRECT",
+		"MATCH,Proxy",
+	}, nil, "")
+	hosts := map[string]any{
+		"router.lan":       "192.168.1.1",
+		"+.svc.example":    "10.0.0.2",
+		"*.dev.example":    "10.0.0.3",
+		"api.corp.example": "10.0.0.4",
+		"blocked.example":  "10.0.0.5",
+		"bad+host":         "10.0.0.6",
+	}
+	want := []string{
+		// 前面有代理的子域名规则时不生成通配符
+		"corp.example",
+		"intranet.example", "*.intranet.example",
+		"nas.home",
+	}
+	want = append(want, testPrivateBypassHosts()...)
+	// hosts 中被 MATCH 以外的非直连规则匹配的域名不加入
+	want = append(want, "*.dev.example", "svc.example", "*.svc.example", "router.lan")
+	if got := directBypassHosts(matcher, hosts); !reflect.DeepEqual(got, want) {
+		t.Fatalf("directBypassHosts = %v\nwant %v", got, want)
+	}
+}
+
+func TestHasProxiedSubdomain(t *testing.T) {
+	matcher := NewRuleMatcher([]string{
+		"DOMAIN,api.corp.example,Proxy",
+		"DOMAIN-SUFFIX,Dev.Home.Example,Proxy",
+		"DOMAIN-SUFFIX,a.lan.example,DIRECT",
+		"DOMAIN-KEYWORD,shop.example,Proxy",
+		"DOMAIN,mycorp.example,Proxy",
+	}, nil, "")
+	tests := []struct {
+		domain string
+		want   bool
+	}{
+		{"corp.example", true},
+		{"home.example", true},
+		{"example", true},
+		// 直连的子域名规则、关键字规则和只是后缀相同的域名不影响
+		{"lan.example", false},
+		{"shop.example", false},
+		{"rp.example", false},
+		{"api.corp.example", false},
+	}
+	for _, tt := range tests {
+		if got := hasProxiedSubdomain(matcher.rules, tt.domain); got != tt.want {
+			t.Errorf("hasProxiedSubdomain(%s) = %v, want %v", tt.domain, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,604 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// 规则匹配结果，离线无法判断时为 ruleUnknown，如需要 DNS 解析或不支持的规则类型
+type ruleResult int
+
+const (
+	ruleNotMatched ruleResult = iota
+	ruleMatched
+	ruleUnknown
+)
+
+// RuleTarget 规则匹配的目标地址
+type RuleTarget struct {
+	Host      string     // 域名，目标为 IP 时为空
+	IP        netip.Addr // 目标 IP，目标为域名时无效
+	Port      int        // 目标端口，未指定时为 0
+	noResolve bool       // 目标为域名时 IP 规则不解析域名，直接视为不匹配
+}
+
+// ParseRuleTarget 解析 host[:port] 或网址形式的匹配目标
+func ParseRuleTarget(s string) (RuleTarget, error) {
+	s = strings.TrimSpace(s)
+	var target RuleTarget
+	host, portStr := s, ""
+	if strings.Contains(s, "://") {
+		u, err := url.Parse(s)
+		if err != nil {
+			return target, err
+		}
+		host, portStr = u.Hostname(), u.Port()
+		if portStr == "" {
+			switch u.Scheme {
+			case "http", "ws":
+				portStr = "80"
+			case "https", "wss":
+				portStr = "443"
+			}
+		}
+	} else if h, p, err := net.SplitHostPort(s); err == nil {
+		host, portStr = h, p
+	}
+	host = strings.ToLower(strings.Trim(host, "[]."))
+	if host == "" {
+		return target, fmt.Errorf("invalid host: %q", s)
+	}
+	if portStr != "" {
+		port, err := strconv.Atoi(portStr)
+		if err != nil || port <= 0 || port > 65535 {
+			return target, fmt.Errorf("invalid port: %q", portStr)
+		}
+		target.Port = port
+	}
+	if ip, err := netip.ParseAddr(host); err == nil {
+		target.IP = ip.Unmap()
+	} else {
+		target.Host = host
+	}
+	return target, nil
+}
+
+// String 目标地址的显示形式
+func (t RuleTarget) String() string {
+	host := t.Host
+	if host == "" {
+		host = t.IP.String()
+	}
+	if t.Port == 0 {
+		return host
+	}
+	return net.JoinHostPort(host, strconv.Itoa(t.Port))
+}
+
+// 规则的匹配条件
+type ruleCondition func(target RuleTarget) ruleResult
+
+// CoreRule core配置中的一条规则
+type CoreRule struct {
+	Index  int    // 在 rules 中的序号，从 1 开始
+	Text   string // 规则原文
+	Policy string // 目标策略
+	Note   string // 无法离线判断的原因，如不支持的规则类型
+	match  ruleCondition
+}
+
+// RuleMatch 规则匹配结果
+type RuleMatch struct {
+	Rule    *CoreRule   // 匹配的规则，没有规则匹配时为空
+	Skipped []*CoreRule // 匹配的规则之前无法离线判断的规则
+}
+
+// RuleProviderConfig core配置中的规则集合
+type RuleProviderConfig struct {
+	Type     string   `yaml:"type"`     // http、file、inline
+	Behavior string   `yaml:"behavior"` // domain、ipcidr、classical
+	Format   string   `yaml:"format"`   // yaml、text、mrs
+	Path     string   `yaml:"path"`     // 规则文件路径，相对于core工作目录
+	Payload  []string `yaml:"payload"`  // inline 规则集合的规则
+}
+
+// RuleMatcher 离线规则匹配器，按顺序匹配core配置中的规则
+type RuleMatcher struct {
+	rules     []*CoreRule
+	providers map[string]RuleProviderConfig
+	baseDir   string
+	ruleSets  map[string]ruleSet
+}
+
+// 已加载的规则集合，加载失败时 err 不为空
+type ruleSet struct {
+	match ruleCondition
+	err   error
+}
+
+// NewRuleMatcher 创建规则匹配器，baseDir 为规则集合文件的相对路径基准
+// 无法解析或不支持的规则不会导致失败，匹配时视为无法判断
+func NewRuleMatcher(rules []string, providers map[string]RuleProviderConfig, baseDir string) *RuleMatcher {
+	m := &RuleMatcher{providers: providers, baseDir: baseDir, ruleSets: make(map[string]ruleSet)}
+	for i, text := range rules {
+		rule := &CoreRule{Index: i + 1, Text: text}
+		match, policy, err := m.parseRule(text, true)
+		if err != nil {
+			rule.Note = err.Error()
+			match = func(RuleTarget) ruleResult { return ruleUnknown }
+		}
+		rule.Policy = policy
+		rule.match = match
+		m.rules = append(m.rules, rule)
+	}
+	return m
+}
+
+// Match 返回目标匹配的第一条规则
+func (m *RuleMatcher) Match(target RuleTarget) RuleMatch {
+	var result RuleMatch
+	for _, rule := range m.rules {
+		switch rule.match(target) {
+		case ruleMatched:
+			result.Rule = rule
+			return result
+		case ruleUnknown:
+			result.Skipped = append(result.Skipped, rule)
+		}
+	}
+	return result
+}
+
+// 解析一条规则，withPolicy 为 false 时是逻辑规则或规则集合中不含策略的子规则
+func (m *RuleMatcher) parseRule(text string, withPolicy bool) (ruleCondition, string, error) {
+	ruleType, rest, _ := strings.Cut(strings.TrimSpace(text), ",")
+	ruleType = strings.ToUpper(strings.TrimSpace(ruleType))
+
+	switch ruleType {
+	case "MATCH":
+		return func(RuleTarget) ruleResult { return ruleMatched }, strings.TrimSpace(rest), nil
+	case "AND", "OR", "NOT":
+		return m.parseLogicRule(ruleType, rest, withPolicy)
+	}
+
+	fields := strings.Split(rest, ",")
+	for i := range fields {
+		fields[i] = strings.TrimSpace(fields[i])
+	}
+	payload, params := fields[0], fields[1:]
+	policy := ""
+	if withPolicy {
+		if len(params) == 0 {
+			return nil, "", fmt.Errorf("missing policy")
+		}
+		policy, params = params[0], params[1:]
+	}
+	noResolve := false
+	for _, param := range params {
+		switch param {
+		case "no-resolve":
+			noResolve = true
+		case "src":
+			return nil, policy, fmt.Errorf("source address rules are not supported")
+		}
+	}
+
+	var match ruleCondition
+	switch ruleType {
+	case "DOMAIN":
+		domain := strings.ToLower(payload)
+		match = domainCondition(func(host string) bool { return host == domain })
+	case "DOMAIN-SUFFIX":
+		suffix := strings.ToLower(payload)
+		match = domainCondition(func(host string) bool { return host == suffix || strings.HasSuffix(host, "."+suffix) })
+	case "DOMAIN-KEYWORD":
+		keyword := strings.ToLower(payload)
+		match = domainCondition(func(host string) bool { return strings.Contains(host, keyword) })
+	case "DOMAIN-REGEX":
+		regex, err := regexp.Compile(payload)
+		if err != nil {
+			return nil, policy, err
+		}
+		match = domainCondition(regex.MatchString)
+	case "IP-CIDR", "IP-CIDR6":
+		prefix, err := netip.ParsePrefix(payload)
+		if err != nil {
+			return nil, policy, err
+		}
+		match = ipCondition([]netip.Prefix{prefix.Masked()})
+	case "DST-PORT":
+		ranges, err := parsePortRanges(payload)
+		if err != nil {
+			return nil, policy, err
+		}
+		match = func(target RuleTarget) ruleResult {
+			if target.Port == 0 {
+				return ruleUnknown
+			}
+			for _, r := range ranges {
+				if target.Port >= r[0] && target.Port <= r[1] {
+					return ruleMatched
+				}
+			}
+			return ruleNotMatched
+		}
+	case "RULE-SET":
+		set := m.loadRuleSet(payload)
+		if set.err != nil {
+			return nil, policy, fmt.Errorf("rule set %s: %w", payload, set.err)
+		}
+		match = set.match
+	default:
+		return nil, policy, fmt.Errorf("unsupported rule type: %s", ruleType)
+	}
+
+	if noResolve {
+		return func(target RuleTarget) ruleResult {
+			target.noResolve = true
+			return match(target)
+		}, policy, nil
+	}
+	return match, policy, nil
+}
+
+// 解析逻辑规则，如 AND,((DOMAIN,example.com),(DST-PORT,443)),PROXY
+func (m *RuleMatcher) parseLogicRule(ruleType, rest string, withPolicy bool) (ruleCondition, string, error) {
+	rest = strings.TrimSpace(rest)
+	end := closingParen(rest)
+	if !strings.HasPrefix(rest, "(") || end < 0 {
+		return nil, "", fmt.Errorf("invalid %s rule payload", ruleType)
+	}
+	payload, tail := rest[1:end], strings.TrimSpace(rest[end+1:])
+	policy := ""
+	if withPolicy {
+		policy, _, _ = strings.Cut(strings.TrimPrefix(tail, ","), ",")
+		policy = strings.TrimSpace(policy)
+		if policy == "" {
+			return nil, "", fmt.Errorf("missing policy")
+		}
+	}
+
+	var conditions []ruleCondition
+	for payload = strings.TrimSpace(payload); payload != ""; {
+		end := closingParen(payload)
+		if !strings.HasPrefix(payload, "(") || end < 0 {
+			return nil, policy, fmt.Errorf("invalid %s rule payload", ruleType)
+		}
+		condition, _, err := m.parseRule(payload[1:end], false)
+		if err != nil {
+			return nil, policy, err
+		}
+		conditions = append(conditions, condition)
+		payload = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(payload[end+1:]), ","))
+	}
+	if len(conditions) == 0 || (ruleType == "NOT" && len(conditions) != 1) {
+		return nil, policy, fmt.Errorf("invalid %s rule payload", ruleType)
+	}
+
+	switch ruleType {
+	case "AND":
+		return func(target RuleTarget) ruleResult {
+			result := ruleMatched
+			for _, condition := range conditions {
+				switch condition(target) {
+				case ruleNotMatched:
+					return ruleNotMatched
+				case ruleUnknown:
+					result = ruleUnknown
+				}
+			}
+			return result
+		}, policy, nil
+	case "OR":
+		return anyCondition(conditions), policy, nil
+	default:
+		return func(target RuleTarget) ruleResult {
+			switch conditions[0](target) {
+			case ruleMatched:
+				return ruleNotMatched
+			case ruleNotMatched:
+				return ruleMatched
+			}
+			return ruleUnknown
+		}, policy, nil
+	}
+}
+
+// 任一条件匹配即匹配
+func anyCondition(conditions []ruleCondition) ruleCondition {
+	return func(target RuleTarget) ruleResult {
+		result := ruleNotMatched
+		for _, condition := range conditions {
+			switch condition(target) {
+			case ruleMatched:
+				return ruleMatched
+			case ruleUnknown:
+				result = ruleUnknown
+			}
+		}
+		return result
+	}
+}
+
+// 域名规则，目标为 IP 时不匹配
+func domainCondition(match func(host string) bool) ruleCondition {
+	return func(target RuleTarget) ruleResult {
+		if target.Host != "" && match(target.Host) {
+			return ruleMatched
+		}
+		return ruleNotMatched
+	}
+}
+
+// IP 规则，目标为域名时需要 DNS 解析才能判断，no-resolve 时不匹配
+func ipCondition(prefixes []netip.Prefix) ruleCondition {
+	return func(target RuleTarget) ruleResult {
+		if !target.IP.IsValid() {
+			if target.noResolve {
+				return ruleNotMatched
+			}
+			return ruleUnknown
+		}
+		for _, prefix := range prefixes {
+			if prefix.Contains(target.IP) {
+				return ruleMatched
+			}
+		}
+		return ruleNotMatched
+	}
+}
+
+// 返回以左括号开头的字符串中与之匹配的右括号位置，没有时返回 -1
+func closingParen(s string) int {
+	depth := 0
+	for i, c := range s {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// 解析端口范围，如 80/443/8000-9000
+func parsePortRanges(s string) ([][2]int, error) {
+	var ranges [][2]int
+	for _, part := range strings.Split(s, "/") {
+		from, to, found := strings.Cut(strings.TrimSpace(part), "-")
+		start, err := strconv.Atoi(from)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port: %q", part)
+		}
+		end := start
+		if found {
+			if end, err = strconv.Atoi(to); err != nil {
+				return nil, fmt.Errorf("invalid port: %q", part)
+			}
+		}
+		ranges = append(ranges, [2]int{start, end})
+	}
+	return ranges, nil
+}
+
+// 加载规则集合，同一集合只加载一次
+func (m *RuleMatcher) loadRuleSet(name string) ruleSet {
+	if set, ok := m.ruleSets[name]; ok {
+		return set
+	}
+	set := ruleSet{}
+	set.match, set.err = m.compileRuleSet(name)
+	m.ruleSets[name] = set
+	return set
+}
+
+func (m *RuleMatcher) compileRuleSet(name string) (ruleCondition, error) {
+	provider, ok := m.providers[name]
+	if !ok {
+		return nil, fmt.Errorf("rule provider not found")
+	}
+	payload := provider.Payload
+	if provider.Type != "inline" {
+		if provider.Path == "" {
+			return nil, fmt.Errorf("no local path for %s provider", provider.Type)
+		}
+		format := provider.Format
+		if format == "" {
+			switch strings.ToLower(filepath.Ext(provider.Path)) {
+			case ".mrs":
+				format = "mrs"
+			case ".txt", ".list":
+				format = "text"
+			}
+		}
+		if format == "mrs" {
+			return nil, fmt.Errorf("mrs format is not supported")
+		}
+		path := provider.Path
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(m.baseDir, path)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if payload, err = parseRuleSetPayload(data, format); err != nil {
+			return nil, err
+		}
+	}
+
+	switch provider.Behavior {
+	case "domain":
+		return domainSetCondition(payload), nil
+	case "ipcidr":
+		var prefixes []netip.Prefix
+		for _, line := range payload {
+			prefix, err := netip.ParsePrefix(line)
+			if err != nil {
+				return nil, err
+			}
+			prefixes = append(prefixes, prefix.Masked())
+		}
+		return ipCondition(prefixes), nil
+	case "classical":
+		var conditions []ruleCondition
+		for _, line := range payload {
+			condition, _, err := m.parseRule(line, false)
+			if err != nil {
+				// 与core一致，忽略无法解析的规则
+				continue
+			}
+			conditions = append(conditions, condition)
+		}
+		return anyCondition(conditions), nil
+	default:
+		return nil, fmt.Errorf("unsupported behavior: %s", provider.Behavior)
+	}
+}
+
+// 解析规则集合文件，yaml 格式为 payload 列表，text 格式为每行一条规则
+func parseRuleSetPayload(data []byte, format string) ([]string, error) {
+	if format != "text" {
+		var content struct {
+			Payload []string `yaml:"payload"`
+		}
+		if err := yaml.Unmarshal(data, &content); err != nil {
+			return nil, err
+		}
+		return content.Payload, nil
+	}
+	var payload []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			payload = append(payload, line)
+		}
+	}
+	return payload, scanner.Err()
+}
+
+// 域名规则集合，+.example.com 匹配域名及其子域名，.example.com 只匹配子域名，*.example.com 只匹配一级子域名
+func domainSetCondition(payload []string) ruleCondition {
+	exact := make(map[string]bool)
+	var suffixes, subdomains, wildcards []string
+	for _, domain := range payload {
+		domain = strings.ToLower(strings.TrimSpace(domain))
+		switch {
+		case strings.HasPrefix(domain, "+."):
+			suffixes = append(suffixes, domain[2:])
+		case strings.HasPrefix(domain, "."):
+			subdomains = append(subdomains, domain)
+		case strings.HasPrefix(domain, "*."):
+			wildcards = append(wildcards, domain[1:])
+		case domain != "":
+			exact[domain] = true
+		}
+	}
+	return domainCondition(func(host string) bool {
+		if exact[host] {
+			return true
+		}
+		for _, suffix := range suffixes {
+			if host == suffix || strings.HasSuffix(host, "."+suffix) {
+				return true
+			}
+		}
+		for _, subdomain := range subdomains {
+			if strings.HasSuffix(host, subdomain) {
+				return true
+			}
+		}
+		for _, wildcard := range wildcards {
+			if label, ok := strings.CutSuffix(host, wildcard); ok && label != "" && !strings.Contains(label, ".") {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// core配置中规则相关的字段
+type coreRulesConfig struct {
+	Rules         []string                      `yaml:"rules"`
+	RuleProviders map[string]RuleProviderConfig `yaml:"rule-providers"`
+}
+
+// 读取配置文件中的规则创建匹配器，用户规则在配置文件的规则之前，规则集合文件相对于core工作目录
+func loadRuleMatcher(path string) (*RuleMatcher, error) {
+	var config coreRulesConfig
+	if err := readYamlFile(path, &config); err != nil {
+		return nil, err
+	}
+	rules, err := loadUserRules()
+	if err != nil {
+		return nil, err
+	}
+	return NewRuleMatcher(append(rules, config.Rules...), config.RuleProviders, coreDir), nil
+}
+
+// 格式化匹配结果
+func formatRuleMatch(target RuleTarget, match RuleMatch) string {
+	var lines []string
+	if match.Rule == nil {
+		lines = append(lines, I.TranSys("msg.info.rule_no_match", map[string]any{"Target": target.String()}))
+	} else {
+		lines = append(lines, I.TranSys("msg.info.rule_match", map[string]any{
+			"Target": target.String(),
+			"Index":  match.Rule.Index,
+			"Rule":   match.Rule.Text,
+			"Policy": match.Rule.Policy,
+		}))
+	}
+	if len(match.Skipped) > 0 {
+		lines = append(lines, I.TranSys("msg.info.rule_skipped", map[string]any{"Count": len(match.Skipped)}))
+		for i, rule := range match.Skipped {
+			if i == 10 {
+				lines = append(lines, fmt.Sprintf("  ... (+%d)", len(match.Skipped)-i))
+				break
+			}
+			line := fmt.Sprintf("  #%d %s", rule.Index, rule.Text)
+			if rule.Note != "" {
+				line += " (" + rule.Note + ")"
+			}
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// 在托盘中匹配剪贴板中的域名或网址
+func showClipboardRuleMatch() {
+	text, err := readClipboardText()
+	if err == nil && strings.TrimSpace(text) == "" {
+		err = fmt.Errorf("clipboard is empty")
+	}
+	var target RuleTarget
+	if err == nil {
+		target, err = ParseRuleTarget(strings.Fields(text)[0])
+	}
+	var matcher *RuleMatcher
+	if err == nil {
+		matcher, err = loadRuleMatcher(coreConfigPath)
+	}
+	if err != nil {
+		messageBoxAlert(AppName, I.TranSys("msg.error.rule_match_failed", map[string]any{"Error": err}))
+		return
+	}
+	messageBoxAlert(AppName, formatRuleMatch(target, matcher.Match(target)))
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"net/netip"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseRuleTarget(t *testing.T) {
+	tests := []struct {
+		input string
+		want  RuleTarget
+		ok    bool
+	}{
+		{"Example.COM.", RuleTarget{Host: "example.com"}, true},
+		{"example.com:8080", RuleTarget{Host: "example.com", Port: 8080}, true},
+		{"https://www.example.com/path?q=1", RuleTarget{Host: "www.example.com", Port: 443}, true},
+		{"http://example.com", RuleTarget{Host: "example.com", Port: 80}, true},
+		{"wss://example.com:8443", RuleTarget{Host: "example.com", Port: 8443}, true},
+		{"1.1.1.1", RuleTarget{IP: netip.MustParseAddr("1.1.1.1")}, true},
+		{"[::ffff:10.0.0.1]:53", RuleTarget{IP: netip.MustParseAddr("10.0.0.1"), Port: 53}, true},
+		{"[2001:db8::1]:443", RuleTarget{IP: netip.MustParseAddr("2001:db8::1"), Port: 443}, true},
+		{"example.com:0", RuleTarget{}, false},
+		{"example.com:70000", RuleTarget{}, false},
+		{"https://:443", RuleTarget{}, false},
+		{" ", RuleTarget{}, false},
+	}
+	for _, tt := range tests {
+		got, err := ParseRuleTarget(tt.input)
+		if (err == nil) != tt.ok {
+			t.Errorf("ParseRuleTarget(%q) error = %v", tt.input, err)
+			continue
+		}
+		if tt.ok && got != tt.want {
+			t.Errorf("ParseRuleTarget(%q) = %+v, want %+v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestRuleMatcherMatch(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "ruleset", "ads.yaml"), "payload:\n  - '+.ads.example'\n  - '.track.example'\n  - '*.cdn.example'\n  - pixel.example\n")
+	writeTestFile(t, filepath.Join(dir, "ruleset", "lan.txt"), "# 局域网\n10.0.0.0/8\n\n192.168.0.0/16\n")
+	writeTestFile(t, filepath.Join(dir, "ruleset", "games.list"), "DOMAIN-SUFFIX,steampowered.com\nDST-PORT,27015-27050\nUNKNOWN-TYPE,foo\n")
+	providers := map[string]RuleProviderConfig{
+		"ads":    {Type: "file", Behavior: "domain", Path: "ruleset/ads.yaml"},
+		"lan":    {Type: "file", Behavior: "ipcidr", Path: "ruleset/lan.txt"},
+		"games":  {Type: "http", Behavior: "classical", Path: "ruleset/games.list"},
+		"inline": {Type: "inline", Behavior: "classical", Payload: []string{"DOMAIN,inline.example", "AND,((DOMAIN-KEYWORD,dev),(DST-PORT,8080))"}},
+		"remote": {Type: "http", Behavior: "domain", Path: "ruleset/missing.yaml"},
+		"binary": {Type: "file", Behavior: "domain", Path: "ruleset/geosite.mrs"},
+	}
+	rules := []string{
+		"RULE-SET,ads,REJECT",
+		"DOMAIN,exact.example,DIRECT",
+		"DOMAIN-REGEX,^api[0-9]+\\.example\\.org$,API",
+		"RULE-SET,remote,REJECT",
+		"RULE-SET,binary,REJECT",
+		"AND,((OR,((DOMAIN-SUFFIX,corp.example),(DOMAIN-KEYWORD,intranet))),(NOT,((DST-PORT,80/443)))),Office",
+		"OR,((DST-PORT,22),(DST-PORT,3389-3390)),Admin",
+		"RULE-SET,inline,Inline",
+		"RULE-SET,games,Games",
+		"IP-CIDR,1.1.1.0/24,DNS,no-resolve",
+		"SRC-IP-CIDR,192.168.1.2/32,DIRECT",
+		"RULE-SET,lan,DIRECT",
+		"IP-CIDR6,2001:db8::/32,IPv6",
+		"GEOIP,CN,DIRECT",
+		"MATCH,Proxy",
+	}
+	m := NewRuleMatcher(rules, providers, dir)
+
+	tests := []struct {
+		target  string
+		index   int   // 匹配的规则序号
+		skipped []int // 匹配前无法判断的规则序号
+	}{
+		// 规则集合文件
+		{"ads.example", 1, []int{}},
+		{"x.y.ads.example", 1, []int{}},
+		{"a.track.example", 1, []int{}},
+		{"img.cdn.example", 1, []int{}},
+		{"pixel.example", 1, []int{}},
+		{"exact.example", 2, []int{}},
+		{"api12.example.org:443", 3, []int{}},
+		// 嵌套的逻辑规则，无法加载的规则集合视为无法判断
+		{"git.corp.example:8443", 6, []int{4, 5}},
+		{"my-intranet.example:9000", 6, []int{4, 5}},
+		{"server.example:22", 7, []int{4, 5}},
+		{"server.example:3390", 7, []int{4, 5}},
+		// inline 规则集合中的逻辑规则，没有端口时端口规则无法判断
+		{"inline.example", 8, []int{4, 5, 7}},
+		{"dev.example:8080", 8, []int{4, 5}},
+		{"store.steampowered.com", 9, []int{4, 5, 7}},
+		{"1.2.3.4:27020", 9, []int{4, 5}},
+		// no-resolve 的 IP 规则对域名不匹配，其他 IP 规则需要解析
+		{"1.1.1.1:853", 10, []int{4, 5}},
+		{"10.1.2.3", 12, []int{4, 5, 7, 9, 11}},
+		{"[2001:db8::1]:443", 13, []int{4, 5, 11}},
+		// 没有其他规则匹配时使用 MATCH
+		{"8.8.8.8:53", 15, []int{4, 5, 11, 14}},
+		{"other.example:443", 15, []int{4, 5, 11, 12, 13, 14}},
+		// NOT 排除了 443 端口
+		{"https://git.corp.example", 15, []int{4, 5, 11, 12, 13, 14}},
+	}
+	for _, tt := range tests {
+		target, err := ParseRuleTarget(tt.target)
+		if err != nil {
+			t.Fatal(err)
+		}
+		match := m.Match(target)
+		if match.Rule == nil {
+			t.Errorf("Match(%s) = nil, want rule %d", tt.target, tt.index)
+			continue
+		}
+		if match.Rule.Index != tt.index {
+			t.Errorf("Match(%s) = rule %d %q, want rule %d", tt.target, match.Rule.Index, match.Rule.Text, tt.index)
+		}
+		skipped := []int{}
+		for _, rule := range match.Skipped {
+			skipped = append(skipped, rule.Index)
+		}
+		if !reflect.DeepEqual(skipped, tt.skipped) {
+			t.Errorf("Match(%s) skipped = %v, want %v", tt.target, skipped, tt.skipped)
+		}
+	}
+
+	// 策略和无法解析的原因
+	wantPolicies := []string{"REJECT", "DIRECT", "API", "REJECT", "REJECT", "Office", "Admin", "Inline", "Games", "DNS", "DIRECT", "DIRECT", "IPv6", "DIRECT", "Proxy"}
+	for i, rule := range m.rules {
+		if rule.Policy != wantPolicies[i] {
+			t.Errorf("rule %d policy = %q, want %q", rule.Index, rule.Policy, wantPolicies[i])
+		}
+		if unknown := rule.Note != ""; unknown != (i == 3 || i == 4 || i == 10 || i == 13) {
+			t.Errorf("rule %d note = %q", rule.Index, rule.Note)
+		}
+	}
+}
+
+func TestRuleMatcherNoMatch(t *testing.T) {
+	m := NewRuleMatcher([]string{
+		"DST-PORT,443,Proxy",
+		"AND,((DOMAIN,example.com),(IP-CIDR,10.0.0.0/8)),Proxy",
+		"DOMAIN-SUFFIX,example.org",
+		"OR,((DOMAIN,a.example)",
+	}, nil, "")
+	target, _ := ParseRuleTarget("example.com")
+	match := m.Match(target)
+	if match.Rule != nil {
+		t.Fatalf("Match = rule %d, want nil", match.Rule.Index)
+	}
+	// 没有端口时端口规则无法判断，AND 中有条件无法判断时整体无法判断，缺少策略的规则无法解析
+	if len(match.Skipped) != 4 {
+		t.Fatalf("skipped = %d, want 4", len(match.Skipped))
+	}
+	if note := m.rules[2].Note; note != "missing policy" {
+		t.Fatalf("note = %q", note)
+	}
+}
+
+func TestParsePortRanges(t *testing.T) {
+	tests := []struct {
+		input string
+		want  [][2]int
+		ok    bool
+	}{
+		{"443", [][2]int{{443, 443}}, true},
+		{"80/443/8000-9000", [][2]int{{80, 80}, {443, 443}, {8000, 9000}}, true},
+		{" 22 / 3389-3390 ", [][2]int{{22, 22}, {3389, 3390}}, true},
+		{"http", nil, false},
+		{"80-", nil, false},
+		{"", nil, false},
+	}
+	for _, tt := range tests {
+		got, err := parsePortRanges(tt.input)
+		if (err == nil) != tt.ok {
+			t.Errorf("parsePortRanges(%q) error = %v", tt.input, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parsePortRanges(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestDomainSetCondition(t *testing.T) {
+	match := domainSetCondition([]string{"+.example.com", ".sub.example.org", "*.wild.example.net", "Exact.example.io"})
+	tests := []struct {
+		host string
+		want ruleResult
+	}{
+		{"example.com", ruleMatched},
+		{"a.b.example.com", ruleMatched},
+		{"badexample.com", ruleNotMatched},
+		{"sub.example.org", ruleNotMatched},
+		{"a.sub.example.org", ruleMatched},
+		{"a.b.sub.example.org", ruleMatched},
+		{"wild.example.net", ruleNotMatched},
+		{"a.wild.example.net", ruleMatched},
+		{"a.b.wild.example.net", ruleNotMatched},
+		{"exact.example.io", ruleMatched},
+		{"www.exact.example.io", ruleNotMatched},
+	}
+	for _, tt := range tests {
+		if got := match(RuleTarget{Host: tt.host}); got != tt.want {
+			t.Errorf("match(%s) = %d, want %d", tt.host, got, tt.want)
+		}
+	}
+	// 域名规则集合不匹配 IP
+	if got := match(RuleTarget{IP: netip.MustParseAddr("1.1.1.1")}); got != ruleNotMatched {
+		t.Errorf("match(1.1.1.1) = %d", got)
+	}
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/dop251/goja"
+	"go.yaml.in/yaml/v3"
+)
+
+const (
+	scriptsDir           = "scripts" // 配置转换脚本目录，位于程序所在目录
+	scriptMain           = "main"    // 脚本的入口函数 main(config, profileName)
+	defaultScriptTimeout = 5 * time.Second
+)
+
+// ConfigScript 配置转换脚本
+type ConfigScript struct {
+	Name   string // 脚本文件名
+	Source string // 脚本内容
+}
+
+// 读取脚本目录中的 .js 脚本，按文件名排序，目录不存在时返回空
+func loadConfigScripts(dir string) ([]ConfigScript, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var scripts []ConfigScript
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.EqualFold(filepath.Ext(entry.Name()), ".js") {
+			continue
+		}
+		source, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		scripts = append(scripts, ConfigScript{Name: entry.Name(), Source: string(source)})
+	}
+	slices.SortFunc(scripts, func(a, b ConfigScript) int { return strings.Compare(a.Name, b.Name) })
+	return scripts, nil
+}
+
+// 运行脚本转换配置，脚本的 main 函数返回新的配置，返回 undefined 时使用修改后的传入配置
+// 脚本运行在独立的虚拟机中，不提供文件和网络访问，超过 timeout 时中断
+func runConfigScript(script ConfigScript, config map[string]any, profileName string, timeout time.Duration) (map[string]any, error) {
+	program, err := goja.Compile(script.Name, script.Source, false)
+	if err != nil {
+		// 语法错误中包含脚本名称和行列号
+		return nil, err
+	}
+	vm := goja.New()
+	console := vm.NewObject()
+	_ = console.Set("log", func(call goja.FunctionCall) goja.Value {
+		args := make([]string, 0, len(call.Arguments))
+		for _, arg := range call.Arguments {
+			args = append(args, arg.String())
+		}
+		log.Println("Script", script.Name+":", strings.Join(args, " "))
+		return goja.Undefined()
+	})
+	_ = vm.Set("console", console)
+
+	timer := time.AfterFunc(timeout, func() {
+		vm.Interrupt(fmt.Errorf("timed out after %s", timeout))
+	})
+	defer timer.Stop()
+
+	if _, err = vm.RunProgram(program); err != nil {
+		return nil, scriptError(err)
+	}
+	mainFunc, ok := goja.AssertFunction(vm.Get(scriptMain))
+	if !ok {
+		return nil, fmt.Errorf("%s: function %s(config, profileName) not found", script.Name, scriptMain)
+	}
+
+	// 通过 json 转换为 js 对象，脚本中可以像普通对象一样修改
+	data, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+	parse, _ := goja.AssertFunction(vm.Get("JSON").ToObject(vm).Get("parse"))
+	input, err := parse(goja.Undefined(), vm.ToValue(string(data)))
+	if err != nil {
+		return nil, err
+	}
+	result, err := mainFunc(goja.Undefined(), input, vm.ToValue(profileName))
+	if err != nil {
+		return nil, scriptError(err)
+	}
+	if goja.IsUndefined(result) || goja.IsNull(result) {
+		result = input
+	}
+	output, ok := result.Export().(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%s: %s must return an object, got %s", script.Name, scriptMain, result.ExportType())
+	}
+	return output, nil
+}
+
+// 脚本运行错误，保留包含脚本行号的调用栈
+func scriptError(err error) error {
+	var exception *goja.Exception
+	if errors.As(err, &exception) {
+		return errors.New(strings.TrimSpace(exception.String()))
+	}
+	var interrupted *goja.InterruptedError
+	if errors.As(err, &interrupted) {
+		return errors.New(strings.TrimSpace(interrupted.String()))
+	}
+	return err
+}
+
+// 依次运行脚本目录中的脚本转换配置内容，未启用或没有脚本时返回原内容
+func transformCoreConfig(data []byte, profileName string) ([]byte, error) {
+	config := getAppConfig().Scripts
+	if !config.Enabled {
+		return data, nil
+	}
+	scripts, err := loadConfigScripts(filepath.Join(workDir, scriptsDir))
+	if err != nil || len(scripts) == 0 {
+		return data, err
+	}
+	timeout := defaultScriptTimeout
+	if config.Timeout != "" {
+		if timeout, err = time.ParseDuration(config.Timeout); err != nil {
+			return nil, err
+		}
+	}
+
+	var content map[string]any
+	if err = yaml.Unmarshal(data, &content); err != nil {
+		return nil, err
+	}
+	for _, script := range scripts {
+		if content, err = runConfigScript(script, content, profileName, timeout); err != nil {
+			return nil, err
+		}
+		log.Println("Config transformed by script:", script.Name)
+	}
+	return yaml.Marshal(content)
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"go.yaml.in/yaml/v3"
+)
+
+func runTestScript(t *testing.T, source string, timeout time.Duration) (map[string]any, error) {
+	t.Helper()
+	config := map[string]any{
+		"mixed-port": 7890,
+		"proxies":    []any{map[string]any{"name": "HK 01"}, map[string]any{"name": "US 01"}},
+	}
+	return runConfigScript(ConfigScript{Name: "test.js", Source: source}, config, "work", timeout)
+}
+
+func TestRunConfigScript(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   map[string]any
+		err    string
+	}{
+		{
+			"return new config",
+			`function main(config, profileName) {
+  return { port: config["mixed-port"] + 1, profile: profileName, names: config.proxies.map(function (p) { return p.name }) }
+}`,
+			map[string]any{"port": int64(7891), "profile": "work", "names": []any{"HK 01", "US 01"}},
+			"",
+		},
+		{
+			// 返回 undefined 时使用修改后的传入配置
+			"return undefined",
+			`function main(config) {
+  config.proxies = config.proxies.filter(function (p) { return /HK/.test(p.name) })
+  delete config["mixed-port"]
+}`,
+			map[string]any{"proxies": []any{map[string]any{"name": "HK 01"}}},
+			"",
+		},
+		{
+			"return null",
+			`function main(config) { config.mode = "rule"; return null }`,
+			map[string]any{"mixed-port": int64(7890), "mode": "rule", "proxies": []any{map[string]any{"name": "HK 01"}, map[string]any{"name": "US 01"}}},
+			"",
+		},
+		{"return number", `function main() { return 1 }`, nil, "must return an object"},
+		{"return array", `function main(config) { return config.proxies }`, nil, "must return an object"},
+		{"return string", `function main() { return "mode: rule" }`, nil, "must return an object"},
+		{"missing main", `function transform(config) { return config }`, nil, "test.js: function main(config, profileName) not found"},
+		// 错误中包含脚本名称和行号
+		{"syntax error", "function main(config) {\n  return config.\n}", nil, "test.js: Line 3"},
+		{"top-level error", "var a = 1\nundefinedFunction()\n", nil, "test.js:2"},
+		{
+			"runtime error",
+			`function main(config) {
+  var hk = config.proxies[5]
+  return hk.name
+}`,
+			nil,
+			"at main (test.js:3",
+		},
+		{"thrown error", "function main() {\n\n  throw new Error(\"bad config\")\n}", nil, "Error: bad config\n\tat main (test.js:3:"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := runTestScript(t, tt.source, time.Second)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("error = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("config = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunConfigScriptTimeout(t *testing.T) {
+	for _, source := range []string{
+		"while (true) {}",
+		"function main() { for (;;) {} }",
+	} {
+		start := time.Now()
+		_, err := runTestScript(t, source, 50*time.Millisecond)
+		if err == nil || !strings.Contains(err.Error(), "timed out after 50ms") {
+			t.Fatalf("%s: error = %v", source, err)
+		}
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Fatalf("%s: interrupted after %s", source, elapsed)
+		}
+	}
+}
+
+func TestTransformCoreConfig(t *testing.T) {
+	setTestWorkDir(t)
+	previous := appConfig.Load()
+	t.Cleanup(func() {
+		if previous != nil {
+			appConfig.Store(previous)
+		}
+	})
+	data := []byte("mixed-port: 7890\nsecret: ${SECRET}\n")
+
+	// 未启用时返回原内容
+	appConfig.Store(&AppConfig{})
+	writeTestFile(t, filepath.Join(workDir, scriptsDir, "10-port.js"), `function main(config) { config["mixed-port"] += 1 }`)
+	if got, err := transformCoreConfig(data, ""); err != nil || string(got) != string(data) {
+		t.Fatalf("disabled: %s, %v", got, err)
+	}
+
+	// 按文件名顺序运行，后面的脚本看到前面脚本的结果，占位符保持不变
+	appConfig.Store(&AppConfig{Scripts: ScriptsConfig{Enabled: true}})
+	writeTestFile(t, filepath.Join(workDir, scriptsDir, "20-mode.js"), `function main(config, name) { config.mode = name + ":" + config["mixed-port"] }`)
+	writeTestFile(t, filepath.Join(workDir, scriptsDir, "readme.txt"), "not a script")
+	got, err := transformCoreConfig(data, "work")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var config map[string]any
+	if err = yaml.Unmarshal(got, &config); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{"mixed-port": 7891, "secret": "${SECRET}", "mode": "work:7891"}
+	if !reflect.DeepEqual(config, want) {
+		t.Fatalf("config = %#v, want %#v", config, want)
+	}
+
+	// 任一脚本出错时停止
+	writeTestFile(t, filepath.Join(workDir, scriptsDir, "30-slow.js"), "function main() { for (;;) {} }")
+	appConfig.Store(&AppConfig{Scripts: ScriptsConfig{Enabled: true, Timeout: "20ms"}})
+	if _, err = transformCoreConfig(data, ""); err == nil || !strings.Contains(err.Error(), "timed out after 20ms") {
+		t.Fatalf("error = %v", err)
+	}
+	appConfig.Store(&AppConfig{Scripts: ScriptsConfig{Enabled: true, Timeout: "soon"}})
+	if _, err = transformCoreConfig(data, ""); err == nil {
+		t.Fatal("invalid timeout accepted")
+	}
+}
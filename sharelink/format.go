@@ -0,0 +1,271 @@
+package sharelink
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Format 将节点转换为分享链接，是 Parse 的逆过程
+func Format(proxy Proxy) (string, error) {
+	server, _ := proxy["server"].(string)
+	port, _ := proxy["port"].(int)
+	if server == "" || port == 0 {
+		return "", fmt.Errorf("missing server or port")
+	}
+	switch proxy.Type() {
+	case "ss":
+		return formatShadowsocks(proxy), nil
+	case "vmess":
+		return formatVmess(proxy)
+	case "vless":
+		return formatVless(proxy), nil
+	case "trojan":
+		return formatTrojan(proxy), nil
+	case "hysteria2":
+		return formatHysteria2(proxy), nil
+	case "tuic":
+		return formatTuic(proxy), nil
+	}
+	return "", fmt.Errorf("unsupported proxy type: %s", proxy.Type())
+}
+
+// 生成链接的公共部分
+func formatURL(proxy Proxy, scheme string, user *url.Userinfo, query url.Values) string {
+	u := url.URL{
+		Scheme:   scheme,
+		User:     user,
+		Host:     net.JoinHostPort(str(proxy["server"]), strconv.Itoa(proxy["port"].(int))),
+		RawQuery: query.Encode(),
+		Fragment: proxy.Name(),
+	}
+	if len(query) > 0 {
+		u.Path = "/"
+	}
+	return u.String()
+}
+
+func formatShadowsocks(proxy Proxy) string {
+	userinfo := base64.RawURLEncoding.EncodeToString([]byte(str(proxy["cipher"]) + ":" + str(proxy["password"])))
+	query := url.Values{}
+	if plugin := str(proxy["plugin"]); plugin != "" {
+		opts, _ := proxy["plugin-opts"].(map[string]any)
+		parts := []string{plugin}
+		switch plugin {
+		case "obfs":
+			parts[0] = "obfs-local"
+			parts = appendParam(parts, "obfs", opts["mode"])
+			parts = appendParam(parts, "obfs-host", opts["host"])
+		case "v2ray-plugin":
+			parts = appendParam(parts, "mode", opts["mode"])
+			parts = appendParam(parts, "host", opts["host"])
+			parts = appendParam(parts, "path", opts["path"])
+			if opts["tls"] == true {
+				parts = append(parts, "tls")
+			}
+			if opts["mux"] == true {
+				parts = append(parts, "mux")
+			}
+		default:
+			keys := make([]string, 0, len(opts))
+			for key := range opts {
+				keys = append(keys, key)
+			}
+			slices.Sort(keys)
+			for _, key := range keys {
+				parts = append(parts, key+"="+str(opts[key]))
+			}
+		}
+		query.Set("plugin", strings.Join(parts, ";"))
+	}
+	return formatURL(proxy, "ss", url.User(userinfo), query)
+}
+
+// 值不为空时追加插件参数
+func appendParam(parts []string, key string, value any) []string {
+	if s := str(value); s != "" {
+		parts = append(parts, key+"="+s)
+	}
+	return parts
+}
+
+func formatVmess(proxy Proxy) (string, error) {
+	v := vmessLink{
+		Ps:   proxy.Name(),
+		Add:  str(proxy["server"]),
+		Port: strconv.Itoa(proxy["port"].(int)),
+		Id:   str(proxy["uuid"]),
+		Aid:  strconv.Itoa(integer(proxy["alterId"])),
+		Scy:  str(proxy["cipher"]),
+		Net:  "tcp",
+	}
+	if proxy["tls"] == true {
+		v.Tls = "tls"
+		v.Sni = str(proxy["servername"])
+		v.Fp = str(proxy["client-fingerprint"])
+		v.Alpn = strings.Join(list(proxy["alpn"]), ",")
+		if proxy["skip-cert-verify"] == true {
+			v.AllowInsecure = "1"
+		}
+	}
+	network, host, path, serviceName := formatTransport(proxy)
+	switch network {
+	case "":
+	case "http":
+		v.Type = "http"
+	case "grpc":
+		v.Net = network
+		path = serviceName
+	default:
+		v.Net = network
+	}
+	v.Host, v.Path = host, path
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return "vmess://" + base64.StdEncoding.EncodeToString(data), nil
+}
+
+func formatVless(proxy Proxy) string {
+	query := url.Values{"encryption": {"none"}}
+	setQuery(query, "flow", proxy["flow"])
+	if proxy["tls"] == true {
+		query.Set("security", "tls")
+		if opts, ok := proxy["reality-opts"].(map[string]any); ok {
+			query.Set("security", "reality")
+			setQuery(query, "pbk", opts["public-key"])
+			setQuery(query, "sid", opts["short-id"])
+		}
+		setQuery(query, "sni", proxy["servername"])
+		setQuery(query, "fp", proxy["client-fingerprint"])
+		setTlsQuery(query, proxy)
+	}
+	setTransportQuery(query, proxy)
+	return formatURL(proxy, "vless", url.User(str(proxy["uuid"])), query)
+}
+
+func formatTrojan(proxy Proxy) string {
+	query := url.Values{}
+	setQuery(query, "sni", proxy["sni"])
+	setQuery(query, "fp", proxy["client-fingerprint"])
+	setTlsQuery(query, proxy)
+	setTransportQuery(query, proxy)
+	return formatURL(proxy, "trojan", url.User(str(proxy["password"])), query)
+}
+
+func formatHysteria2(proxy Proxy) string {
+	query := url.Values{}
+	setQuery(query, "mport", proxy["ports"])
+	setQuery(query, "sni", proxy["sni"])
+	setQuery(query, "obfs", proxy["obfs"])
+	setQuery(query, "obfs-password", proxy["obfs-password"])
+	setQuery(query, "pinSHA256", proxy["fingerprint"])
+	setTlsQuery(query, proxy)
+	return formatURL(proxy, "hysteria2", url.User(str(proxy["password"])), query)
+}
+
+func formatTuic(proxy Proxy) string {
+	query := url.Values{}
+	setQuery(query, "congestion_control", proxy["congestion-controller"])
+	setQuery(query, "udp_relay_mode", proxy["udp-relay-mode"])
+	setQuery(query, "sni", proxy["sni"])
+	setTlsQuery(query, proxy)
+	if proxy["disable-sni"] == true {
+		query.Set("disable_sni", "1")
+	}
+	return formatURL(proxy, "tuic", url.UserPassword(str(proxy["uuid"]), str(proxy["password"])), query)
+}
+
+// 获取节点的传输方式及伪装域名、路径和 grpc 服务名
+func formatTransport(proxy Proxy) (network, host, path, serviceName string) {
+	network = str(proxy["network"])
+	switch network {
+	case "ws":
+		opts, _ := proxy["ws-opts"].(map[string]any)
+		headers, _ := opts["headers"].(map[string]any)
+		host, path = str(headers["Host"]), str(opts["path"])
+		if opts["v2ray-http-upgrade"] == true {
+			network = "httpupgrade"
+		}
+	case "grpc":
+		opts, _ := proxy["grpc-opts"].(map[string]any)
+		serviceName = str(opts["grpc-service-name"])
+	case "h2":
+		opts, _ := proxy["h2-opts"].(map[string]any)
+		host, path = strings.Join(list(opts["host"]), ","), str(opts["path"])
+	case "http":
+		opts, _ := proxy["http-opts"].(map[string]any)
+		headers, _ := opts["headers"].(map[string]any)
+		host, path = strings.Join(list(headers["Host"]), ","), strings.Join(list(opts["path"]), ",")
+	}
+	return
+}
+
+// 设置链接中的传输方式参数
+func setTransportQuery(query url.Values, proxy Proxy) {
+	network, host, path, serviceName := formatTransport(proxy)
+	switch network {
+	case "":
+		return
+	case "http":
+		query.Set("type", "tcp")
+		query.Set("headerType", "http")
+	default:
+		query.Set("type", network)
+	}
+	setQuery(query, "host", host)
+	setQuery(query, "path", path)
+	setQuery(query, "serviceName", serviceName)
+}
+
+// 设置链接中的 alpn 和跳过证书验证参数
+func setTlsQuery(query url.Values, proxy Proxy) {
+	setQuery(query, "alpn", strings.Join(list(proxy["alpn"]), ","))
+	if proxy["skip-cert-verify"] == true {
+		query.Set("insecure", "1")
+	}
+}
+
+// 值不为空时设置参数
+func setQuery(query url.Values, key string, value any) {
+	if s := str(value); s != "" {
+		query.Set(key, s)
+	}
+}
+
+func str(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	}
+	return fmt.Sprint(v)
+}
+
+func integer(v any) int {
+	i, _ := v.(int)
+	return i
+}
+
+func list(v any) []string {
+	switch v := v.(type) {
+	case []string:
+		return v
+	case []any:
+		result := make([]string, 0, len(v))
+		for _, item := range v {
+			result = append(result, str(item))
+		}
+		return result
+	case string:
+		return splitList(v)
+	}
+	return nil
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const subscriptionStateFile = "subscriptions.json" // 订阅流量信息的保存文件
+
+// 默认的订阅提醒配置
+var (
+	defaultUsageThresholds = []int{80, 95}
+	defaultExpireDays      = 3
+)
+
+// SubscriptionInfo 订阅流量信息，字段与core代理集合中的 subscriptionInfo 一致
+type SubscriptionInfo struct {
+	Upload   int64 `json:"Upload"`
+	Download int64 `json:"Download"`
+	Total    int64 `json:"Total"`  // 总流量，0 表示不限
+	Expire   int64 `json:"Expire"` // 到期时间戳（秒），0 表示不过期
+}
+
+// Used 已用流量
+func (s *SubscriptionInfo) Used() int64 {
+	return s.Upload + s.Download
+}
+
+// UsagePercent 已用流量百分比，不限流量时返回 0
+func (s *SubscriptionInfo) UsagePercent() float64 {
+	if s.Total <= 0 {
+		return 0
+	}
+	return float64(s.Used()) * 100 / float64(s.Total)
+}
+
+// ExpireTime 到期时间，不过期时返回零值
+func (s *SubscriptionInfo) ExpireTime() time.Time {
+	if s.Expire <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(s.Expire, 0)
+}
+
+// 解析订阅响应头 subscription-userinfo，如 upload=1; download=2; total=3; expire=4
+func parseSubscriptionUserinfo(header string) (*SubscriptionInfo, error) {
+	info := new(SubscriptionInfo)
+	found := false
+	for _, field := range strings.Split(header, ";") {
+		key, value, ok := strings.Cut(strings.TrimSpace(field), "=")
+		if !ok {
+			continue
+		}
+		// 部分订阅返回浮点数
+		number, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			continue
+		}
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "upload":
+			info.Upload = int64(number)
+		case "download":
+			info.Download = int64(number)
+		case "total":
+			info.Total = int64(number)
+		case "expire":
+			info.Expire = int64(number)
+		default:
+			continue
+		}
+		found = true
+	}
+	if !found {
+		return nil, fmt.Errorf("invalid subscription-userinfo: %q", header)
+	}
+	return info, nil
+}
+
+// SubscriptionState 保存的订阅流量信息及提醒状态
+type SubscriptionState struct {
+	SubscriptionInfo
+	UpdatedAt      time.Time `json:"updated-at"`
+	NotifiedUsage  int       `json:"notified-usage"`  // 已提醒的最高用量阈值
+	NotifiedExpire int64     `json:"notified-expire"` // 已提醒即将到期的到期时间
+}
+
+// SubscriptionStore 订阅流量信息存储，按订阅或代理集合名称保存
+type SubscriptionStore struct {
+	path   string
+	mutex  sync.Mutex
+	states map[string]*SubscriptionState
+}
+
+// NewSubscriptionStore 从文件加载订阅流量信息
+func NewSubscriptionStore(path string) *SubscriptionStore {
+	store := &SubscriptionStore{path: path, states: make(map[string]*SubscriptionState)}
+	if data, err := os.ReadFile(path); err == nil {
+		if err = json.Unmarshal(data, &store.states); err != nil {
+			log.Println("Failed to parse subscription state:", err)
+		}
+	}
+	return store
+}
+
+// SubscriptionAlert 订阅需要发送的提醒
+type SubscriptionAlert struct {
+	Usage  bool // 用量超过了新的阈值
+	Expire bool // 即将到期
+}
+
+// Update 更新订阅流量信息，返回需要发送的提醒
+// thresholds 为用量百分比阈值，expireDays 为到期前提醒的天数
+func (s *SubscriptionStore) Update(name string, info *SubscriptionInfo, thresholds []int, expireDays int) SubscriptionAlert {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	state, ok := s.states[name]
+	if !ok {
+		state = new(SubscriptionState)
+		s.states[name] = state
+	}
+	state.SubscriptionInfo = *info
+	state.UpdatedAt = time.Now()
+
+	var alert SubscriptionAlert
+	usage := info.UsagePercent()
+	crossed := 0
+	for _, threshold := range thresholds {
+		if threshold > 0 && usage >= float64(threshold) {
+			crossed = max(crossed, threshold)
+		}
+	}
+	alert.Usage = crossed > state.NotifiedUsage
+	// 用量下降（重置周期）后重新提醒
+	state.NotifiedUsage = crossed
+
+	if expire := info.ExpireTime(); !expire.IsZero() && expireDays > 0 && state.NotifiedExpire != info.Expire &&
+		time.Until(expire) < time.Duration(expireDays)*24*time.Hour {
+		alert.Expire = true
+		state.NotifiedExpire = info.Expire
+	}
+	return alert
+}
+
+// Retain 只保留仍然存在的订阅
+func (s *SubscriptionStore) Retain(names []string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for name := range s.states {
+		if !slices.Contains(names, name) {
+			delete(s.states, name)
+		}
+	}
+}
+
+// List 按名称排序的订阅流量信息
+func (s *SubscriptionStore) List() ([]string, []SubscriptionState) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	names := make([]string, 0, len(s.states))
+	for name := range s.states {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	states := make([]SubscriptionState, 0, len(names))
+	for _, name := range names {
+		states = append(states, *s.states[name])
+	}
+	return names, states
+}
+
+// Save 保存到文件
+func (s *SubscriptionStore) Save() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	data, err := json.MarshalIndent(s.states, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.path, data, 0644)
+}
+
+// 格式化流量大小
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	value, exp := float64(n)/unit, 0
+	for value >= unit && exp < 4 {
+		value /= unit
+		exp++
+	}
+	return fmt.Sprintf("%.2f %cB", value, "KMGTP"[exp])
+}
+
+var (
+	subscriptionStore     *SubscriptionStore // 订阅流量信息存储
+	subscriptionStoreOnce sync.Once
+)
+
+// 获取订阅流量信息存储
+func getSubscriptionStore() *SubscriptionStore {
+	subscriptionStoreOnce.Do(func() {
+		subscriptionStore = NewSubscriptionStore(filepath.Join(workDir, subscriptionStateFile))
+	})
+	return subscriptionStore
+}
+
+// 记录订阅下载响应头 subscription-userinfo 中的流量信息，超过阈值或即将到期时发送通知
+func recordSubscriptionUserinfo(name string, header http.Header) {
+	userinfo := header.Get("Subscription-Userinfo")
+	if userinfo == "" {
+		return
+	}
+	info, err := parseSubscriptionUserinfo(userinfo)
+	if err != nil {
+		log.Println("Failed to parse subscription userinfo:", err)
+		return
+	}
+	config := getAppConfig().SubscriptionAlert
+	store := getSubscriptionStore()
+	sendSubscriptionAlert(name, info, store.Update(name, info, config.UsageThresholds, config.ExpireDays))
+	if err = store.Save(); err != nil {
+		log.Println("Failed to save subscription state:", err)
+	}
+}
+
+// 发送订阅用量或到期提醒通知
+func sendSubscriptionAlert(name string, info *SubscriptionInfo, alert SubscriptionAlert) {
+	if alert.Usage {
+		sendNotification(I.TranSys("msg.info.subscription_usage", map[string]any{
+			"Name":    name,
+			"Percent": fmt.Sprintf("%.0f", info.UsagePercent()),
+			"Used":    formatBytes(info.Used()),
+			"Total":   formatBytes(info.Total),
+		}))
+	}
+	if alert.Expire {
+		sendNotification(I.TranSys("msg.info.subscription_expire", map[string]any{
+			"Name":   name,
+			"Expire": info.ExpireTime().Format("2006-01-02 15:04"),
+		}))
+	}
+}
+
+// 从core的代理集合中同步订阅流量信息，超过阈值或即将到期时发送通知
+func syncProviderSubscriptions() error {
+	providers, err := fetchProviders(ProviderKindProxy)
+	if err != nil {
+		return err
+	}
+	config := getAppConfig().SubscriptionAlert
+	store := getSubscriptionStore()
+	names := make([]string, 0, len(providers))
+	for _, provider := range providers {
+		if provider.SubscriptionInfo == nil {
+			continue
+		}
+		names = append(names, provider.Name)
+		alert := store.Update(provider.Name, provider.SubscriptionInfo, config.UsageThresholds, config.ExpireDays)
+		sendSubscriptionAlert(provider.Name, provider.SubscriptionInfo, alert)
+	}
+	// 订阅配置文件的流量信息在下载时更新
+	for _, profile := range getProfileStore().List() {
+		if profile.Type == ProfileTypeRemote {
+			names = append(names, profile.Name)
+		}
+	}
+	store.Retain(names)
+	return store.Save()
+}
+
+// 定时同步订阅流量信息并刷新托盘
+func runSubscriptionMonitor() {
+	// 等待core就绪
+	time.Sleep(30 * time.Second)
+	for {
+		if isCoreRunning() {
+			if err := syncProviderSubscriptions(); err != nil {
+				log.Println("Failed to sync subscriptions:", err)
+			}
+			buildSubscriptionItems()
+		}
+		time.Sleep(10 * time.Minute)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseSubscriptionUserinfo(t *testing.T) {
+	tests := []struct {
+		header string
+		want   SubscriptionInfo
+		ok     bool
+	}{
+		{"upload=1; download=2; total=3; expire=4", SubscriptionInfo{Upload: 1, Download: 2, Total: 3, Expire: 4}, true},
+		{"Upload=1024;Download=2.5e3;total=10737418240", SubscriptionInfo{Upload: 1024, Download: 2500, Total: 10737418240}, true},
+		{"upload=1; expire=; foo=bar", SubscriptionInfo{Upload: 1}, true},
+		{"foo=1; bar", SubscriptionInfo{}, false},
+		{"", SubscriptionInfo{}, false},
+	}
+	for _, tt := range tests {
+		info, err := parseSubscriptionUserinfo(tt.header)
+		if (err == nil) != tt.ok {
+			t.Errorf("parseSubscriptionUserinfo(%q) error = %v", tt.header, err)
+			continue
+		}
+		if tt.ok && *info != tt.want {
+			t.Errorf("parseSubscriptionUserinfo(%q) = %+v, want %+v", tt.header, *info, tt.want)
+		}
+	}
+}
+
+func TestSubscriptionStoreUpdate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), subscriptionStateFile)
+	store := NewSubscriptionStore(path)
+	gb := int64(1 << 30)
+	update := func(used int64, expire time.Time) SubscriptionAlert {
+		return store.Update("airport", &SubscriptionInfo{Download: used, Total: 100 * gb, Expire: expire.Unix()}, []int{80, 95}, 3)
+	}
+	farAway := time.Now().Add(30 * 24 * time.Hour)
+	soon := time.Now().Add(24 * time.Hour)
+	steps := []struct {
+		used   int64
+		expire time.Time
+		want   SubscriptionAlert
+	}{
+		{50 * gb, farAway, SubscriptionAlert{}},
+		{85 * gb, farAway, SubscriptionAlert{Usage: true}},
+		// 同一阈值只提醒一次
+		{90 * gb, farAway, SubscriptionAlert{}},
+		{96 * gb, farAway, SubscriptionAlert{Usage: true}},
+		// 重置周期后重新提醒
+		{10 * gb, farAway, SubscriptionAlert{}},
+		{81 * gb, farAway, SubscriptionAlert{Usage: true}},
+		{81 * gb, soon, SubscriptionAlert{Expire: true}},
+		{81 * gb, soon, SubscriptionAlert{}},
+		{96 * gb, soon, SubscriptionAlert{Usage: true}},
+	}
+	for i, step := range steps {
+		if got := update(step.used, step.expire); got != step.want {
+			t.Fatalf("step %d: Update(%d GB) = %+v, want %+v", i, step.used/gb, got, step.want)
+		}
+	}
+
+	if err := store.Save(); err != nil {
+		t.Fatal(err)
+	}
+	names, states := NewSubscriptionStore(path).List()
+	if len(names) != 1 || names[0] != "airport" || states[0].Used() != 96*gb || states[0].NotifiedUsage != 95 {
+		t.Fatalf("reloaded states = %v %+v", names, states)
+	}
+}
@@ -0,0 +1,502 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// 支持同步代理配置的开发工具
+const (
+	ToolGit    = "git"    // ~/.gitconfig http.proxy/https.proxy
+	ToolNpm    = "npm"    // ~/.npmrc proxy/https-proxy/noproxy
+	ToolPip    = "pip"    // pip.ini/pip.conf [global] proxy
+	ToolDocker = "docker" // ~/.docker/config.json proxies.default
+)
+
+// 开发工具代理同步的原始配置备份文件名
+const toolProxyStateFile = "tool-proxy-sync.json"
+
+var toolProxyMutex sync.Mutex // 同步互斥锁
+
+// ToolProxySettings 写入开发工具的代理配置
+type ToolProxySettings struct {
+	HttpProxy  string
+	HttpsProxy string
+	NoProxy    string
+}
+
+// toolProxyStore 开发工具的代理配置读写，值为 nil 表示不存在该配置项
+type toolProxyStore interface {
+	read() (map[string]*string, error)
+	write(values map[string]*string) error
+}
+
+// ToolProxySyncer 开发工具代理同步器
+type ToolProxySyncer struct {
+	home      string // 用户目录
+	statePath string // 原始配置备份文件路径
+}
+
+// NewToolProxySyncer 创建开发工具代理同步器，home 为用户目录，statePath 为原始配置备份文件路径
+func NewToolProxySyncer(home, statePath string) *ToolProxySyncer {
+	return &ToolProxySyncer{
+		home:      home,
+		statePath: statePath,
+	}
+}
+
+// Apply 将代理配置写入指定的开发工具，首次写入时备份原始配置，未在列表中但已备份的工具会被恢复
+func (s *ToolProxySyncer) Apply(tools []string, settings ToolProxySettings) error {
+	state, err := s.loadState()
+	if err != nil {
+		return err
+	}
+
+	normalized := make([]string, 0, len(tools))
+	for _, tool := range tools {
+		normalized = append(normalized, strings.ToLower(strings.TrimSpace(tool)))
+	}
+
+	var errs []error
+	for _, tool := range normalized {
+		store, err := s.store(tool)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if _, ok := state[tool]; !ok {
+			// 首次写入，备份原始配置
+			prior, err := store.read()
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", tool, err))
+				continue
+			}
+			state[tool] = prior
+			if err = s.saveState(state); err != nil {
+				return err
+			}
+		}
+		if err = store.write(s.values(tool, settings)); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", tool, err))
+		}
+	}
+
+	// 恢复已从列表中移除的工具
+	for tool := range state {
+		if !slices.Contains(normalized, tool) {
+			if err = s.restoreTool(state, tool); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	return errors.Join(append(errs, s.saveState(state))...)
+}
+
+// Restore 恢复所有已备份工具的原始配置
+func (s *ToolProxySyncer) Restore() error {
+	state, err := s.loadState()
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for tool := range state {
+		if err = s.restoreTool(state, tool); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(append(errs, s.saveState(state))...)
+}
+
+// 恢复指定工具的原始配置，成功后从备份中移除
+func (s *ToolProxySyncer) restoreTool(state map[string]map[string]*string, tool string) error {
+	store, err := s.store(tool)
+	if err != nil {
+		return err
+	}
+	if err = store.write(state[tool]); err != nil {
+		return fmt.Errorf("%s: %w", tool, err)
+	}
+	delete(state, tool)
+	return nil
+}
+
+// 获取工具需要写入的配置项
+func (s *ToolProxySyncer) values(tool string, settings ToolProxySettings) map[string]*string {
+	// 空值表示删除该配置项
+	ptr := func(v string) *string {
+		if v == "" {
+			return nil
+		}
+		return &v
+	}
+	switch tool {
+	case ToolGit:
+		return map[string]*string{"http.proxy": ptr(settings.HttpProxy), "https.proxy": ptr(settings.HttpsProxy)}
+	case ToolNpm:
+		return map[string]*string{"proxy": ptr(settings.HttpProxy), "https-proxy": ptr(settings.HttpsProxy), "noproxy": ptr(settings.NoProxy)}
+	case ToolPip:
+		return map[string]*string{"proxy": ptr(settings.HttpProxy)}
+	case ToolDocker:
+		return map[string]*string{"httpProxy": ptr(settings.HttpProxy), "httpsProxy": ptr(settings.HttpsProxy), "noProxy": ptr(settings.NoProxy)}
+	}
+	return nil
+}
+
+// 获取工具对应的配置读写
+func (s *ToolProxySyncer) store(tool string) (toolProxyStore, error) {
+	switch tool {
+	case ToolGit:
+		return &gitConfigStore{path: filepath.Join(s.home, ".gitconfig"), keys: []string{"http.proxy", "https.proxy"}}, nil
+	case ToolNpm:
+		return &keyValueFileStore{path: filepath.Join(s.home, ".npmrc"), keys: []string{"proxy", "https-proxy", "noproxy"}}, nil
+	case ToolPip:
+		path := filepath.Join(s.home, ".config", "pip", "pip.conf")
+		if runtime.GOOS == "windows" {
+			path = filepath.Join(s.home, "AppData", "Roaming", "pip", "pip.ini")
+		}
+		return &keyValueFileStore{path: path, section: "global", keys: []string{"proxy"}}, nil
+	case ToolDocker:
+		return &dockerConfigStore{path: filepath.Join(s.home, ".docker", "config.json")}, nil
+	}
+	return nil, fmt.Errorf("unsupported tool: %s", tool)
+}
+
+// 读取原始配置备份
+func (s *ToolProxySyncer) loadState() (map[string]map[string]*string, error) {
+	state := make(map[string]map[string]*string)
+	data, err := os.ReadFile(s.statePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return state, nil
+		}
+		return nil, err
+	}
+	if err = json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", s.statePath, err)
+	}
+	return state, nil
+}
+
+// 保存原始配置备份，没有备份时删除文件
+func (s *ToolProxySyncer) saveState(state map[string]map[string]*string) error {
+	if len(state) == 0 {
+		if err := os.Remove(s.statePath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.statePath, data, 0644)
+}
+
+// gitConfigStore 通过 git config --file 读写 git 全局配置
+type gitConfigStore struct {
+	path string
+	keys []string
+}
+
+func (g *gitConfigStore) read() (map[string]*string, error) {
+	values := make(map[string]*string)
+	for _, key := range g.keys {
+		out, err := execCommand("git", "config", "--file", g.path, "--get", key).Output()
+		if err != nil {
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) {
+				// 配置项不存在
+				values[key] = nil
+				continue
+			}
+			return nil, err
+		}
+		value := strings.TrimSpace(string(out))
+		values[key] = &value
+	}
+	return values, nil
+}
+
+func (g *gitConfigStore) write(values map[string]*string) error {
+	for key, value := range values {
+		var err error
+		if value == nil {
+			if !isFileExist(g.path) {
+				continue
+			}
+			err = execCommand("git", "config", "--file", g.path, "--unset-all", key).Run()
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) && exitErr.ExitCode() == 5 {
+				// 配置项本就不存在
+				err = nil
+			}
+		} else {
+			err = execCommand("git", "config", "--file", g.path, key, *value).Run()
+		}
+		if err != nil {
+			return fmt.Errorf("git config %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// keyValueFileStore 读写 key=value 格式的配置文件，section 不为空时只处理对应 ini 节
+type keyValueFileStore struct {
+	path    string
+	section string
+	keys    []string
+}
+
+func (k *keyValueFileStore) read() (map[string]*string, error) {
+	values := make(map[string]*string)
+	for _, key := range k.keys {
+		values[key] = nil
+	}
+	lines, err := readLines(k.path)
+	if err != nil {
+		return nil, err
+	}
+	section := ""
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]") {
+			section = strings.TrimSpace(trimmed[1 : len(trimmed)-1])
+			continue
+		}
+		if section != k.section {
+			continue
+		}
+		if key, value, ok := strings.Cut(trimmed, "="); ok {
+			key = strings.TrimSpace(key)
+			if _, wanted := values[key]; wanted {
+				value = strings.TrimSpace(value)
+				values[key] = &value
+			}
+		}
+	}
+	return values, nil
+}
+
+func (k *keyValueFileStore) write(values map[string]*string) error {
+	lines, err := readLines(k.path)
+	if err != nil {
+		return err
+	}
+
+	// 删除目标节内已有的配置项，并记录目标节最后一行的位置
+	var result []string
+	section, sectionEnd := "", -1
+	if k.section == "" {
+		sectionEnd = 0
+	}
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]") {
+			section = strings.TrimSpace(trimmed[1 : len(trimmed)-1])
+			result = append(result, line)
+			if section == k.section {
+				sectionEnd = len(result)
+			}
+			continue
+		}
+		if section == k.section {
+			if key, _, ok := strings.Cut(trimmed, "="); ok {
+				if _, managed := values[strings.TrimSpace(key)]; managed {
+					continue
+				}
+			}
+		}
+		result = append(result, line)
+		if section == k.section && trimmed != "" {
+			sectionEnd = len(result)
+		}
+	}
+
+	var added []string
+	for _, key := range k.keys {
+		if value := values[key]; value != nil {
+			added = append(added, key+" = "+*value)
+		}
+	}
+	if len(added) > 0 {
+		if sectionEnd < 0 {
+			// 目标节不存在则在末尾创建
+			result = append(result, fmt.Sprintf("[%s]", k.section))
+			sectionEnd = len(result)
+		}
+		result = slices.Insert(result, sectionEnd, added...)
+	}
+
+	empty := !slices.ContainsFunc(result, func(line string) bool {
+		trimmed := strings.TrimSpace(line)
+		return trimmed != "" && !(strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]"))
+	})
+	if empty {
+		// 没有任何配置项，只剩空的节时也删除文件
+		if err = os.Remove(k.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	if err = os.MkdirAll(filepath.Dir(k.path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(k.path, []byte(strings.Join(result, "\n")+"\n"), 0644)
+}
+
+// dockerConfigStore 读写 docker 客户端 config.json 中的 proxies.default
+type dockerConfigStore struct {
+	path string
+}
+
+func (d *dockerConfigStore) load() (map[string]any, error) {
+	config := make(map[string]any)
+	data, err := os.ReadFile(d.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return config, nil
+		}
+		return nil, err
+	}
+	if err = json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", d.path, err)
+	}
+	return config, nil
+}
+
+func (d *dockerConfigStore) read() (map[string]*string, error) {
+	config, err := d.load()
+	if err != nil {
+		return nil, err
+	}
+	values := map[string]*string{"httpProxy": nil, "httpsProxy": nil, "noProxy": nil}
+	proxies, _ := config["proxies"].(map[string]any)
+	defaults, _ := proxies["default"].(map[string]any)
+	for key := range values {
+		if value, ok := defaults[key].(string); ok {
+			values[key] = &value
+		}
+	}
+	return values, nil
+}
+
+func (d *dockerConfigStore) write(values map[string]*string) error {
+	config, err := d.load()
+	if err != nil {
+		return err
+	}
+	proxies, _ := config["proxies"].(map[string]any)
+	if proxies == nil {
+		proxies = make(map[string]any)
+	}
+	defaults, _ := proxies["default"].(map[string]any)
+	if defaults == nil {
+		defaults = make(map[string]any)
+	}
+	for key, value := range values {
+		if value == nil {
+			delete(defaults, key)
+		} else {
+			defaults[key] = *value
+		}
+	}
+	if len(defaults) == 0 {
+		delete(proxies, "default")
+	} else {
+		proxies["default"] = defaults
+	}
+	if len(proxies) == 0 {
+		delete(config, "proxies")
+	} else {
+		config["proxies"] = proxies
+	}
+
+	if len(config) == 0 {
+		// 没有任何配置项，删除文件
+		if err = os.Remove(d.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	data, err := json.MarshalIndent(config, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(d.path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(d.path, data, 0644)
+}
+
+// 按行读取文件，文件不存在时返回空
+func readLines(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines, scanner.Err()
+}
+
+// 获取当前程序使用的开发工具代理同步器
+func getToolProxySyncer() *ToolProxySyncer {
+	home, err := os.UserHomeDir()
+	if err != nil || workDir == "" {
+		return nil
+	}
+	return NewToolProxySyncer(home, filepath.Join(workDir, toolProxyStateFile))
+}
+
+// 同步core代理到开发工具
+func syncToolProxy() {
+	toolProxyMutex.Lock()
+	defer toolProxyMutex.Unlock()
+
+	syncer := getToolProxySyncer()
+	if syncer == nil {
+		return
+	}
+	env := getCoreProxyEnv()
+	settings := ToolProxySettings{
+		HttpProxy:  env["HTTP_PROXY"],
+		HttpsProxy: env["HTTPS_PROXY"],
+		NoProxy:    env["NO_PROXY"],
+	}
+	if err := syncer.Apply(getAppConfig().ToolProxySync, settings); err != nil {
+		log.Println("Failed to sync tool proxy:", err)
+	}
+}
+
+// 恢复开发工具的原始代理配置
+func restoreToolProxy() {
+	toolProxyMutex.Lock()
+	defer toolProxyMutex.Unlock()
+
+	syncer := getToolProxySyncer()
+	if syncer == nil {
+		return
+	}
+	if err := syncer.Restore(); err != nil {
+		log.Println("Failed to restore tool proxy:", err)
+	}
+}
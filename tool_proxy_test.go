@@ -0,0 +1,224 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var testProxySettings = ToolProxySettings{
+	HttpProxy:  "http://127.0.0.1:7890",
+	HttpsProxy: "http://127.0.0.1:7890",
+	NoProxy:    "localhost,127.0.0.0/8,.corp.example.com,git.example.org",
+}
+
+// 工具配置文件路径
+func toolStorePath(t *testing.T, syncer *ToolProxySyncer, tool string) string {
+	t.Helper()
+	store, err := syncer.store(tool)
+	if err != nil {
+		t.Fatal(err)
+	}
+	switch s := store.(type) {
+	case *gitConfigStore:
+		return s.path
+	case *keyValueFileStore:
+		return s.path
+	case *dockerConfigStore:
+		return s.path
+	}
+	t.Fatalf("unexpected store %T", store)
+	return ""
+}
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readToolValues(t *testing.T, syncer *ToolProxySyncer, tool string) map[string]string {
+	t.Helper()
+	store, err := syncer.store(tool)
+	if err != nil {
+		t.Fatal(err)
+	}
+	values, err := store.read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := make(map[string]string)
+	for key, value := range values {
+		if value != nil {
+			result[key] = *value
+		}
+	}
+	return result
+}
+
+func TestToolProxySync(t *testing.T) {
+	tests := []struct {
+		tool    string
+		prior   string            // 原有配置文件内容，空表示文件不存在
+		keep    string            // 同步和恢复后都应保留的内容
+		written map[string]string // 同步后的配置项
+		restore map[string]string // 恢复后的配置项
+	}{
+		{
+			tool:    ToolGit,
+			prior:   "[user]\n\tname = dev\n[http]\n\tproxy = http://old:8080\n",
+			keep:    "name = dev",
+			written: map[string]string{"http.proxy": "http://127.0.0.1:7890", "https.proxy": "http://127.0.0.1:7890"},
+			restore: map[string]string{"http.proxy": "http://old:8080"},
+		},
+		{
+			tool:  ToolNpm,
+			prior: "registry=https://registry.npmmirror.com\nproxy=http://old:8080\n",
+			keep:  "registry=https://registry.npmmirror.com",
+			written: map[string]string{
+				"proxy":       "http://127.0.0.1:7890",
+				"https-proxy": "http://127.0.0.1:7890",
+				"noproxy":     testProxySettings.NoProxy,
+			},
+			restore: map[string]string{"proxy": "http://old:8080"},
+		},
+		{
+			tool:    ToolPip,
+			prior:   "[global]\nindex-url = https://pypi.example.com/simple\n\n[install]\nuser = true\n",
+			keep:    "[install]\nuser = true",
+			written: map[string]string{"proxy": "http://127.0.0.1:7890"},
+			restore: map[string]string{},
+		},
+		{
+			tool:  ToolDocker,
+			prior: `{"auths": {"registry.example.com": {}}}`,
+			keep:  "registry.example.com",
+			written: map[string]string{
+				"httpProxy":  "http://127.0.0.1:7890",
+				"httpsProxy": "http://127.0.0.1:7890",
+				"noProxy":    testProxySettings.NoProxy,
+			},
+			restore: map[string]string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tool, func(t *testing.T) {
+			if tt.tool == ToolGit {
+				if _, err := exec.LookPath("git"); err != nil {
+					t.Skip("git not found")
+				}
+			}
+			home := t.TempDir()
+			statePath := filepath.Join(t.TempDir(), toolProxyStateFile)
+			syncer := NewToolProxySyncer(home, statePath)
+			path := toolStorePath(t, syncer, tt.tool)
+			if tt.prior != "" {
+				writeTestFile(t, path, tt.prior)
+			}
+
+			// 再次同步时不能覆盖首次备份的原始配置
+			for range 2 {
+				if err := syncer.Apply([]string{tt.tool}, testProxySettings); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := readToolValues(t, syncer, tt.tool); !equalStringMaps(got, tt.written) {
+				t.Fatalf("written values = %v, want %v", got, tt.written)
+			}
+			if _, err := os.Stat(statePath); err != nil {
+				t.Fatalf("backup not saved: %v", err)
+			}
+			assertFileContains(t, path, tt.keep)
+
+			if err := syncer.Restore(); err != nil {
+				t.Fatal(err)
+			}
+			if got := readToolValues(t, syncer, tt.tool); !equalStringMaps(got, tt.restore) {
+				t.Fatalf("restored values = %v, want %v", got, tt.restore)
+			}
+			if _, err := os.Stat(statePath); !os.IsNotExist(err) {
+				t.Fatalf("backup not removed after restore: %v", err)
+			}
+			assertFileContains(t, path, tt.keep)
+		})
+	}
+}
+
+func TestToolProxySyncRemovesFileCreatedBySync(t *testing.T) {
+	home := t.TempDir()
+	syncer := NewToolProxySyncer(home, filepath.Join(t.TempDir(), toolProxyStateFile))
+	tools := []string{ToolNpm, ToolPip, ToolDocker}
+	if err := syncer.Apply(tools, testProxySettings); err != nil {
+		t.Fatal(err)
+	}
+	for _, tool := range tools {
+		if _, err := os.Stat(toolStorePath(t, syncer, tool)); err != nil {
+			t.Fatalf("%s: config not written: %v", tool, err)
+		}
+	}
+	if err := syncer.Restore(); err != nil {
+		t.Fatal(err)
+	}
+	for _, tool := range tools {
+		if _, err := os.Stat(toolStorePath(t, syncer, tool)); !os.IsNotExist(err) {
+			t.Fatalf("%s: config created by sync not removed: %v", tool, err)
+		}
+	}
+}
+
+func TestToolProxySyncRestoresRemovedTool(t *testing.T) {
+	home := t.TempDir()
+	syncer := NewToolProxySyncer(home, filepath.Join(t.TempDir(), toolProxyStateFile))
+	npmrc := toolStorePath(t, syncer, ToolNpm)
+	writeTestFile(t, npmrc, "proxy=http://old:8080\n")
+
+	if err := syncer.Apply([]string{ToolNpm, ToolDocker}, testProxySettings); err != nil {
+		t.Fatal(err)
+	}
+	// 从列表中移除的工具恢复原始配置
+	if err := syncer.Apply([]string{ToolDocker}, testProxySettings); err != nil {
+		t.Fatal(err)
+	}
+	if got := readToolValues(t, syncer, ToolNpm); got["proxy"] != "http://old:8080" || len(got) != 1 {
+		t.Fatalf("npm values = %v", got)
+	}
+	if got := readToolValues(t, syncer, ToolDocker); got["httpProxy"] != testProxySettings.HttpProxy {
+		t.Fatalf("docker values = %v", got)
+	}
+}
+
+func TestToolProxySyncUnsupportedTool(t *testing.T) {
+	syncer := NewToolProxySyncer(t.TempDir(), filepath.Join(t.TempDir(), toolProxyStateFile))
+	if err := syncer.Apply([]string{"svn"}, testProxySettings); err == nil {
+		t.Fatal("expected error for unsupported tool")
+	}
+}
+
+func equalStringMaps(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for key, value := range a {
+		if other, ok := b[key]; !ok || other != value {
+			return false
+		}
+	}
+	return true
+}
+
+func assertFileContains(t *testing.T, path, want string) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), want) {
+		t.Fatalf("%s does not contain %q:\n%s", filepath.Base(path), want, data)
+	}
+}
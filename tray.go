@@ -3,13 +3,13 @@ package main
 import (
 	"embed"
 	"fmt"
-	"io"
 	"log"
-	"net/http"
 	"os"
 	"os/exec"
-	"regexp"
+	"path/filepath"
 	"runtime"
+	"slices"
+	"sync"
 
 	"github.com/energye/systray"
 	"golang.org/x/sys/windows"
@@ -18,8 +18,111 @@ import (
 //go:embed static/*
 var staticFiles embed.FS // 嵌入静态文件
 
-// 匹配该应用版本号正则
-var versionRegex = regexp.MustCompile(`^\d{8}$`)
+var (
+	trayMutex         sync.Mutex        // 托盘菜单重建互斥锁
+	terminalMenu      *systray.MenuItem // 终端菜单项所在的父菜单
+	terminalItems     *menuItemPool     // 终端菜单项
+	actionMenu        *systray.MenuItem // 自定义操作菜单
+	actionItems       *menuItemPool     // 自定义操作菜单项
+	dashboardMenu     *systray.MenuItem // 控制面板菜单
+	dashboardItems    *menuItemPool     // 控制面板菜单项
+	dashboardList     []dashboardItem   // 控制面板菜单项及其配置
+	providerMenu      *systray.MenuItem // 提供者菜单
+	providerItems     *menuItemPool     // 提供者菜单项
+	providerNames     []string          // 提供者菜单项对应的提供者，用于判断是否需要重建
+	subscriptionMenu  *systray.MenuItem // 订阅流量菜单
+	subscriptionItems *menuItemPool     // 订阅流量菜单项
+	profileMenu       *systray.MenuItem // 配置文件菜单
+	profileItems      *menuItemPool     // 配置文件菜单项
+	userRuleMenu      *systray.MenuItem // 用户规则菜单
+	userRuleItems     *menuItemPool     // 用户规则菜单项
+)
+
+// 控制面板菜单项及其配置
+type dashboardItem struct {
+	item   *pooledMenuItem
+	config DashboardConfig
+}
+
+// menuItemPool 动态菜单项池
+// systray 无法删除菜单项，重建时按顺序复用已创建的菜单项并隐藏多余的，菜单项数量不会随重建增加
+type menuItemPool struct {
+	parent *systray.MenuItem
+	items  []*pooledMenuItem
+	used   int // 本次重建已使用的菜单项数量
+}
+
+// pooledMenuItem 菜单项池中的菜单项，children 不为空时为子菜单
+type pooledMenuItem struct {
+	*systray.MenuItem
+	children *menuItemPool
+	visible  bool
+}
+
+func newMenuItemPool(parent *systray.MenuItem) *menuItemPool {
+	return &menuItemPool{parent: parent}
+}
+
+// 添加点击时执行 click 的菜单项，click 为空时只显示
+func (p *menuItemPool) add(title, tooltip string, click func()) *pooledMenuItem {
+	item := p.take(title, tooltip, false)
+	item.Click(click)
+	return item
+}
+
+// 添加子菜单，通过 children 添加子菜单项
+func (p *menuItemPool) addGroup(title, tooltip string) *pooledMenuItem {
+	item := p.take(title, tooltip, true)
+	item.Click(nil)
+	return item
+}
+
+// 取得未使用的菜单项，优先复用相同类型的，已经有子菜单的菜单项无法再作为普通菜单项
+func (p *menuItemPool) take(title, tooltip string, group bool) *pooledMenuItem {
+	index := slices.IndexFunc(p.items[p.used:], func(item *pooledMenuItem) bool {
+		return (item.children != nil) == group
+	})
+	var item *pooledMenuItem
+	if index < 0 {
+		item = &pooledMenuItem{MenuItem: p.parent.AddSubMenuItem(title, tooltip), visible: true}
+		if group {
+			item.children = newMenuItemPool(item.MenuItem)
+		}
+		p.items = slices.Insert(p.items, p.used, item)
+	} else {
+		item = p.items[p.used+index]
+		p.items[p.used], p.items[p.used+index] = item, p.items[p.used]
+		// 更新菜单项时会重新显示
+		item.SetTitle(title)
+		item.SetTooltip(tooltip)
+		item.Enable()
+		item.Uncheck()
+		item.visible = true
+	}
+	p.used++
+	return item
+}
+
+// 完成重建，隐藏本次未使用的菜单项，子菜单中未使用的菜单项也一并隐藏
+func (p *menuItemPool) done() {
+	for _, item := range p.items[p.used:] {
+		item.setVisible(false)
+	}
+	p.used = 0
+}
+
+// 显示或隐藏菜单项，已经是对应状态时不操作
+func (item *pooledMenuItem) setVisible(visible bool) {
+	if item.visible == visible {
+		return
+	}
+	item.visible = visible
+	if visible {
+		item.Show()
+	} else {
+		item.Hide()
+	}
+}
 
 // 初始化系统托盘
 func initSystray() {
@@ -89,20 +192,64 @@ func onReady() {
 		// 打开配置文件
 		_ = openBrowser(coreConfigPath)
 	})
+	systray.AddMenuItem(I.TranSys("tray.which_rule", nil), "").Click(func() {
+		// 匹配剪贴板中的域名或网址使用的规则
+		go showClipboardRuleMatch()
+	})
 
-	dashboardItem := systray.AddMenuItem(I.TranSys("tray.core_dashboard.title", nil), "")
-	dashboardItem.AddSubMenuItem(I.TranSys("tray.core_dashboard.options.local_ui", nil), "").Click(func() {
-		_ = openBrowser(getCoreConfig().ExternalUiAddr)
+	// 配置文件菜单，切换配置文件或导入后重建
+	profileMenu = systray.AddMenuItem(I.TranSys("tray.profiles.title", nil), "")
+	profileMenu.AddSubMenuItem(I.TranSys("tray.profiles.update", nil), "").Click(func() {
+		go updateProfilesAndNotify()
+	})
+	profileMenu.AddSubMenuItem(I.TranSys("tray.profiles.import_clients", nil), "").Click(func() {
+		go importClientProfilesAndNotify()
 	})
-	dashboardItem.AddSubMenuItem(I.TranSys("tray.core_dashboard.options.official_ui", nil), "").Click(func() {
-		_ = openBrowser(getCoreConfig().OfficialUiAddr)
+	profileMenu.AddSubMenuItem(I.TranSys("tray.profiles.preview_filters", nil), "").Click(func() {
+		go showSubscriptionFilterPreview()
+	})
+	profileItems = newMenuItemPool(profileMenu)
+	buildProfileItems()
+
+	// 用户规则菜单，添加或删除规则后重建
+	userRuleMenu = systray.AddMenuItem(I.TranSys("tray.rules.title", nil), "")
+	userRuleMenu.AddSubMenuItem(I.TranSys("tray.rules.direct", nil), "").Click(func() {
+		go addClipboardHostRule("DIRECT")
 	})
-	dashboardItem.AddSubMenuItem(I.TranSys("tray.core_dashboard.options.yacd_ui", nil), "").Click(func() {
-		_ = openBrowser(getCoreConfig().YACDUiAddr)
+	userRuleMenu.AddSubMenuItem(I.TranSys("tray.rules.proxy", nil), "").Click(func() {
+		go addClipboardHostRule("")
 	})
-	dashboardItem.AddSubMenuItem(I.TranSys("tray.core_dashboard.options.zash_ui", nil), "").Click(func() {
-		_ = openBrowser(getCoreConfig().ZashBoardUiAddr)
+	userRuleMenu.AddSubMenuItem(I.TranSys("tray.rules.reject", nil), "").Click(func() {
+		go addClipboardHostRule("REJECT")
 	})
+	userRuleItems = newMenuItemPool(userRuleMenu)
+	buildUserRuleItems()
+
+	// 控制面板菜单项，配置重载时重建
+	dashboardMenu = systray.AddMenuItem(I.TranSys("tray.core_dashboard.title", nil), "")
+	dashboardItems = newMenuItemPool(dashboardMenu)
+	buildDashboardItems()
+
+	// 提供者菜单，每次打开托盘时刷新
+	providerMenu = systray.AddMenuItem(I.TranSys("tray.providers.title", nil), "")
+	providerMenu.AddSubMenuItem(I.TranSys("tray.providers.list", nil), "").Click(func() {
+		go showProviders()
+	})
+	providerMenu.AddSubMenuItem(I.TranSys("tray.providers.update_all", nil), "").Click(func() {
+		go runAllProvidersAction("update")
+	})
+	providerMenu.AddSubMenuItem(I.TranSys("tray.providers.healthcheck_all", nil), "").Click(func() {
+		go runAllProvidersAction("healthcheck")
+	})
+	providerMenu.AddSubMenuItem(I.TranSys("tray.providers.import_clipboard", nil), "").Click(func() {
+		go importClipboardLinks()
+	})
+	providerItems = newMenuItemPool(providerMenu)
+
+	// 订阅流量菜单，同步订阅流量信息后重建
+	subscriptionMenu = systray.AddMenuItem(I.TranSys("tray.subscriptions", nil), "")
+	subscriptionItems = newMenuItemPool(subscriptionMenu)
+	buildSubscriptionItems()
 
 	// 分割线
 	systray.AddSeparator()
@@ -113,37 +260,51 @@ func onReady() {
 		_ = openDirectory(workDir)
 	})
 
-	var openShellFn = func(shell string) {
-		cmd := exec.Command(shell)
-		cmd.Dir = workDir
-		// 设置代理环境变量
-		cmd.Env = append(os.Environ(),
-			fmt.Sprintf("HTTP_PROXY=http://%s", getProxyServer()),
-			fmt.Sprintf("HTTPS_PROXY=http://%s", getProxyServer()))
-		cmd.SysProcAttr = &windows.SysProcAttr{
-			CreationFlags: windows.CREATE_NEW_CONSOLE | windows.CREATE_UNICODE_ENVIRONMENT | windows.CREATE_NEW_PROCESS_GROUP,
-		}
-		cmd.Stdin = os.Stdin
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		if err := cmd.Start(); err != nil {
-			go messageBoxAlert(AppName, fmt.Sprintf("Failed to start %s: %v", shell, err))
-		}
+	// 终端菜单项，配置重载时重建
+	terminalMenu = openItem
+	terminalItems = newMenuItemPool(terminalMenu)
+	buildTerminalItems()
+
+	updateItem := systray.AddMenuItem(I.TranSys("tray.update.title", nil), "")
+	// 更新core
+	updateItem.AddSubMenuItem(I.TranSys("tray.update.core", nil), "").Click(func() {
+		go func() {
+			if err := updateCore(false, true); err != nil {
+				messageBoxAlert(AppName, I.TranSys("msg.error.core.update_failed", map[string]any{"Error": err}))
+			}
+		}()
+	})
+	// 更新geodata
+	updateItem.AddSubMenuItem(I.TranSys("tray.update.geodata", nil), "").Click(func() {
+		go func() {
+			if err := updateGeoDataAndNotify(true, true); err != nil {
+				messageBoxAlert(AppName, I.TranSys("msg.error.geodata_failed", map[string]any{"Error": err}))
+			}
+		}()
+	})
+	// 安装或更新外部ui
+	externalUiItem := updateItem.AddSubMenuItem(I.TranSys("tray.update.external_ui.title", nil), "")
+	var installUiFn = func(source UiSourceConfig) {
+		go func() {
+			if err := installExternalUi(source, false, true); err != nil {
+				messageBoxAlert(AppName, I.TranSys("msg.error.ui_install_failed", map[string]any{"Error": err}))
+			}
+		}()
 	}
-	// 打开powershell
-	openItem.AddSubMenuItem(I.TranSys("tray.open.options.powershell", nil), "").Click(func() {
-		ps := "pwsh.exe"
-		// 先判断 pwsh.exe 是否在环境变量内存在
-		if _, err := exec.LookPath(ps); err != nil {
-			// 不存在使用系统默认的 PowerShell
-			ps = "powershell.exe"
-		}
-		openShellFn(ps)
+	externalUiItem.AddSubMenuItem(I.TranSys("tray.update.external_ui.configured", nil), "").Click(func() {
+		installUiFn(getAppConfig().ExternalUiSource)
 	})
-	// 打开命令行
-	openItem.AddSubMenuItem(I.TranSys("tray.open.options.cmd", nil), "").Click(func() {
-		openShellFn("cmd.exe")
+	externalUiItem.AddSubMenuItem(UiSourceMetaCubeXD, "").Click(func() {
+		installUiFn(UiSourceConfig{Name: UiSourceMetaCubeXD})
 	})
+	externalUiItem.AddSubMenuItem(UiSourceZashboard, "").Click(func() {
+		installUiFn(UiSourceConfig{Name: UiSourceZashboard})
+	})
+
+	// 自定义操作菜单项，配置重载时重建
+	actionMenu = systray.AddMenuItem(I.TranSys("tray.actions", nil), "")
+	actionItems = newMenuItemPool(actionMenu)
+	buildActionItems()
 
 	// 分割线
 	systray.AddSeparator()
@@ -154,33 +315,7 @@ func onReady() {
 	})
 
 	systray.AddMenuItem(I.TranSys("tray.check_update", nil), "").Click(func() {
-		go func() {
-			resp, err := http.Get(fmt.Sprintf("%s/releases/latest/download/version.txt", AppGitHubRepo))
-			if err != nil {
-				go messageBoxAlert(AppName, fmt.Sprintf("Failed to check update: %v", err))
-				return
-			}
-			defer resp.Body.Close()
-
-			body, err := io.ReadAll(resp.Body)
-			if err != nil {
-				go messageBoxAlert(AppName, fmt.Sprintf("Failed to read response: %v", err))
-				return
-			}
-			latestVersion := string(body)
-			if latestVersion != "" && versionRegex.MatchString(latestVersion) && latestVersion != version {
-				go func() {
-					if messageBoxConfirm(AppName, I.TranSys("msg.info.update_available", map[string]any{"Version": latestVersion})) {
-						downloadUrl := fmt.Sprintf("%s/releases/download/%s/gohomo-%s-%s-%s.zip", AppGitHubRepo,
-							latestVersion, runtime.GOOS, runtime.GOARCH, latestVersion)
-						log.Println("Update package download url:", downloadUrl)
-						_ = openBrowser(downloadUrl)
-					}
-				}()
-			} else {
-				go messageBoxAlert(AppName, I.TranSys("msg.info.no_update", nil))
-			}
-		}()
+		go checkAppUpdate()
 	})
 
 	systray.AddMenuItem(I.TranSys("tray.about", nil), "").Click(func() {
@@ -210,11 +345,9 @@ func onReady() {
 			coreItem.SetTitle(fmt.Sprintf("%s %s", CoreShowName, getCoreVersion()))
 
 			// 判断是否展示外部控制面板菜单项
-			if getCoreConfig().ExternalUiAddr == "" {
-				dashboardItem.Hide()
-			} else {
-				dashboardItem.Show()
-			}
+			refreshDashboardItems()
+			// 刷新提供者菜单项，下次打开时生效
+			go refreshProviderItems()
 
 			_ = menu.ShowMenu()
 		}
@@ -225,6 +358,291 @@ func onReady() {
 	systray.SetOnRClick(clickFn)
 }
 
+// 配置变更后重建托盘中的动态菜单项
+func reloadSystray() {
+	buildTerminalItems()
+	buildActionItems()
+	buildDashboardItems()
+}
+
+// 根据应用配置构建控制面板菜单项
+func buildDashboardItems() {
+	trayMutex.Lock()
+	defer trayMutex.Unlock()
+
+	if dashboardMenu == nil {
+		// 托盘尚未初始化
+		return
+	}
+	dashboardList = dashboardList[:0]
+	for _, config := range getAppConfig().Dashboards {
+		if config.Name == "" || config.Url == "" {
+			continue
+		}
+		item := dashboardItems.add(config.Name, "", func() {
+			openDashboard(config)
+		})
+		dashboardList = append(dashboardList, dashboardItem{item: item, config: config})
+	}
+	dashboardItems.done()
+}
+
+// 根据core配置展示可用的控制面板菜单项
+func refreshDashboardItems() {
+	trayMutex.Lock()
+	defer trayMutex.Unlock()
+
+	if dashboardMenu == nil {
+		return
+	}
+	available := 0
+	for _, dashboard := range dashboardList {
+		if isDashboardAvailable(dashboard.config) {
+			dashboard.item.setVisible(true)
+			available++
+		} else {
+			dashboard.item.setVisible(false)
+		}
+	}
+	if available == 0 {
+		dashboardMenu.Hide()
+	} else {
+		dashboardMenu.Show()
+	}
+}
+
+// 根据core当前的提供者重建提供者菜单项，提供者未变化时不重建
+func refreshProviderItems() {
+	providers, err := fetchAllProviders()
+	if err != nil {
+		providers = nil
+	}
+	names := make([]string, 0, len(providers))
+	for _, provider := range providers {
+		names = append(names, provider.Kind+"/"+provider.Name)
+	}
+
+	trayMutex.Lock()
+	defer trayMutex.Unlock()
+
+	if providerMenu == nil || slices.Equal(names, providerNames) {
+		return
+	}
+	providerNames = names
+	for _, provider := range providers {
+		item := providerItems.addGroup(fmt.Sprintf("%s (%d)", provider.Name, provider.Count()), "")
+		item.children.add(I.TranSys("tray.providers.update", nil), "", func() {
+			go runProviderAction([]*Provider{provider}, "update")
+		})
+		if provider.Kind == ProviderKindProxy {
+			item.children.add(I.TranSys("tray.providers.healthcheck", nil), "", func() {
+				go runProviderAction([]*Provider{provider}, "healthcheck")
+			})
+		}
+		item.children.done()
+	}
+	providerItems.done()
+}
+
+// 根据配置文件列表构建配置文件菜单项，勾选正在使用的配置文件
+func buildProfileItems() {
+	trayMutex.Lock()
+	defer trayMutex.Unlock()
+
+	if profileMenu == nil {
+		// 托盘尚未初始化
+		return
+	}
+	store := getProfileStore()
+	active := store.ActiveName()
+	// 第一项为默认的 config.yaml
+	names := []string{""}
+	for _, profile := range store.List() {
+		names = append(names, profile.Name)
+	}
+	for _, name := range names {
+		title := name
+		if name == "" {
+			title = I.TranSys("tray.profiles.default", nil)
+		}
+		item := profileItems.add(title, "", func() {
+			go func() {
+				if name == store.ActiveName() {
+					return
+				}
+				if err := switchProfile(name); err != nil {
+					messageBoxAlert(AppName, fmt.Sprint(err))
+				}
+				buildProfileItems()
+			}()
+		})
+		if name == active {
+			item.Check()
+		}
+	}
+	profileItems.done()
+}
+
+// 构建用户规则菜单项，点击后删除规则
+func buildUserRuleItems() {
+	trayMutex.Lock()
+	defer trayMutex.Unlock()
+
+	if userRuleMenu == nil {
+		// 托盘尚未初始化
+		return
+	}
+	rules, err := loadUserRules()
+	if err != nil {
+		log.Println("Failed to load user rules:", err)
+	}
+	for _, rule := range rules {
+		userRuleItems.add(rule, I.TranSys("tray.rules.remove", nil), func() {
+			go removeUserRuleAndReload(rule)
+		})
+	}
+	userRuleItems.done()
+}
+
+// 根据保存的订阅流量信息构建订阅流量菜单项
+func buildSubscriptionItems() {
+	trayMutex.Lock()
+	defer trayMutex.Unlock()
+
+	if subscriptionMenu == nil {
+		// 托盘尚未初始化
+		return
+	}
+	names, states := getSubscriptionStore().List()
+	for i, state := range states {
+		total, expire := I.TranSys("tray.subscription.unlimited", nil), "-"
+		if state.Total > 0 {
+			total = formatBytes(state.Total)
+		}
+		if t := state.ExpireTime(); !t.IsZero() {
+			expire = t.Format("2006-01-02")
+		}
+		item := subscriptionItems.add(I.TranSys("tray.subscription.item", map[string]any{
+			"Name":   names[i],
+			"Used":   formatBytes(state.Used()),
+			"Total":  total,
+			"Expire": expire,
+		}), "", nil)
+		item.Disable()
+	}
+	subscriptionItems.done()
+	if len(states) == 0 {
+		subscriptionMenu.Hide()
+	} else {
+		subscriptionMenu.Show()
+	}
+}
+
+// 对所有提供者执行操作
+func runAllProvidersAction(action string) {
+	providers, err := fetchAllProviders()
+	if err != nil {
+		messageBoxAlert(AppName, I.TranSys("msg.error.providers_failed", map[string]any{"Error": err}))
+		return
+	}
+	if action == "healthcheck" {
+		// 规则集合不支持健康检查
+		providers = proxyProviders(providers)
+	}
+	runProviderAction(providers, action)
+}
+
+// 根据应用配置构建终端菜单项
+func buildTerminalItems() {
+	trayMutex.Lock()
+	defer trayMutex.Unlock()
+
+	if terminalMenu == nil {
+		// 托盘尚未初始化
+		return
+	}
+	for _, terminal := range getAppConfig().Terminals {
+		if terminal.Command == "" {
+			continue
+		}
+		name := terminal.Name
+		if name == "" {
+			name = terminal.Command
+		}
+		terminalItems.add(name, "", func() {
+			openTerminal(terminal)
+		})
+	}
+	terminalItems.done()
+}
+
+// 根据应用配置构建自定义操作菜单项
+func buildActionItems() {
+	trayMutex.Lock()
+	defer trayMutex.Unlock()
+
+	if actionMenu == nil {
+		// 托盘尚未初始化
+		return
+	}
+	count := 0
+	for _, action := range getAppConfig().Actions {
+		if addActionItem(actionItems, action) {
+			count++
+		}
+	}
+	actionItems.done()
+	if count == 0 {
+		actionMenu.Hide()
+	} else {
+		actionMenu.Show()
+	}
+}
+
+// 添加自定义操作菜单项，有子菜单时递归添加，返回是否添加
+func addActionItem(pool *menuItemPool, action ActionConfig) bool {
+	if action.Name == "" {
+		return false
+	}
+	if len(action.Children) > 0 {
+		item := pool.addGroup(action.Name, "")
+		for _, child := range action.Children {
+			addActionItem(item.children, child)
+		}
+		item.children.done()
+		return true
+	}
+	pool.add(action.Name, "", func() {
+		go runAction(action)
+	})
+	return true
+}
+
+// 在新的控制台窗口中打开终端
+func openTerminal(terminal TerminalConfig) {
+	if err := startConsoleProcess(terminal.Command, terminal.Args, terminal.Dir); err != nil {
+		go messageBoxAlert(AppName, fmt.Sprintf("Failed to start %s: %v", terminal.Command, err))
+	}
+}
+
+// 在新的控制台窗口中启动程序，并设置代理环境变量
+// dir 为空时使用程序目录，支持环境变量，相对路径基于程序目录
+func startConsoleProcess(command string, args []string, dir string) error {
+	cmd := exec.Command(command, args...)
+	cmd.Dir = workDir
+	if dir != "" {
+		cmd.Dir = os.ExpandEnv(dir)
+		if !filepath.IsAbs(cmd.Dir) {
+			cmd.Dir = filepath.Join(workDir, cmd.Dir)
+		}
+	}
+	cmd.Env = proxyEnviron(os.Environ())
+	cmd.SysProcAttr = &windows.SysProcAttr{
+		CreationFlags: windows.CREATE_NEW_CONSOLE | windows.CREATE_UNICODE_ENVIRONMENT | windows.CREATE_NEW_PROCESS_GROUP,
+	}
+	return cmd.Start()
+}
+
 func onExit() {
 	// 退出程序后的处理操作
 	unsetProxy()
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// 外部ui目录中的版本标记文件
+const uiMarkerFile = ".gohomo-ui.json"
+
+// 外部ui来源
+const (
+	UiSourceMetaCubeXD = "metacubexd"
+	UiSourceZashboard  = "zashboard"
+	UiSourceCustom     = "custom"
+)
+
+// 内置外部ui的 GitHub 发布仓库及附件名称
+var builtinUiReleases = map[string]struct {
+	Repo  string
+	Asset string
+}{
+	UiSourceMetaCubeXD: {Repo: "MetaCubeX/metacubexd", Asset: "compressed-dist.tgz"},
+	UiSourceZashboard:  {Repo: "Zephyruso/zashboard", Asset: "dist.zip"},
+}
+
+// UiMarker 外部ui版本标记
+type UiMarker struct {
+	Source      string    `json:"source"`
+	Version     string    `json:"version"`
+	Sha256      string    `json:"sha256"`
+	InstalledAt time.Time `json:"installed-at"`
+}
+
+// 待安装的外部ui压缩包
+type uiPackage struct {
+	Url     string
+	Sha256  string
+	Version string
+	Archive string // 压缩包文件名，用于判断格式
+}
+
+// UiInstaller 外部ui安装器
+type UiInstaller struct {
+	Client  *http.Client
+	ApiBase string // GitHub API 地址，为空时使用默认地址
+}
+
+// NewUiInstaller 创建外部ui安装器
+func NewUiInstaller(client *http.Client, apiBase string) *UiInstaller {
+	return &UiInstaller{Client: client, ApiBase: apiBase}
+}
+
+// Install 安装或更新外部ui到 dir，force 为 false 时已是最新版本则跳过，返回安装后的版本标记以及是否有更新
+func (u *UiInstaller) Install(source UiSourceConfig, dir string, force bool) (*UiMarker, bool, error) {
+	name := source.sourceName()
+	pkg, err := u.resolve(source)
+	if err != nil {
+		return nil, false, err
+	}
+
+	current := readUiMarker(dir)
+	if !force && current != nil && current.Source == name && pkg.Version != "" && current.Version == pkg.Version {
+		// 已是最新版本
+		return current, false, nil
+	}
+
+	archive := filepath.Join(filepath.Dir(dir), fmt.Sprintf(".%s.download-%s", filepath.Base(dir), pkg.Archive))
+	defer os.Remove(archive)
+	sum, err := downloadFile(u.Client, pkg.Url, archive, pkg.Sha256)
+	if err != nil {
+		return nil, false, err
+	}
+	if !force && current != nil && current.Source == name && current.Sha256 == sum {
+		// 内容未变化
+		return current, false, nil
+	}
+
+	newDir := dir + ".new"
+	_ = os.RemoveAll(newDir)
+	defer os.RemoveAll(newDir)
+	if err = extractArchive(archive, newDir); err != nil {
+		return nil, false, err
+	}
+	if !isFileExist(filepath.Join(newDir, "index.html")) {
+		return nil, false, fmt.Errorf("index.html not found in %s", pkg.Url)
+	}
+
+	marker := &UiMarker{
+		Source:      name,
+		Version:     pkg.Version,
+		Sha256:      sum,
+		InstalledAt: time.Now(),
+	}
+	data, err := json.MarshalIndent(marker, "", "  ")
+	if err != nil {
+		return nil, false, err
+	}
+	if err = os.WriteFile(filepath.Join(newDir, uiMarkerFile), data, 0644); err != nil {
+		return nil, false, err
+	}
+	if err = replaceDir(newDir, dir); err != nil {
+		return nil, false, err
+	}
+	return marker, true, nil
+}
+
+// 解析外部ui来源的下载地址、摘要和版本
+func (u *UiInstaller) resolve(source UiSourceConfig) (*uiPackage, error) {
+	name := source.sourceName()
+	if name == UiSourceCustom {
+		if source.Url == "" {
+			return nil, fmt.Errorf("url is required for custom external-ui source")
+		}
+		if source.Sha256 == "" {
+			return nil, fmt.Errorf("sha256 is required for custom external-ui source")
+		}
+		archive := filepath.Base(strings.SplitN(source.Url, "?", 2)[0])
+		return &uiPackage{Url: source.Url, Sha256: source.Sha256, Version: source.Sha256, Archive: archive}, nil
+	}
+
+	release, ok := builtinUiReleases[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown external-ui source: %s", name)
+	}
+	info, err := fetchGitHubRelease(u.Client, u.ApiBase, release.Repo, "")
+	if err != nil {
+		return nil, err
+	}
+	asset := info.Asset(release.Asset)
+	if asset == nil {
+		return nil, fmt.Errorf("asset %s not found in %s %s", release.Asset, release.Repo, info.TagName)
+	}
+	if asset.Sha256() == "" {
+		return nil, fmt.Errorf("no checksum published for %s %s", release.Repo, info.TagName)
+	}
+	return &uiPackage{Url: asset.BrowserDownloadUrl, Sha256: asset.Sha256(), Version: info.TagName, Archive: asset.Name}, nil
+}
+
+// 获取外部ui来源名称，配置了地址时为自定义来源
+func (s UiSourceConfig) sourceName() string {
+	if s.Url != "" {
+		return UiSourceCustom
+	}
+	if s.Name == "" {
+		return UiSourceMetaCubeXD
+	}
+	return strings.ToLower(s.Name)
+}
+
+// 读取外部ui版本标记，不存在时返回 nil
+func readUiMarker(dir string) *UiMarker {
+	data, err := os.ReadFile(filepath.Join(dir, uiMarkerFile))
+	if err != nil {
+		return nil
+	}
+	marker := new(UiMarker)
+	if err = json.Unmarshal(data, marker); err != nil {
+		return nil
+	}
+	return marker
+}
+
+// 获取外部ui的安装目录，未配置 external-ui 时返回空
+func getExternalUiInstallDir() string {
+	config := getCoreConfig()
+	if config.ExternalUi == "" {
+		return ""
+	}
+	dir := getExternalUiDir()
+	if config.ExternalUiName != "" {
+		dir = filepath.Join(dir, strings.Trim(config.ExternalUiName, "/"))
+	}
+	return dir
+}
+
+// 安装外部ui并通知结果，notifyLatest 为 true 时已是最新版本也会通知
+func installExternalUi(source UiSourceConfig, force, notifyLatest bool) error {
+	dir := getExternalUiInstallDir()
+	if dir == "" {
+		return fmt.Errorf("external-ui is not configured")
+	}
+	log.Printf("Installing external-ui %s into %s\n", source.sourceName(), dir)
+	marker, updated, err := NewUiInstaller(downloadClient, "").Install(source, dir, force)
+	if err != nil {
+		log.Println("Failed to install external-ui:", err)
+		return err
+	}
+	if updated {
+		log.Printf("External-ui %s %s installed\n", marker.Source, marker.Version)
+		sendNotification(I.TranSys("msg.info.ui_installed", map[string]any{"Source": marker.Source, "Version": marker.Version}))
+	} else if notifyLatest {
+		sendNotification(I.TranSys("msg.info.ui_latest", map[string]any{"Source": marker.Source, "Version": marker.Version}))
+	}
+	return nil
+}
+
+// 启动时检查外部ui，未安装时自动安装，开启自动更新时检查更新
+func checkExternalUi() {
+	dir := getExternalUiInstallDir()
+	if dir == "" {
+		return
+	}
+	source := getAppConfig().ExternalUiSource
+	if shouldInstallExternalUi(dir, source.AutoUpdate) {
+		_ = installExternalUi(source, false, false)
+	}
+}
+
+// 判断启动时是否需要安装外部ui：未安装时安装，开启自动更新时只更新由本程序安装（有版本标记）的外部ui
+func shouldInstallExternalUi(dir string, autoUpdate bool) bool {
+	if !isFileExist(filepath.Join(dir, "index.html")) {
+		return true
+	}
+	if !autoUpdate {
+		return false
+	}
+	if readUiMarker(dir) == nil {
+		// 手动放置的外部ui，不自动替换
+		log.Println("Skip auto update of external-ui not installed by Gohomo:", dir)
+		return false
+	}
+	return true
+}
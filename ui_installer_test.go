@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// 模拟 GitHub 发布接口和附件下载，返回服务器和下载次数
+type testUiRelease struct {
+	server    *httptest.Server
+	tag       string
+	archive   []byte
+	digest    string
+	downloads int
+}
+
+func newTestUiRelease(t *testing.T, tag string, archive []byte) *testUiRelease {
+	t.Helper()
+	release := &testUiRelease{tag: tag, archive: archive, digest: "sha256:" + sha256Hex(archive)}
+	release.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/Zephyruso/zashboard/releases/latest":
+			_ = json.NewEncoder(w).Encode(GitHubRelease{
+				TagName: release.tag,
+				Assets: []GitHubReleaseAsset{{
+					Name:               "dist.zip",
+					BrowserDownloadUrl: release.server.URL + "/download/dist.zip",
+					Digest:             release.digest,
+				}},
+			})
+		case "/download/dist.zip":
+			release.downloads++
+			_, _ = w.Write(release.archive)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(release.server.Close)
+	return release
+}
+
+func testUiArchive(t *testing.T, version string) []byte {
+	return buildTestZip(t, []testArchiveFile{
+		{name: "dist/index.html", body: "<html>" + version},
+		{name: "dist/assets/app.js", body: "app"},
+	})
+}
+
+func TestUiInstallerWritesMarker(t *testing.T) {
+	release := newTestUiRelease(t, "v1.0.0", testUiArchive(t, "1"))
+	installer := NewUiInstaller(release.server.Client(), release.server.URL)
+	dir := filepath.Join(t.TempDir(), "ui", "zashboard")
+	source := UiSourceConfig{Name: UiSourceZashboard}
+
+	marker, updated, err := installer.Install(source, dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !updated || marker.Source != UiSourceZashboard || marker.Version != "v1.0.0" || marker.Sha256 != sha256Hex(release.archive) {
+		t.Fatalf("marker = %+v, updated = %v", marker, updated)
+	}
+	saved := readUiMarker(dir)
+	if saved == nil || saved.Version != "v1.0.0" || saved.Sha256 != marker.Sha256 {
+		t.Fatalf("saved marker = %+v", saved)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "index.html")); string(data) != "<html>1" {
+		t.Fatalf("index.html = %q", data)
+	}
+	// 临时文件和目录已清理
+	entries, _ := os.ReadDir(filepath.Dir(dir))
+	if len(entries) != 1 {
+		t.Fatalf("leftover files: %v", entries)
+	}
+
+	// 已是最新版本时不再下载
+	if _, updated, err = installer.Install(source, dir, false); err != nil || updated || release.downloads != 1 {
+		t.Fatalf("updated = %v, downloads = %d, err = %v", updated, release.downloads, err)
+	}
+
+	// 发布新版本后更新
+	release.tag, release.archive = "v1.1.0", testUiArchive(t, "2")
+	release.digest = "sha256:" + sha256Hex(release.archive)
+	if marker, updated, err = installer.Install(source, dir, false); err != nil || !updated || marker.Version != "v1.1.0" {
+		t.Fatalf("marker = %+v, updated = %v, err = %v", marker, updated, err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "index.html")); string(data) != "<html>2" {
+		t.Fatalf("index.html = %q", data)
+	}
+}
+
+func TestUiInstallerChecksumMismatchKeepsInstalledUi(t *testing.T) {
+	release := newTestUiRelease(t, "v1.0.0", testUiArchive(t, "1"))
+	installer := NewUiInstaller(release.server.Client(), release.server.URL)
+	dir := filepath.Join(t.TempDir(), "ui")
+	source := UiSourceConfig{Name: UiSourceZashboard}
+	if _, _, err := installer.Install(source, dir, false); err != nil {
+		t.Fatal(err)
+	}
+
+	release.tag, release.archive = "v2.0.0", testUiArchive(t, "tampered")
+	_, _, err := installer.Install(source, dir, false)
+	if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("expected checksum mismatch, got %v", err)
+	}
+	if marker := readUiMarker(dir); marker == nil || marker.Version != "v1.0.0" {
+		t.Fatalf("marker = %+v", marker)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "index.html")); string(data) != "<html>1" {
+		t.Fatalf("index.html = %q", data)
+	}
+}
+
+func TestUiInstallerRejectsUnsafeArchive(t *testing.T) {
+	archive := buildTestZip(t, []testArchiveFile{
+		{name: "index.html", body: "<html>"},
+		{name: "../../escaped.html", body: "x"},
+	})
+	release := newTestUiRelease(t, "v1.0.0", archive)
+	installer := NewUiInstaller(release.server.Client(), release.server.URL)
+	root := t.TempDir()
+	dir := filepath.Join(root, "ui", "zashboard")
+
+	if _, _, err := installer.Install(UiSourceConfig{Name: UiSourceZashboard}, dir, false); err == nil {
+		t.Fatal("expected error for unsafe archive")
+	}
+	if isFileExist(filepath.Join(root, "escaped.html")) || isFileExist(filepath.Join(root, "ui", "escaped.html")) {
+		t.Fatal("file written outside target")
+	}
+	if isFileExist(dir) {
+		t.Fatal("ui installed from unsafe archive")
+	}
+}
+
+func TestUiInstallerCustomSource(t *testing.T) {
+	archive := buildTestTarGz(t, []testArchiveFile{{name: "index.html", body: "<html>"}})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(archive)
+	}))
+	defer server.Close()
+	installer := NewUiInstaller(server.Client(), server.URL)
+	dir := filepath.Join(t.TempDir(), "ui")
+	url := server.URL + "/ui.tgz?raw=1"
+
+	if _, _, err := installer.Install(UiSourceConfig{Url: url}, dir, false); err == nil {
+		t.Fatal("expected error without sha256")
+	}
+	if _, _, err := installer.Install(UiSourceConfig{Url: url, Sha256: sha256Hex([]byte("x"))}, dir, false); err == nil {
+		t.Fatal("expected checksum mismatch")
+	}
+	marker, updated, err := installer.Install(UiSourceConfig{Url: url, Sha256: sha256Hex(archive)}, dir, false)
+	if err != nil || !updated || marker.Source != UiSourceCustom {
+		t.Fatalf("marker = %+v, updated = %v, err = %v", marker, updated, err)
+	}
+}
+
+func TestShouldInstallExternalUi(t *testing.T) {
+	root := t.TempDir()
+	missing := filepath.Join(root, "missing")
+	manual := filepath.Join(root, "manual")
+	managed := filepath.Join(root, "managed")
+	writeTestFile(t, filepath.Join(manual, "index.html"), "<html>")
+	writeTestFile(t, filepath.Join(managed, "index.html"), "<html>")
+	writeTestFile(t, filepath.Join(managed, uiMarkerFile), `{"source":"metacubexd","version":"v1"}`)
+
+	for _, tt := range []struct {
+		dir        string
+		autoUpdate bool
+		want       bool
+	}{
+		{missing, false, true},
+		{missing, true, true},
+		{manual, false, false},
+		{manual, true, false},
+		{managed, false, false},
+		{managed, true, true},
+	} {
+		if got := shouldInstallExternalUi(tt.dir, tt.autoUpdate); got != tt.want {
+			t.Errorf("shouldInstallExternalUi(%s, %v) = %v, want %v", filepath.Base(tt.dir), tt.autoUpdate, got, tt.want)
+		}
+	}
+}
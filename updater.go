@@ -0,0 +1,452 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/energye/systray"
+	"golang.org/x/sys/windows"
+)
+
+const (
+	updateHelperCommand = "apply-update"      // 替换程序文件的辅助命令
+	updateManifestFile  = "manifest.json"     // 发布中的版本清单
+	updateStagingDir    = ".update"           // 工作目录下的更新暂存目录
+	updateStateFile     = "update-check.json" // 后台检查更新的状态文件
+)
+
+// 更新通道
+const (
+	UpdateChannelStable = "stable"
+	UpdateChannelBeta   = "beta"
+)
+
+// 校验版本清单签名的 ed25519 公钥（base64），编译时通过 -X main.updatePublicKey=xxx 设置
+// 设置后更新时必须校验 manifest.json.sig 签名，未设置时只接受从 GitHub 发布直接下载的清单
+var updatePublicKey string
+
+// UpdateManifest 发布的版本清单
+type UpdateManifest struct {
+	Version string        `json:"version"`
+	Channel string        `json:"channel"`
+	Notes   string        `json:"notes"`
+	Assets  []UpdateAsset `json:"assets"`
+
+	url string // 清单地址，附件使用相对地址时基于该地址
+}
+
+// UpdateAsset 版本清单中的更新包
+type UpdateAsset struct {
+	Name   string `json:"name"`
+	Os     string `json:"os"`
+	Arch   string `json:"arch"`
+	Url    string `json:"url,omitempty"` // 为空时与清单位于同一目录
+	Sha256 string `json:"sha256"`
+}
+
+// SelfUpdater 程序自更新器
+type SelfUpdater struct {
+	Client    *http.Client
+	ApiBase   string   // GitHub API 地址，为空时使用默认地址
+	Repo      string   // 发布仓库，如 junlongzzz/gohomo
+	Channel   string   // 更新通道
+	Mirrors   []string // 更新包下载镜像地址前缀，依次尝试，都失败时直连，清单和签名始终直连
+	PublicKey string   // 清单签名公钥，为空时不校验签名
+	Dir       string   // 程序所在目录
+}
+
+// NewSelfUpdater 根据应用配置创建程序自更新器
+func NewSelfUpdater(config UpdateConfig) *SelfUpdater {
+	return &SelfUpdater{
+		Client:    downloadClient,
+		ApiBase:   config.ApiBase,
+		Repo:      strings.TrimPrefix(AppGitHubRepo, "https://github.com/"),
+		Channel:   config.Channel,
+		Mirrors:   config.Mirrors,
+		PublicKey: updatePublicKey,
+		Dir:       workDir,
+	}
+}
+
+// Latest 获取更新通道中的最新版本清单
+// 稳定通道只使用正式发布，测试通道同时比较最新的正式发布和预发布，取较新的版本
+func (u *SelfUpdater) Latest() (*UpdateManifest, error) {
+	releases, err := fetchGitHubReleases(u.Client, u.ApiBase, u.Repo)
+	if err != nil {
+		return nil, err
+	}
+
+	var latest *UpdateManifest
+	var errs []error
+	seenStable, seenBeta := false, false
+	for _, release := range releases {
+		if release.Prerelease && (u.Channel != UpdateChannelBeta || seenBeta) || !release.Prerelease && seenStable {
+			continue
+		}
+		asset := release.Asset(updateManifestFile)
+		if asset == nil {
+			continue
+		}
+		manifest, err := u.fetchManifest(asset.BrowserDownloadUrl)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err = u.checkChannel(manifest); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if release.Prerelease {
+			seenBeta = true
+		} else {
+			seenStable = true
+		}
+		if latest == nil || compareVersions(manifest.Version, latest.Version) > 0 {
+			latest = manifest
+		}
+		if seenStable && (seenBeta || u.Channel != UpdateChannelBeta) {
+			break
+		}
+	}
+	if latest == nil {
+		if len(errs) > 0 {
+			return nil, errors.Join(errs...)
+		}
+		return nil, fmt.Errorf("no %s found in releases of %s", updateManifestFile, u.Repo)
+	}
+	return latest, nil
+}
+
+// 检查清单的更新通道，测试版清单只在测试通道中使用，未标明通道的清单按发布类型处理
+func (u *SelfUpdater) checkChannel(manifest *UpdateManifest) error {
+	switch manifest.Channel {
+	case "", UpdateChannelStable:
+		return nil
+	case UpdateChannelBeta:
+		if u.Channel == UpdateChannelBeta {
+			return nil
+		}
+		return fmt.Errorf("%s is a %s release, current channel is %s", manifest.Version, manifest.Channel, u.Channel)
+	default:
+		return fmt.Errorf("unknown channel %q in %s %s", manifest.Channel, updateManifestFile, manifest.Version)
+	}
+}
+
+// 直连下载并解析版本清单，配置了公钥时校验签名，否则清单必须来自仓库的 GitHub 发布
+// 更新包的摘要来自清单，清单不经过镜像下载，镜像无法替换更新包
+func (u *SelfUpdater) fetchManifest(url string) (*UpdateManifest, error) {
+	trusted := strings.ToLower(fmt.Sprintf("https://github.com/%s/releases/download/", u.Repo))
+	if u.PublicKey == "" && !strings.HasPrefix(strings.ToLower(url), trusted) {
+		return nil, fmt.Errorf("untrusted manifest url without update public key: %s", url)
+	}
+	data, err := u.get(url)
+	if err != nil {
+		return nil, err
+	}
+	if u.PublicKey != "" {
+		if err = u.verifySignature(url+".sig", data); err != nil {
+			return nil, err
+		}
+	}
+	manifest := new(UpdateManifest)
+	if err = json.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", url, err)
+	}
+	if manifest.Version == "" {
+		return nil, fmt.Errorf("no version in %s", url)
+	}
+	manifest.url = url
+	return manifest, nil
+}
+
+// Download 下载清单中当前系统的更新包，校验后解压到暂存目录并返回该目录
+func (u *SelfUpdater) Download(manifest *UpdateManifest) (string, error) {
+	var asset *UpdateAsset
+	for i := range manifest.Assets {
+		if manifest.Assets[i].Os == runtime.GOOS && manifest.Assets[i].Arch == runtime.GOARCH {
+			asset = &manifest.Assets[i]
+			break
+		}
+	}
+	if asset == nil {
+		return "", fmt.Errorf("no update package for %s/%s in %s", runtime.GOOS, runtime.GOARCH, manifest.Version)
+	}
+	if asset.Sha256 == "" {
+		return "", fmt.Errorf("no checksum for %s", asset.Name)
+	}
+	assetUrl := asset.Url
+	if assetUrl == "" {
+		assetUrl = manifest.url[:strings.LastIndex(manifest.url, "/")+1] + asset.Name
+	}
+
+	staging := filepath.Join(u.Dir, updateStagingDir)
+	_ = os.RemoveAll(staging)
+	archive := filepath.Join(u.Dir, updateStagingDir+"-"+asset.Name)
+	defer os.Remove(archive)
+	var err error
+	for _, url := range mirrorUrls(u.Mirrors, assetUrl) {
+		if _, err = downloadFile(u.Client, url, archive, asset.Sha256); err == nil {
+			break
+		}
+		log.Println("Failed to download update:", err)
+	}
+	if err != nil {
+		return "", err
+	}
+	if err = extractArchive(archive, staging); err != nil {
+		_ = os.RemoveAll(staging)
+		return "", err
+	}
+	if !isFileExist(filepath.Join(staging, "gohomo.exe")) {
+		_ = os.RemoveAll(staging)
+		return "", fmt.Errorf("gohomo.exe not found in %s", asset.Name)
+	}
+	return staging, nil
+}
+
+// 校验 ed25519 签名
+func (u *SelfUpdater) verifySignature(url string, data []byte) error {
+	publicKey, err := base64.StdEncoding.DecodeString(u.PublicKey)
+	if err != nil || len(publicKey) != ed25519.PublicKeySize {
+		return fmt.Errorf("invalid update public key")
+	}
+	body, err := u.get(url)
+	if err != nil {
+		return err
+	}
+	signature, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(body)))
+	if err != nil {
+		return fmt.Errorf("invalid signature: %w", err)
+	}
+	if !ed25519.Verify(publicKey, data, signature) {
+		return fmt.Errorf("signature verification failed for %s", url)
+	}
+	return nil
+}
+
+// 直连请求并读取小文件内容
+func (u *SelfUpdater) get(url string) ([]byte, error) {
+	resp, err := u.Client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("get %s: %s", url, resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+// 按顺序生成通过镜像访问的地址，最后为原地址
+func mirrorUrls(mirrors []string, url string) []string {
+	urls := make([]string, 0, len(mirrors)+1)
+	for _, mirror := range mirrors {
+		if mirror != "" {
+			urls = append(urls, mirror+url)
+		}
+	}
+	return append(urls, url)
+}
+
+// 启动暂存目录中的新程序作为辅助进程，在当前程序退出后替换文件并重新启动
+func startUpdateHelper(staging string) error {
+	cmd := exec.Command(filepath.Join(staging, "gohomo.exe"), updateHelperCommand,
+		"-pid", fmt.Sprint(os.Getpid()),
+		"-src", staging,
+		"-dst", workDir,
+	)
+	cmd.Dir = workDir
+	cmd.SysProcAttr = &windows.SysProcAttr{
+		CreationFlags: windows.CREATE_NEW_PROCESS_GROUP | windows.DETACHED_PROCESS,
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	return cmd.Process.Release()
+}
+
+// 辅助进程：等待旧程序退出，备份旧文件为 .old 并复制新文件，然后启动新程序
+// 任一步骤失败时恢复已备份的旧文件并启动旧程序
+func applyUpdate(args []string) {
+	fs := flag.NewFlagSet(updateHelperCommand, flag.ContinueOnError)
+	pid := fs.Int("pid", 0, "pid of the running gohomo")
+	src := fs.String("src", "", "directory of the new version")
+	dst := fs.String("dst", "", "directory of the installed version")
+	if err := fs.Parse(args); err != nil || *src == "" || *dst == "" {
+		os.Exit(2)
+	}
+
+	if logFile, err := os.OpenFile(filepath.Join(*dst, "logs", "update.log"), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0666); err == nil {
+		defer logFile.Close()
+		log.SetOutput(logFile)
+	}
+
+	// 等待旧程序退出
+	for i := 0; i < 60 && isProcessRunningByPid(*pid); i++ {
+		time.Sleep(500 * time.Millisecond)
+	}
+
+	entries, err := os.ReadDir(*src)
+	if err != nil {
+		log.Println("Failed to read update files:", err)
+		os.Exit(1)
+	}
+	var replaced []string
+	rollback := func() {
+		for _, name := range replaced {
+			target := filepath.Join(*dst, name)
+			_ = os.Remove(target)
+			if isFileExist(target + ".old") {
+				_ = os.Rename(target+".old", target)
+			}
+		}
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		target := filepath.Join(*dst, entry.Name())
+		_ = os.Remove(target + ".old")
+		if isFileExist(target) {
+			if err = os.Rename(target, target+".old"); err != nil {
+				log.Println("Failed to back up", target, err)
+				rollback()
+				break
+			}
+		}
+		replaced = append(replaced, entry.Name())
+		if err = copyFile(filepath.Join(*src, entry.Name()), target); err != nil {
+			log.Println("Failed to replace", target, err)
+			rollback()
+			break
+		}
+	}
+	if err == nil {
+		log.Println("Update applied from:", *src)
+	}
+
+	cmd := exec.Command(filepath.Join(*dst, "gohomo.exe"))
+	cmd.Dir = *dst
+	if err = cmd.Start(); err != nil {
+		log.Println("Failed to start new version, rolling back:", err)
+		rollback()
+		cmd = exec.Command(filepath.Join(*dst, "gohomo.exe"))
+		cmd.Dir = *dst
+		_ = cmd.Start()
+	}
+}
+
+// 复制文件
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0755)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// 清理上次更新遗留的暂存目录
+func cleanupUpdate() {
+	// 辅助进程启动新程序后才退出，稍等再删除
+	time.Sleep(5 * time.Second)
+	_ = os.RemoveAll(filepath.Join(workDir, updateStagingDir))
+}
+
+// 检查程序更新，有新版本时确认后下载并重启更新
+func checkAppUpdate() {
+	updater := NewSelfUpdater(getAppConfig().Update)
+	manifest, err := updater.Latest()
+	if err != nil {
+		go messageBoxAlert(AppName, fmt.Sprintf("Failed to check update: %v", err))
+		return
+	}
+	if compareVersions(manifest.Version, version) <= 0 {
+		go messageBoxAlert(AppName, I.TranSys("msg.info.no_update", nil))
+		return
+	}
+	notes := []rune(strings.TrimSpace(manifest.Notes))
+	if len(notes) > 500 {
+		notes = append(notes[:500], []rune("...")...)
+	}
+	if !messageBoxConfirm(AppName, I.TranSys("msg.info.update_available", map[string]any{
+		"Version": manifest.Version,
+		"Channel": manifest.Channel,
+		"Notes":   string(notes),
+	})) {
+		return
+	}
+
+	staging, err := updater.Download(manifest)
+	if err == nil {
+		err = startUpdateHelper(staging)
+	}
+	if err != nil {
+		log.Println("Failed to update:", err)
+		go messageBoxAlert(AppName, I.TranSys("msg.error.update_failed", map[string]any{"Error": err}))
+		return
+	}
+	log.Println("Update downloaded, restarting:", manifest.Version)
+	// 退出后由辅助进程替换文件并重新启动
+	systray.Quit()
+}
+
+// 后台检查更新的状态
+type updateCheckState struct {
+	LastCheck time.Time `json:"last-check"`
+	Notified  string    `json:"notified"` // 已通知过的版本
+}
+
+// 按配置的间隔在后台检查更新，每个新版本只通知一次
+func runUpdateChecker() {
+	statePath := filepath.Join(workDir, updateStateFile)
+	state := new(updateCheckState)
+	if data, err := os.ReadFile(statePath); err == nil {
+		_ = json.Unmarshal(data, state)
+	}
+
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for range ticker.C {
+		config := getAppConfig().Update
+		interval, err := time.ParseDuration(config.CheckInterval)
+		if err != nil || interval <= 0 || time.Since(state.LastCheck) < interval {
+			// 未启用或未到检查时间，配置变更后下一分钟生效
+			continue
+		}
+
+		state.LastCheck = time.Now()
+		manifest, err := NewSelfUpdater(config).Latest()
+		if err != nil {
+			log.Println("Failed to check update:", err)
+		} else if compareVersions(manifest.Version, version) > 0 && manifest.Version != state.Notified {
+			log.Println("New version available:", manifest.Version)
+			sendNotification(I.TranSys("msg.info.update_notify", map[string]any{"Version": manifest.Version}))
+			state.Notified = manifest.Version
+		}
+		if data, err := json.MarshalIndent(state, "", "  "); err == nil {
+			_ = os.WriteFile(statePath, data, 0644)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSelfUpdaterFetchManifest(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest := []byte(`{"version":"v1.2.0","channel":"stable"}`)
+	signature := base64.StdEncoding.EncodeToString(ed25519.Sign(privateKey, manifest))
+	var mirrorHits int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/manifest.json":
+			_, _ = w.Write(manifest)
+		case "/manifest.json.sig":
+			_, _ = w.Write([]byte(signature))
+		case "/bad/manifest.json":
+			_, _ = w.Write([]byte(`{"version":"v9.9.9"}`))
+		case "/bad/manifest.json.sig":
+			_, _ = w.Write([]byte(signature))
+		default:
+			mirrorHits++
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	updater := &SelfUpdater{
+		Client:    server.Client(),
+		Repo:      "junlongzzz/gohomo",
+		Mirrors:   []string{server.URL + "/mirror/"},
+		PublicKey: base64.StdEncoding.EncodeToString(publicKey),
+	}
+
+	// 清单和签名不经过镜像
+	got, err := updater.fetchManifest(server.URL + "/manifest.json")
+	if err != nil || got.Version != "v1.2.0" {
+		t.Fatalf("fetchManifest() = %+v, %v", got, err)
+	}
+	if mirrorHits != 0 {
+		t.Fatalf("manifest fetched through mirror %d times", mirrorHits)
+	}
+	if _, err = updater.fetchManifest(server.URL + "/bad/manifest.json"); err == nil || !strings.Contains(err.Error(), "signature verification failed") {
+		t.Fatalf("expected signature error, got %v", err)
+	}
+
+	// 没有公钥时只接受 GitHub 发布中的清单
+	updater.PublicKey = ""
+	if _, err = updater.fetchManifest(server.URL + "/manifest.json"); err == nil || !strings.Contains(err.Error(), "untrusted manifest url") {
+		t.Fatalf("expected untrusted url error, got %v", err)
+	}
+	if mirrorHits != 0 {
+		t.Fatalf("manifest fetched through mirror %d times", mirrorHits)
+	}
+}
+
+func TestSelfUpdaterCheckChannel(t *testing.T) {
+	tests := []struct {
+		channel  string // 更新器通道
+		manifest string // 清单通道
+		ok       bool
+	}{
+		{UpdateChannelStable, UpdateChannelStable, true},
+		{UpdateChannelStable, "", true},
+		{UpdateChannelStable, UpdateChannelBeta, false},
+		{UpdateChannelBeta, UpdateChannelStable, true},
+		{UpdateChannelBeta, UpdateChannelBeta, true},
+		{UpdateChannelBeta, "nightly", false},
+	}
+	for _, tt := range tests {
+		updater := &SelfUpdater{Channel: tt.channel}
+		err := updater.checkChannel(&UpdateManifest{Version: "v1.0.0", Channel: tt.manifest})
+		if (err == nil) != tt.ok {
+			t.Errorf("checkChannel(%q) on %s channel = %v", tt.manifest, tt.channel, err)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/viper"
+	"go.yaml.in/yaml/v3"
+)
+
+const userRulesFile = "user-rules.yaml" // 用户添加的规则文件，位于core目录
+
+// 用户规则文件的内容
+type userRules struct {
+	Rules []string `yaml:"rules"`
+}
+
+// 用户规则文件路径
+func getUserRulesPath() string {
+	return filepath.Join(coreDir, userRulesFile)
+}
+
+// 读取用户规则，文件不存在时返回空
+func loadUserRules() ([]string, error) {
+	data, err := os.ReadFile(getUserRulesPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var content userRules
+	if err = yaml.Unmarshal(data, &content); err != nil {
+		return nil, fmt.Errorf("%s: %w", userRulesFile, err)
+	}
+	return content.Rules, nil
+}
+
+func saveUserRules(rules []string) error {
+	out, err := yaml.Marshal(userRules{Rules: rules})
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(getUserRulesPath(), out, 0644)
+}
+
+// 配置文件中可以作为规则策略的名称，包括节点、代理组和内置策略，第一个代理组在最前
+func loadCorePolicies(path string) ([]string, error) {
+	var config struct {
+		Proxies     []struct{ Name string } `yaml:"proxies"`
+		ProxyGroups []struct{ Name string } `yaml:"proxy-groups"`
+	}
+	if err := readYamlFile(path, &config); err != nil {
+		return nil, err
+	}
+	var policies []string
+	for _, group := range config.ProxyGroups {
+		policies = append(policies, group.Name)
+	}
+	for _, proxy := range config.Proxies {
+		policies = append(policies, proxy.Name)
+	}
+	if imported, err := loadImportedProxies(); err == nil {
+		for _, proxy := range imported {
+			policies = append(policies, proxy.Name())
+		}
+	}
+	return append(policies, builtinPolicies...), nil
+}
+
+// 添加用户规则，校验规则格式以及策略是否存在于配置文件 path 中，已存在相同规则时不重复添加
+func addUserRule(rule, path string) error {
+	rule = strings.TrimSpace(rule)
+	ruleType, policy := splitRulePolicy(rule)
+	if ruleType == "" || policy == "" {
+		return fmt.Errorf("invalid rule %q, expected TYPE,payload,policy", rule)
+	}
+	if ruleType == "MATCH" {
+		return fmt.Errorf("MATCH rule would override all rules of the profile")
+	}
+	policies, err := loadCorePolicies(path)
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(policies, policy) {
+		return fmt.Errorf("policy %q not found in %s", policy, filepath.Base(path))
+	}
+	rules, err := loadUserRules()
+	if err != nil {
+		return err
+	}
+	if slices.Contains(rules, rule) {
+		return nil
+	}
+	log.Println("Add user rule:", rule)
+	return saveUserRules(append(rules, rule))
+}
+
+// 删除用户规则，arg 为规则原文或从 1 开始的序号，返回删除的规则
+func removeUserRule(arg string) (string, error) {
+	rules, err := loadUserRules()
+	if err != nil {
+		return "", err
+	}
+	index := slices.Index(rules, strings.TrimSpace(arg))
+	if n, err := strconv.Atoi(arg); err == nil && index < 0 {
+		index = n - 1
+	}
+	if index < 0 || index >= len(rules) {
+		return "", fmt.Errorf("user rule not found: %s", arg)
+	}
+	removed := rules[index]
+	log.Println("Remove user rule:", removed)
+	return removed, saveUserRules(slices.Delete(rules, index, index+1))
+}
+
+// 将用户规则添加到运行配置的 rules 最前面，策略不存在的规则跳过，需要在注入导入节点后调用
+// 规则中的占位符在注入时替换，用户规则文件中保持原样
+func injectUserRules(v *viper.Viper) error {
+	rules, err := loadUserRules()
+	if err != nil {
+		log.Println("Failed to load user rules:", err)
+		return nil
+	}
+	if len(rules) == 0 {
+		return nil
+	}
+	policies := slices.Clone(builtinPolicies)
+	for _, key := range []string{"proxies", "proxy-groups"} {
+		items, _ := v.Get(key).([]any)
+		for _, item := range items {
+			if m, ok := item.(map[string]any); ok {
+				policies = append(policies, fmt.Sprint(m["name"]))
+			}
+		}
+	}
+	// 包含从分享链接导入的节点名称，不依赖导入节点是否已注入到运行配置
+	if imported, err := loadImportedProxies(); err == nil {
+		for _, proxy := range imported {
+			policies = append(policies, proxy.Name())
+		}
+	}
+	var injected []any
+	for _, rule := range rules {
+		if _, policy := splitRulePolicy(rule); !slices.Contains(policies, policy) {
+			log.Println("Skip user rule with missing policy:", rule)
+			continue
+		}
+		expanded, err := expandPlaceholders(rule)
+		if err != nil {
+			return errors.New(I.TranSys("msg.error.core.config.placeholder", map[string]any{
+				"Path":  getUserRulesPath(),
+				"Error": err,
+			}))
+		}
+		injected = append(injected, expanded)
+	}
+	existing, _ := v.Get("rules").([]any)
+	log.Println("Inject user rules into running config:", len(injected))
+	v.Set("rules", append(injected, existing...))
+	return nil
+}
+
+// 根据剪贴板中的域名、IP 或网址生成规则，域名使用 DOMAIN-SUFFIX，IP 使用 IP-CIDR
+func clipboardHostRule(policy string) (string, error) {
+	text, err := readClipboardText()
+	if err != nil {
+		return "", err
+	}
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return "", fmt.Errorf("clipboard is empty")
+	}
+	target, err := ParseRuleTarget(fields[0])
+	if err != nil {
+		return "", err
+	}
+	if target.Host != "" {
+		return fmt.Sprintf("DOMAIN-SUFFIX,%s,%s", target.Host, policy), nil
+	}
+	ruleType, bits := "IP-CIDR", 32
+	if target.IP.Is6() {
+		ruleType, bits = "IP-CIDR6", 128
+	}
+	return fmt.Sprintf("%s,%s/%d,%s,no-resolve", ruleType, target.IP, bits, policy), nil
+}
+
+// 在托盘中为剪贴板中的地址添加规则，policy 为空时使用第一个代理组
+func addClipboardHostRule(policy string) {
+	if policy == "" {
+		policies, err := loadCorePolicies(coreConfigPath)
+		if err != nil {
+			messageBoxAlert(AppName, I.TranSys("msg.error.user_rule_failed", map[string]any{"Error": err}))
+			return
+		}
+		// 没有代理组时为第一个节点
+		policy = policies[0]
+	}
+	rule, err := clipboardHostRule(policy)
+	if err == nil {
+		err = addUserRule(rule, coreConfigPath)
+	}
+	if err == nil {
+		err = reloadCoreConfig()
+	}
+	if err != nil {
+		messageBoxAlert(AppName, I.TranSys("msg.error.user_rule_failed", map[string]any{"Error": err}))
+		return
+	}
+	sendNotification(I.TranSys("msg.info.user_rule_added", map[string]any{"Rule": rule}))
+	buildUserRuleItems()
+}
+
+// 在托盘中确认后删除用户规则
+func removeUserRuleAndReload(rule string) {
+	if !messageBoxConfirm(AppName, I.TranSys("msg.info.user_rule_remove", map[string]any{"Rule": rule})) {
+		return
+	}
+	_, err := removeUserRule(rule)
+	if err == nil {
+		err = reloadCoreConfig()
+	}
+	if err != nil {
+		messageBoxAlert(AppName, I.TranSys("msg.error.user_rule_failed", map[string]any{"Error": err}))
+	}
+	buildUserRuleItems()
+}
+
+// 命令行修改用户规则后重新生成 coreConfigPath 的运行配置，并通过外部控制器通知正在运行的core重新加载
+func reloadCoreConfigFromCli() error {
+	coreRunConfigPath = filepath.Join(coreDir, "config.auto-gen")
+	coreConfig.Store(&CoreConfig{})
+	if err := loadCoreConfig(); err != nil {
+		return err
+	}
+	if getCoreConfig().ControllerAddr == "" {
+		return fmt.Errorf("no external controller configured")
+	}
+	body, _ := json.Marshal(map[string]string{"path": coreRunConfigPath})
+	return controllerJSON(http.MethodPut, "/configs", bytes.NewReader(body), nil)
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+const testUserRulesConfig = `proxies:
+  - {name: Local, type: direct}
+proxy-groups:
+  - {name: Proxy, type: select, proxies: [Local, DIRECT]}
+rules:
+  - GEOIP,CN,DIRECT
+  - MATCH,Proxy
+`
+
+// 使用临时core目录中的用户规则和导入节点文件
+func setTestCoreDir(t *testing.T) string {
+	t.Helper()
+	dir := coreDir
+	coreDir = t.TempDir()
+	t.Cleanup(func() { coreDir = dir })
+	return coreDir
+}
+
+func TestAddRemoveUserRule(t *testing.T) {
+	dir := setTestCoreDir(t)
+	path := filepath.Join(dir, "config.yaml")
+	writeTestFile(t, path, testUserRulesConfig)
+	writeTestFile(t, filepath.Join(dir, importedProxiesFile), testImportedProxies)
+
+	for _, rule := range []string{
+		" DOMAIN-SUFFIX,a.com,Proxy ",
+		"IP-CIDR,10.0.0.0/8,DIRECT,no-resolve",
+		"AND,((DOMAIN,b.com),(DST-PORT,443)),Local",
+		"DOMAIN,c.com,JP 01",
+		// 重复的规则不再添加
+		"DOMAIN-SUFFIX,a.com,Proxy",
+	} {
+		if err := addUserRule(rule, path); err != nil {
+			t.Fatalf("addUserRule(%q) = %v", rule, err)
+		}
+	}
+	for _, tt := range []struct{ rule, err string }{
+		{"DOMAIN,d.com", "invalid rule"},
+		{"", "invalid rule"},
+		{"MATCH,Proxy", "MATCH rule"},
+		{"DOMAIN,d.com,Missing", `policy "Missing" not found in config.yaml`},
+	} {
+		if err := addUserRule(tt.rule, path); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("addUserRule(%q) = %v, want %q", tt.rule, err, tt.err)
+		}
+	}
+	want := []string{"DOMAIN-SUFFIX,a.com,Proxy", "IP-CIDR,10.0.0.0/8,DIRECT,no-resolve", "AND,((DOMAIN,b.com),(DST-PORT,443)),Local", "DOMAIN,c.com,JP 01"}
+	if rules, err := loadUserRules(); err != nil || !reflect.DeepEqual(rules, want) {
+		t.Fatalf("rules = %q, %v, want %q", rules, err, want)
+	}
+
+	// 按规则原文或从 1 开始的序号删除
+	for _, tt := range []struct{ arg, removed string }{
+		{"2", "IP-CIDR,10.0.0.0/8,DIRECT,no-resolve"},
+		{" DOMAIN,c.com,JP 01 ", "DOMAIN,c.com,JP 01"},
+		{"1", "DOMAIN-SUFFIX,a.com,Proxy"},
+	} {
+		removed, err := removeUserRule(tt.arg)
+		if err != nil || removed != tt.removed {
+			t.Fatalf("removeUserRule(%q) = %q, %v, want %q", tt.arg, removed, err, tt.removed)
+		}
+	}
+	for _, arg := range []string{"0", "2", "-1", "DOMAIN,c.com,JP 01"} {
+		if _, err := removeUserRule(arg); err == nil {
+			t.Errorf("removeUserRule(%q) succeeded", arg)
+		}
+	}
+	if rules, _ := loadUserRules(); !reflect.DeepEqual(rules, []string{"AND,((DOMAIN,b.com),(DST-PORT,443)),Local"}) {
+		t.Fatalf("rules = %q", rules)
+	}
+}
+
+func TestInjectUserRules(t *testing.T) {
+	dir := setTestCoreDir(t)
+	writeTestFile(t, filepath.Join(dir, importedProxiesFile), testImportedProxies)
+	t.Setenv("GOHOMO_TEST_DOMAIN", "corp.example")
+	if err := saveUserRules([]string{
+		"DOMAIN,a.com,Proxy",
+		"DOMAIN,b.com,Missing",
+		"DOMAIN,c.com,HK 01",
+		"DOMAIN,d.com," + importedProxyGroup,
+		"DOMAIN-SUFFIX,${GOHOMO_TEST_DOMAIN},DIRECT",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 导入节点注入后，规则可以使用导入的节点和代理组，用户规则在配置文件的规则之前
+	v := viper.New()
+	v.SetConfigType("yaml")
+	if err := v.ReadConfig(strings.NewReader(testUserRulesConfig)); err != nil {
+		t.Fatal(err)
+	}
+	if err := injectImportedProxies(v); err != nil {
+		t.Fatal(err)
+	}
+	if err := injectUserRules(v); err != nil {
+		t.Fatal(err)
+	}
+	want := []any{
+		"DOMAIN,a.com,Proxy",
+		"DOMAIN,c.com,HK 01",
+		"DOMAIN,d.com," + importedProxyGroup,
+		"DOMAIN-SUFFIX,corp.example,DIRECT",
+		"GEOIP,CN,DIRECT",
+		"MATCH,Proxy",
+	}
+	if rules := v.Get("rules"); !reflect.DeepEqual(rules, want) {
+		t.Fatalf("rules = %q, want %q", rules, want)
+	}
+
+	// 用户规则文件中保持占位符
+	if rules, _ := loadUserRules(); rules[4] != "DOMAIN-SUFFIX,${GOHOMO_TEST_DOMAIN},DIRECT" {
+		t.Fatalf("user rules = %q", rules)
+	}
+}
@@ -10,6 +10,8 @@ import (
 	"strconv"
 	"strings"
 	"syscall"
+	"unicode/utf16"
+	"unsafe"
 
 	"github.com/go-toast/toast"
 	"golang.org/x/sys/windows"
@@ -21,6 +23,18 @@ var (
 	attachConsole            = kernel32.NewProc("AttachConsole")
 	setConsoleCtrlHandler    = kernel32.NewProc("SetConsoleCtrlHandler")
 	generateConsoleCtrlEvent = kernel32.NewProc("GenerateConsoleCtrlEvent")
+	globalLock               = kernel32.NewProc("GlobalLock")
+	globalUnlock             = kernel32.NewProc("GlobalUnlock")
+
+	user32 = windows.NewLazySystemDLL("user32.dll")
+
+	openClipboard    = user32.NewProc("OpenClipboard")
+	closeClipboard   = user32.NewProc("CloseClipboard")
+	getClipboardData = user32.NewProc("GetClipboardData")
+
+	comdlg32             = windows.NewLazySystemDLL("comdlg32.dll")
+	getOpenFileName      = comdlg32.NewProc("GetOpenFileNameW")
+	commDlgExtendedError = comdlg32.NewProc("CommDlgExtendedError")
 )
 
 // 使用 tasklist 命令检查进程是否正在运行
@@ -209,6 +223,22 @@ func execCommand(name string, arg ...string) *exec.Cmd {
 	return cmd
 }
 
+// 附加到父进程的控制台，使 GUI 程序在命令行中运行时可以输出内容
+func attachParentConsole() {
+	if handle, err := windows.GetStdHandle(windows.STD_OUTPUT_HANDLE); err == nil && handle != 0 && handle != windows.InvalidHandle {
+		// 输出已被重定向
+		return
+	}
+	// ATTACH_PARENT_PROCESS
+	if call, _, _ := attachConsole.Call(uintptr(^uint32(0))); call == 0 {
+		return
+	}
+	if out, err := os.OpenFile("CONOUT$", os.O_WRONLY, 0); err == nil {
+		os.Stdout = out
+		os.Stderr = out
+	}
+}
+
 // 发送通知
 func sendNotification(message string) {
 	notification := toast.Notification{
@@ -219,3 +249,80 @@ func sendNotification(message string) {
 		log.Printf("Failed to send notification: %v\n", err)
 	}
 }
+
+// 读取剪贴板中的文本
+func readClipboardText() (string, error) {
+	// 剪贴板操作需要在同一线程中完成
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	if call, _, err := openClipboard.Call(0); call == 0 {
+		return "", fmt.Errorf("open clipboard: %w", err)
+	}
+	defer closeClipboard.Call()
+
+	// CF_UNICODETEXT
+	handle, _, err := getClipboardData.Call(13)
+	if handle == 0 {
+		return "", fmt.Errorf("no text in clipboard: %w", err)
+	}
+	ptr, _, err := globalLock.Call(handle)
+	if ptr == 0 {
+		return "", fmt.Errorf("lock clipboard data: %w", err)
+	}
+	defer globalUnlock.Call(handle)
+	// 通过指针转换避免 uintptr 直接转为 unsafe.Pointer
+	return windows.UTF16PtrToString(*(**uint16)(unsafe.Pointer(&ptr))), nil
+}
+
+// OPENFILENAMEW 结构
+type openFileName struct {
+	StructSize      uint32
+	Owner           uintptr
+	Instance        uintptr
+	Filter          *uint16
+	CustomFilter    *uint16
+	MaxCustomFilter uint32
+	FilterIndex     uint32
+	File            *uint16
+	MaxFile         uint32
+	FileTitle       *uint16
+	MaxFileTitle    uint32
+	InitialDir      *uint16
+	Title           *uint16
+	Flags           uint32
+	FileOffset      uint16
+	FileExtension   uint16
+	DefExt          *uint16
+	CustData        uintptr
+	Hook            uintptr
+	TemplateName    *uint16
+	Reserved        uintptr
+	Reserved2       uint32
+	FlagsEx         uint32
+}
+
+// 显示打开文件对话框，filter 为 \x00 分隔的名称和匹配模式，用户取消时返回空
+func openFileDialog(title, filter string) (string, error) {
+	file := make([]uint16, windows.MAX_LONG_PATH)
+	// 过滤器以两个空字符结尾
+	filterPtr := &utf16.Encode([]rune(filter + "\x00"))[0]
+	titlePtr, _ := windows.UTF16PtrFromString(title)
+	ofn := openFileName{
+		Filter:  filterPtr,
+		File:    &file[0],
+		MaxFile: uint32(len(file)),
+		Title:   titlePtr,
+		// OFN_FILEMUSTEXIST | OFN_PATHMUSTEXIST | OFN_NOCHANGEDIR
+		Flags: 0x1000 | 0x800 | 0x8,
+	}
+	ofn.StructSize = uint32(unsafe.Sizeof(ofn))
+	if call, _, _ := getOpenFileName.Call(uintptr(unsafe.Pointer(&ofn))); call == 0 {
+		// 用户取消或出错，出错时 CommDlgExtendedError 返回非零值
+		if code, _, _ := commDlgExtendedError.Call(); code != 0 {
+			return "", fmt.Errorf("open file dialog error: %d", code)
+		}
+		return "", nil
+	}
+	return windows.UTF16ToString(file), nil
+}
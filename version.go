@@ -0,0 +1,98 @@
+package main
+
+import (
+	"strings"
+)
+
+// 比较两个版本号，a 较新时返回 1，相同时返回 0，较旧时返回 -1
+// 支持日期版本（20260101、20260101.1）和语义化版本（v1.2.3、v1.2.3-beta.1），预发布版本低于对应的正式版本
+func compareVersions(a, b string) int {
+	aMain, aPre := splitVersion(a)
+	bMain, bPre := splitVersion(b)
+	if c := compareIdentifiers(aMain, bMain, true); c != 0 {
+		return c
+	}
+	switch {
+	case aPre == bPre:
+		return 0
+	case aPre == "":
+		return 1
+	case bPre == "":
+		return -1
+	}
+	return compareIdentifiers(aPre, bPre, false)
+}
+
+// 拆分版本号为主版本和预发布标识，忽略 v 前缀和 + 后的构建信息
+func splitVersion(v string) (string, string) {
+	v = strings.TrimPrefix(strings.TrimSpace(v), "v")
+	v, _, _ = strings.Cut(v, "+")
+	main, pre, _ := strings.Cut(v, "-")
+	return main, pre
+}
+
+// 按 . 分隔逐段比较，数字段按数值比较且低于非数字段，其余按字符串比较
+// pad 为 true 时缺少的段视为 0（1.2 等于 1.2.0），否则段数少的较旧（beta 低于 beta.1）
+func compareIdentifiers(a, b string, pad bool) int {
+	aParts, bParts := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < max(len(aParts), len(bParts)); i++ {
+		var x, y string
+		if i < len(aParts) {
+			x = aParts[i]
+		} else if !pad {
+			return -1
+		}
+		if i < len(bParts) {
+			y = bParts[i]
+		} else if !pad {
+			return 1
+		}
+		if pad {
+			x, y = defaultIdentifier(x), defaultIdentifier(y)
+		}
+		if c := compareIdentifier(x, y); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+func defaultIdentifier(s string) string {
+	if s == "" {
+		return "0"
+	}
+	return s
+}
+
+// 比较单个版本段
+func compareIdentifier(x, y string) int {
+	xNum, yNum := isNumeric(x), isNumeric(y)
+	switch {
+	case xNum && yNum:
+		// 去掉前导零后先比较长度，避免大数溢出
+		x, y = strings.TrimLeft(x, "0"), strings.TrimLeft(y, "0")
+		if len(x) != len(y) {
+			if len(x) > len(y) {
+				return 1
+			}
+			return -1
+		}
+	case xNum:
+		return -1
+	case yNum:
+		return 1
+	}
+	return strings.Compare(x, y)
+}
+
+func isNumeric(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
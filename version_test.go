@@ -0,0 +1,49 @@
+package main
+
+import "testing"
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		// 日期版本
+		{"20260102", "20260101", 1},
+		{"20260101", "20260101", 0},
+		{"20251231", "20260101", -1},
+		{"20260101.1", "20260101", 1},
+		{"20260101.2", "20260101.10", -1},
+		{"20260101.0", "20260101", 0},
+		{"20260101-beta", "20260101", -1},
+		{"20260102-beta", "20260101", 1},
+		// 语义化版本，v 前缀可选
+		{"v1.2.3", "1.2.3", 0},
+		{"v1.10.0", "v1.9.9", 1},
+		{"v1.2", "v1.2.0", 0},
+		{"v2.0.0", "v1.99.99", 1},
+		{"v1.2.3+build.5", "v1.2.3", 0},
+		// 预发布版本
+		{"v1.2.3-beta.1", "v1.2.3", -1},
+		{"v1.2.3-beta.2", "v1.2.3-beta.1", 1},
+		{"v1.2.3-beta.10", "v1.2.3-beta.9", 1},
+		{"v1.2.3-beta", "v1.2.3-beta.1", -1},
+		{"v1.2.3-alpha", "v1.2.3-beta", -1},
+		{"v1.2.3-1", "v1.2.3-alpha", -1},
+		{"v1.2.4-beta.1", "v1.2.3", 1},
+		// 日期版本和语义化版本混合时日期版本较新
+		{"20260101", "v1.2.3", 1},
+		{"v9.9.9", "20260101.1", -1},
+		// 数字很大时不溢出
+		{"v1.99999999999999999999", "v1.99999999999999999998", 1},
+		{"v1.007", "v1.7", 0},
+	}
+	for _, tt := range tests {
+		if got := compareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("compareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		// 交换参数结果相反
+		if got := compareVersions(tt.b, tt.a); got != -tt.want {
+			t.Errorf("compareVersions(%q, %q) = %d, want %d", tt.b, tt.a, got, -tt.want)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	_ "embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/spf13/viper"
+)
+
+//go:embed templates/config.yaml
+var configTemplate string
+
+// ConfigTemplateData 生成配置模板使用的数据
+type ConfigTemplateData struct {
+	Secret          string // 外部控制器密钥
+	SubscriptionUrl string // 订阅地址，为空时不添加代理集合
+}
+
+// 首次运行没有配置文件时引导用户创建，依次询问导入剪贴板中的订阅地址、选择已有配置文件或生成最小配置
+// 返回创建的配置文件路径，用户全部取消时返回空
+func runConfigWizard() (string, error) {
+	path := filepath.Join(workDir, "config.yaml")
+
+	if messageBoxConfirm(AppName, I.TranSys("msg.info.wizard.subscription", nil)) {
+		subscriptionUrl, err := readSubscriptionUrl()
+		if err == nil {
+			return path, writeConfigTemplate(path, subscriptionUrl)
+		}
+		messageBoxAlert(AppName, I.TranSys("msg.error.wizard.subscription", map[string]any{"Error": err}))
+	}
+
+	if messageBoxConfirm(AppName, I.TranSys("msg.info.wizard.file", nil)) {
+		source, err := openFileDialog(I.TranSys("msg.info.wizard.file_title", nil), "YAML (*.yaml;*.yml)\x00*.yaml;*.yml\x00")
+		if err != nil {
+			log.Println("Failed to open file dialog:", err)
+		}
+		if source != "" {
+			return path, importConfigFile(source, path)
+		}
+	}
+
+	if messageBoxConfirm(AppName, I.TranSys("msg.info.wizard.template", map[string]any{"Path": path})) {
+		return path, writeConfigTemplate(path, "")
+	}
+	return "", nil
+}
+
+// 读取剪贴板中的订阅地址
+func readSubscriptionUrl() (string, error) {
+	content, err := readClipboardText()
+	if err != nil {
+		return "", err
+	}
+	content = strings.TrimSpace(content)
+	u, err := url.Parse(content)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("not a subscription url: %q", content)
+	}
+	return content, nil
+}
+
+// 使用内置模板生成配置文件，外部控制器使用随机密钥
+func writeConfigTemplate(path, subscriptionUrl string) error {
+	secret := make([]byte, 16)
+	if _, err := rand.Read(secret); err != nil {
+		return err
+	}
+	tmpl, err := template.New("config").Parse(configTemplate)
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err = tmpl.Execute(&buf, ConfigTemplateData{
+		Secret:          hex.EncodeToString(secret),
+		SubscriptionUrl: subscriptionUrl,
+	}); err != nil {
+		return err
+	}
+	log.Println("Generate config from template:", path)
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
+
+// 校验并复制已有的配置文件
+func importConfigFile(source, path string) error {
+	v := viper.New()
+	v.SetConfigFile(source)
+	v.SetConfigType("yaml")
+	if err := v.ReadInConfig(); err != nil {
+		return errors.New(I.TranSys("msg.error.core.config.read_failed", map[string]any{"Error": err}))
+	}
+	if _, err := parseCoreConfig(v); err != nil {
+		return err
+	}
+	log.Println("Import config file:", source)
+	return copyFile(source, path)
+}